@@ -13,8 +13,8 @@ import (
 	"github.com/boltdb/bolt"
 )
 
-//Check la validité des transactions d'un block
-func (b *Blockchain) CheckBlockTXs(block *twayutil.Block) error {
+//Check la validité des transactions d'un block ajouté à la hauteur passée en paramètre
+func (b *Blockchain) CheckBlockTXs(block *twayutil.Block, height int) error {
 	txs := block.Transactions
 
 	//verifie la transaction coinbase
@@ -23,14 +23,21 @@ func (b *Blockchain) CheckBlockTXs(block *twayutil.Block) error {
 		return err
 	}
 
-	//on verifie individuellement la validitié de chacun des txs du block
-	for _, tx := range txs {
-		err := CheckIfTxIsCorrect(&tx)
+	//on verifie individuellement les inputs de chacun des txs du block
+	//puis on execute l'ensemble de leurs scripts en parallèle
+	//les inputs ne peuvent dépenser que des outputs confirmés dans un block précédent
+	var checks []scriptCheck
+	for idx := range txs {
+		if txs[idx].IsCoinbase() == true {
+			continue
+		}
+		txChecks, err := prepareScriptChecks(&txs[idx], nil, height)
 		if err != nil {
 			return err
 		}
+		checks = append(checks, txChecks...)
 	}
-	return nil
+	return runScriptChecks(checks)
 }
 
 //Verifie la validité de la transaction coinbase d'un block
@@ -61,12 +68,12 @@ func (b *Blockchain) GetBlockHeight(blockHash []byte) int {
 	var i = 0
 	for {
 		bl := be.Next()
-		if bytes.Compare(bl.GetHash(), blockHash) == 0 {
-			return BC.Height - i
-		}
 		if bl == nil {
 			return -1
 		}
+		if bytes.Compare(bl.GetHash(), blockHash) == 0 {
+			return BC.Height - i
+		}
 		i++
 	}
 	return i
@@ -233,5 +240,14 @@ func (b *Blockchain) CheckNewBlock(new *twayutil.Block) error {
 	if lastChainBlockTime > newBlockTime || time.Now().Unix() < int64(newBlockTime) {
 		return errors.New(WRONG_BLOCK_TIME_ERROR)
 	}
-	return b.CheckBlockTXs(new)
+	//le block est validé à la hauteur qui suit son block précédent
+	height := b.Height + 1
+	if bytes.Compare(new.Header.HashPrevBlock, b.Tip) != 0 {
+		prevHeight := b.GetBlockHeight(new.Header.HashPrevBlock)
+		if prevHeight == -1 {
+			return errors.New(NO_NEXT_TO_TIP_ERROR)
+		}
+		height = prevHeight + 1
+	}
+	return b.CheckBlockTXs(new, height)
 }
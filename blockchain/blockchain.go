@@ -19,6 +19,8 @@ const (
 
 var (
 	BC *Blockchain
+	//clé publique au format X||Y des premières versions, conservée pour ne pas
+	//modifier le block genesis des chains existantes
 	GENESIS_PUBKEY = []byte{189, 208, 30, 89, 219, 197, 16, 58, 25, 114, 192, 26, 220, 144, 175, 157, 49, 159, 118, 140, 125, 205, 53, 177, 7, 217, 176, 2, 32, 103, 6, 158, 41, 70, 93, 47, 232, 197, 86, 128, 148, 98, 99, 151, 120, 33, 166, 193, 45, 123, 29, 252, 213, 142, 130, 88, 248, 152, 109, 119, 89, 243, 129, 88}
 )
 
@@ -37,6 +39,10 @@ func InitPKG(){
 		genesis := GenesisBlock(GENESIS_PUBKEY)
 		CreateBlockchainDB(genesis)
 	}
+	//les chains créées avant l'index des transactions sont indexées au démarrage
+	if BC.hasTxIndex() == false {
+		BC.ReindexTxs()
+	}
 	UTXO.Reindex()
 }
 
@@ -95,12 +101,16 @@ func (b *Blockchain) AddBlock(block *twayutil.Block) error {
 		if err != nil {
 			return err
 		}
+		if err := indexBlockTxs(tx, block, b.Height+1); err != nil {
+			return err
+		}
 		b.Tip = blockHash
 		return nil
 	})
 	if err == nil {
 		b.Height += 1
 		go UTXO.Reindex()
+		notifyBlockConnected(block, b.Height)
 	}
 	return err
 }
@@ -128,12 +138,16 @@ func (b *Blockchain) RemoveLastBlock() (*twayutil.Block, error) {
 		if err != nil {
 			return err
 		}
+		if err := unindexBlockTxs(tx, last); err != nil {
+			return err
+		}
 		BC.Tip = newTip
 		return nil
 	})
 	if err == nil {
 		BC.Height -= 1
 		go UTXO.Reindex()
+		notifyBlockDisconnected(last, BC.Height+1)
 	}
 	return last, err
 }
@@ -11,4 +11,5 @@ const (
 	NIL_BLOCK = "nil block"
 	NOT_FOUND = "not found"
 	BLOCK_EXISTS = "block already exists"
+	NOT_FINAL_TX = "transaction is not final"
 )
\ No newline at end of file
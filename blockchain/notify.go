@@ -0,0 +1,37 @@
+package blockchain
+
+import (
+	"tway/twayutil"
+)
+
+//Fonction appelée lorsqu'un block est ajouté ou retiré du sommet de la chain
+//height est la hauteur du block dans la chain
+type BlockListener func(block *twayutil.Block, height int)
+
+var (
+	blockConnectedListeners    []BlockListener
+	blockDisconnectedListeners []BlockListener
+)
+
+//Enregistre une fonction appelée après l'ajout d'un block à la chain
+//Les listeners doivent être enregistrés à l'initialisation des packages
+func OnBlockConnected(listener BlockListener) {
+	blockConnectedListeners = append(blockConnectedListeners, listener)
+}
+
+//Enregistre une fonction appelée après le retrait du dernier block de la chain
+func OnBlockDisconnected(listener BlockListener) {
+	blockDisconnectedListeners = append(blockDisconnectedListeners, listener)
+}
+
+func notifyBlockConnected(block *twayutil.Block, height int) {
+	for _, listener := range blockConnectedListeners {
+		listener(block, height)
+	}
+}
+
+func notifyBlockDisconnected(block *twayutil.Block, height int) {
+	for _, listener := range blockDisconnectedListeners {
+		listener(block, height)
+	}
+}
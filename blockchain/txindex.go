@@ -0,0 +1,169 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"log"
+	"tway/twayutil"
+
+	"github.com/boltdb/bolt"
+)
+
+const (
+	//Nom du bucket indexant les transactions de la chain par leur hash
+	TX_INDEX_BUCKET = "txindex"
+)
+
+//Position d'une transaction dans la chain
+type TxLocation struct {
+	BlockHash []byte
+	Height    int
+}
+
+//Positions d'une transaction, une même transaction coinbase pouvant être
+//incluse dans plusieurs blocks. La dernière position est la plus haute.
+type TxLocations struct {
+	Locations []TxLocation
+}
+
+//TxLocations -> []byte
+func (locs *TxLocations) Serialize() []byte {
+	var encoded bytes.Buffer
+	if err := gob.NewEncoder(&encoded).Encode(locs); err != nil {
+		log.Panic(err)
+	}
+	return encoded.Bytes()
+}
+
+//[]byte -> TxLocations
+func DeserializeTxLocations(d []byte) *TxLocations {
+	var locs TxLocations
+	if err := gob.NewDecoder(bytes.NewReader(d)).Decode(&locs); err != nil {
+		log.Panic(err)
+	}
+	return &locs
+}
+
+//Ajoute les transactions d'un block à l'index, dans la transaction bolt
+//ajoutant le block à la chain
+func indexBlockTxs(dbTx *bolt.Tx, block *twayutil.Block, height int) error {
+	b, err := dbTx.CreateBucketIfNotExists([]byte(TX_INDEX_BUCKET))
+	if err != nil {
+		return err
+	}
+	blockHash := block.GetHash()
+	for _, tx := range block.Transactions {
+		txHash := tx.GetHash()
+		locs := &TxLocations{}
+		if encoded := b.Get(txHash); len(encoded) > 0 {
+			locs = DeserializeTxLocations(encoded)
+		}
+		locs.Locations = append(locs.Locations, TxLocation{blockHash, height})
+		if err := b.Put(txHash, locs.Serialize()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Retire de l'index les transactions d'un block retiré de la chain
+func unindexBlockTxs(dbTx *bolt.Tx, block *twayutil.Block) error {
+	b := dbTx.Bucket([]byte(TX_INDEX_BUCKET))
+	if b == nil {
+		return nil
+	}
+	blockHash := block.GetHash()
+	for _, tx := range block.Transactions {
+		txHash := tx.GetHash()
+		encoded := b.Get(txHash)
+		if len(encoded) == 0 {
+			continue
+		}
+		locs := DeserializeTxLocations(encoded)
+		var kept []TxLocation
+		for _, loc := range locs.Locations {
+			if bytes.Compare(loc.BlockHash, blockHash) != 0 {
+				kept = append(kept, loc)
+			}
+		}
+		var err error
+		if len(kept) == 0 {
+			err = b.Delete(txHash)
+		} else {
+			err = b.Put(txHash, (&TxLocations{kept}).Serialize())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Retourne true si l'index des transactions existe
+func (b *Blockchain) hasTxIndex() bool {
+	exist := false
+	b.DB.View(func(tx *bolt.Tx) error {
+		exist = tx.Bucket([]byte(TX_INDEX_BUCKET)) != nil
+		return nil
+	})
+	return exist
+}
+
+//Reconstruit l'index des transactions en parcourant la chain
+func (b *Blockchain) ReindexTxs() error {
+	var blocks []*twayutil.Block
+	be := NewExplorer()
+	for block := be.Next(); block != nil; block = be.Next() {
+		blocks = append(blocks, block)
+	}
+	return b.DB.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket([]byte(TX_INDEX_BUCKET))
+		if err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		//les blocks sont indexés du genesis au tip
+		for i := len(blocks) - 1; i >= 0; i-- {
+			if err := indexBlockTxs(tx, blocks[i], len(blocks)-i); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//Récupère une transaction de la chain depuis l'index, avec le block
+//dans lequel elle se trouve et la hauteur du block, -1 si elle n'existe pas
+func (b *Blockchain) lookupTx(hash []byte) (*twayutil.Transaction, *twayutil.Block, int) {
+	//chain vide
+	if b.Height == 0 {
+		return nil, nil, -1
+	}
+	var block *twayutil.Block
+	height := -1
+	b.DB.View(func(tx *bolt.Tx) error {
+		index := tx.Bucket([]byte(TX_INDEX_BUCKET))
+		if index == nil {
+			return nil
+		}
+		encoded := index.Get(hash)
+		if len(encoded) == 0 {
+			return nil
+		}
+		locs := DeserializeTxLocations(encoded)
+		loc := locs.Locations[len(locs.Locations)-1]
+		if encodedBlock := tx.Bucket([]byte(BLOCK_BUCKET)).Get(loc.BlockHash); len(encodedBlock) > 0 {
+			block = twayutil.DeserializeBlock(encodedBlock)
+			height = loc.Height
+		}
+		return nil
+	})
+	if block == nil {
+		return nil, nil, -1
+	}
+	for i := range block.Transactions {
+		if bytes.Compare(hash, block.Transactions[i].GetHash()) == 0 {
+			return &block.Transactions[i], block, height
+		}
+	}
+	return nil, nil, -1
+}
@@ -0,0 +1,150 @@
+package blockchain
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	conf "tway/config"
+	s "tway/script"
+	"tway/twayutil"
+	"tway/util"
+)
+
+//Créer une chain contenant le block genesis dans un fichier temporaire
+func newTestChain(t *testing.T, genesis *twayutil.Block) {
+	dir, err := ioutil.TempDir("", "tway-chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedFile := conf.DB_FILE
+	conf.DB_FILE = filepath.Join(dir, "chain.db")
+	if err := CreateBlockchainDB(genesis); err != nil {
+		t.Fatal(err)
+	}
+	if err := BC.ReindexTxs(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		BC.DB.Close()
+		conf.DB_FILE = savedFile
+		os.RemoveAll(dir)
+	})
+}
+
+func testBlock(prev *twayutil.Block, txs ...twayutil.Transaction) *twayutil.Block {
+	prevHash := conf.GENESIS_BLOCK_PREVHASH
+	if prev != nil {
+		prevHash = prev.GetHash()
+	}
+	return &twayutil.Block{
+		Header:       twayutil.BlockHeader{HashPrevBlock: prevHash, Time: util.EncodeInt(len(txs))},
+		Counter:      uint(len(txs)),
+		Transactions: txs,
+	}
+}
+
+func checkTxLocation(t *testing.T, tx *twayutil.Transaction, block *twayutil.Block, height int) {
+	found, foundBlock, foundHeight := GetTxByHash(tx.GetHash())
+	if foundHeight != height {
+		t.Fatalf("tx %x found at height %d, want %d", tx.GetHash(), foundHeight, height)
+	}
+	if height == -1 {
+		return
+	}
+	if bytes.Compare(found.GetHash(), tx.GetHash()) != 0 || bytes.Compare(foundBlock.GetHash(), block.GetHash()) != 0 {
+		t.Fatalf("tx %x found in block %x, want %x", tx.GetHash(), foundBlock.GetHash(), block.GetHash())
+	}
+}
+
+func TestTxIndex(t *testing.T) {
+	coinbase := twayutil.NewCoinbaseTx([]byte("genesis"), 0)
+	genesis := testBlock(nil, coinbase)
+	newTestChain(t, genesis)
+
+	funding := twayutil.Transaction{
+		Version: []byte{1},
+		Inputs:  []twayutil.Input{twayutil.NewTxInput(coinbase.GetHash(), util.EncodeInt(0), nil)},
+		Outputs: []twayutil.Output{twayutil.NewTxOutput([][]byte{{s.OP_DATA_1}}, conf.REWARD)},
+	}
+	block2 := testBlock(genesis, twayutil.NewCoinbaseTx([]byte("block2"), 0), funding)
+	if err := BC.AddBlock(block2); err != nil {
+		t.Fatal(err)
+	}
+	//la même transaction coinbase que le genesis
+	block3 := testBlock(block2, coinbase)
+	if err := BC.AddBlock(block3); err != nil {
+		t.Fatal(err)
+	}
+
+	checkTxLocation(t, &funding, block2, 2)
+	checkTxLocation(t, &coinbase, block3, 3)
+	unknown := twayutil.NewCoinbaseTx([]byte("unknown"), 0)
+	checkTxLocation(t, &unknown, nil, -1)
+
+	//l'index reconstruit depuis la chain est identique
+	if err := BC.ReindexTxs(); err != nil {
+		t.Fatal(err)
+	}
+	checkTxLocation(t, &funding, block2, 2)
+	checkTxLocation(t, &coinbase, block3, 3)
+
+	//le retrait du block rend sa position précédente à la transaction coinbase
+	if _, err := BC.RemoveLastBlock(); err != nil {
+		t.Fatal(err)
+	}
+	checkTxLocation(t, &coinbase, genesis, 1)
+	if _, err := BC.RemoveLastBlock(); err != nil {
+		t.Fatal(err)
+	}
+	checkTxLocation(t, &funding, nil, -1)
+	checkTxLocation(t, &coinbase, genesis, 1)
+}
+
+//Les outputs dépensés sont résolus depuis l'index et l'ensemble des UTXOs
+func TestPrepareScriptChecks(t *testing.T) {
+	coinbase := twayutil.NewCoinbaseTx([]byte("genesis"), 0)
+	genesis := testBlock(nil, coinbase)
+	newTestChain(t, genesis)
+	funding := twayutil.Transaction{
+		Version: []byte{1},
+		Inputs:  []twayutil.Input{twayutil.NewTxInput(coinbase.GetHash(), util.EncodeInt(0), nil)},
+		Outputs: []twayutil.Output{twayutil.NewTxOutput([][]byte{{s.OP_DATA_1}}, 600), twayutil.NewTxOutput([][]byte{{s.OP_0}}, 400)},
+	}
+	block2 := testBlock(genesis, twayutil.NewCoinbaseTx([]byte("block2"), 0), funding)
+	if err := BC.AddBlock(block2); err != nil {
+		t.Fatal(err)
+	}
+	if err := UTXO.Reindex(); err != nil {
+		t.Fatal(err)
+	}
+
+	spend := &twayutil.Transaction{
+		Version:  []byte{1},
+		Inputs:   []twayutil.Input{twayutil.NewTxInput(funding.GetHash(), util.EncodeInt(0), nil), twayutil.NewTxInput(funding.GetHash(), util.EncodeInt(1), nil)},
+		Outputs:  []twayutil.Output{twayutil.NewTxOutput(nil, 900)},
+		LockTime: util.EncodeInt(0),
+	}
+	checks, err := prepareScriptChecks(spend, nil, BC.Height+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 2 || checks[1].idx != 1 || len(checks[1].prevTXs) != 1 {
+		t.Fatalf("%d checks", len(checks))
+	}
+	//le second output est verrouillé par un script invalide
+	if err := runScriptChecks(checks); err == nil || err.Error() != WRONG_SCRIPT {
+		t.Fatalf("error %v, want %s", err, WRONG_SCRIPT)
+	}
+	if err := runScriptChecks(checks[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	//output inexistant
+	missing := *spend
+	missing.Inputs = []twayutil.Input{twayutil.NewTxInput(funding.GetHash(), util.EncodeInt(2), nil)}
+	if _, err := prepareScriptChecks(&missing, nil, BC.Height+1); err == nil {
+		t.Fatal("missing output resolved")
+	}
+}
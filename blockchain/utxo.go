@@ -30,6 +30,7 @@ type UnspentOutput struct {
 	Idx      int //index of output in tx
 	Output   twayutil.Output
 	MultiSig bool
+	HTLC     bool
 }
 
 type UnspentOutputs struct {
@@ -102,6 +103,11 @@ func (utxo *UTXOSet) GetUnspentOutputsByPubKOrPubKH(pubKOrPubKHList [][]byte, am
 			unSpents := DeserializeTxOutputs(v)
 			//pour chaque output non dépnesé de la tx
 			for _, unSpent := range unSpents.Outputs {
+				//les outputs HTLC ne sont dépensables qu'avec un secret
+				//ou après expiration, voir GetHTLCOutputsByPubKH
+				if unSpent.HTLC == true {
+					continue
+				}
 				//si l'output est locké avec la pubKeyHash passé en paramètre
 				//et que le montant accumulé est inférieur au montant passé en paramètre
 				for _, pubKOrPubKH := range pubKOrPubKHList {
@@ -123,6 +129,63 @@ func (utxo *UTXOSet) GetUnspentOutputsByPubKOrPubKH(pubKOrPubKHList [][]byte, am
 	return accumulated, unspentOutputs
 }
 
+//Récupère la liste des outputs HTLC non dépensés dont le destinataire
+//ou l'émetteur (remboursement) correspond à un des pubKeyHash passés en paramètre
+func (utxo *UTXOSet) GetHTLCOutputsByPubKH(pubKHList [][]byte) []UnspentOutput {
+	var unspentOutputs []UnspentOutput
+	db := BC.DB
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(UTXO_BUCKET))
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			unSpents := DeserializeTxOutputs(v)
+			for _, unSpent := range unSpents.Outputs {
+				if unSpent.HTLC == false {
+					continue
+				}
+				for _, pubKH := range pubKHList {
+					if unSpent.Output.IsLockedWithPubKOrPubKH(pubKH) {
+						unspentOutputs = append(unspentOutputs, unSpent)
+						break
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+	return unspentOutputs
+}
+
+//Récupère la liste des outputs non dépensés locké avec le scriptPubKey passé en paramètre
+func (utxo *UTXOSet) GetUnspentOutputsByScript(scriptPubKey [][]byte) []UnspentOutput {
+	var unspentOutputs []UnspentOutput
+	db := BC.DB
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(UTXO_BUCKET))
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			unSpents := DeserializeTxOutputs(v)
+			for _, unSpent := range unSpents.Outputs {
+				if util.EqualDoubleSliceByte(unSpent.Output.ScriptPubKey, scriptPubKey) {
+					unspentOutputs = append(unspentOutputs, unSpent)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+	return unspentOutputs
+}
+
 //Reindex la liste des utxo dans le bucket des UTXOS
 func (utxo *UTXOSet) Reindex() error {
 	bucketName := []byte(UTXO_BUCKET)
@@ -157,6 +220,7 @@ func OutputToUnspentOutput(out *twayutil.Output, tx *twayutil.Transaction, vout
 		Output:   *out,
 		Idx:      vout,
 		MultiSig: script.Script.IsPayToHashScript(out.ScriptPubKey),
+		HTLC:     script.Script.IsHTLCScript(out.ScriptPubKey),
 	}
 }
 
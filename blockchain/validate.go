@@ -0,0 +1,126 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"runtime"
+	"sync"
+	s "tway/script"
+	"tway/twayutil"
+	"tway/util"
+)
+
+//Structure représentant l'execution du script d'un input
+type scriptCheck struct {
+	tx      *util.Transaction
+	prevTXs map[string]*util.Transaction
+	idx     int
+	script  [][]byte
+}
+
+//Execute le scriptSig de l'input avec le scriptPubKey de l'output lié
+func (check *scriptCheck) run() error {
+	engine := s.NewEngine(check.prevTXs, check.tx, check.idx)
+	//on execute le script
+	if err := engine.Run(check.script); err != nil {
+		return err
+	}
+	//si la stack du script apres son execution n'est pas egale a true
+	if engine.IsScriptSucceed() == false {
+		return errors.New(WRONG_SCRIPT)
+	}
+	return nil
+}
+
+//Verifie les inputs d'une transaction et retourne la liste des scripts
+//à executer pour valider chacun d'entre eux.
+//Les inputs peuvent dépenser les outputs des transactions de la vue
+//height est la hauteur du block dans lequel la transaction est incluse
+func prepareScriptChecks(tx *twayutil.Transaction, view TxView, height int) ([]scriptCheck, error) {
+	if err := CheckIfTxIsFinal(tx, height); err != nil {
+		return nil, err
+	}
+	if total_inputs, total_outputs, fees := view.GetAmounts(tx); total_inputs != (total_outputs + fees) {
+		return nil, errors.New(WRONG_BLOCK_PUTS_VALUE)
+	}
+
+	//on recupere la liste des transactions ayant permis
+	// la creation des inputs de la tx recu
+	prevTXs := view.GetPrevTxs(tx)
+	//pour des raisons de fonctionnalités avec pkg on convertit le type twayutil.Transaction en type util.Transaction
+	prevTXsUtil := make(map[string]*util.Transaction)
+	for hash, prevTX := range prevTXs {
+		prevTXsUtil[hash] = prevTX.ToTxUtil()
+	}
+	txUtil := tx.ToTxUtil()
+
+	checks := make([]scriptCheck, 0, len(tx.Inputs))
+	for idx, in := range tx.Inputs {
+		prevHash := hex.EncodeToString(in.PrevTransactionHash)
+		prevTX, exist := prevTXs[prevHash]
+		if exist == false {
+			return nil, errors.New(NOT_FOUND)
+		}
+		if err := view.CheckIfInputIsUnspent(&in); err != nil {
+			return nil, err
+		}
+
+		vout := util.DecodeInt(in.Vout)
+		if vout < 0 || vout >= len(prevTX.Outputs) {
+			return nil, errors.New(NOT_FOUND)
+		}
+		//on recupère le script pubkey de la tx precente lié a cet input
+		scriptPubKey := prevTX.Outputs[vout].ScriptPubKey
+
+		//ScriptSig + ScriptPubKey
+		scriptToRun := append(append([][]byte{}, in.ScriptSig...), scriptPubKey...)
+		checks = append(checks, scriptCheck{txUtil, prevTXsUtil, idx, scriptToRun})
+	}
+	return checks, nil
+}
+
+//Execute une liste de scripts en parallèle sur un pool de workers
+//Retourne la première erreur rencontrée
+func runScriptChecks(checks []scriptCheck) error {
+	if len(checks) == 0 {
+		return nil
+	}
+	nWorkers := runtime.NumCPU()
+	if nWorkers > len(checks) {
+		nWorkers = len(checks)
+	}
+
+	jobs := make(chan *scriptCheck)
+	quit := make(chan struct{})
+	var once sync.Once
+	var firstErr error
+	var wg sync.WaitGroup
+
+	for i := 0; i < nWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for check := range jobs {
+				if err := check.run(); err != nil {
+					once.Do(func() {
+						firstErr = err
+						close(quit)
+					})
+				}
+			}
+		}()
+	}
+
+Dispatch:
+	for i := range checks {
+		select {
+		case jobs <- &checks[i]:
+		case <-quit:
+			//un script est invalide, inutile de vérifier les suivants
+			break Dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,54 @@
+package blockchain
+
+import (
+	"strings"
+	"testing"
+	s "tway/script"
+	"tway/util"
+)
+
+func scriptTestCheck(idx int, srpt [][]byte) scriptCheck {
+	tx := &util.Transaction{Version: []byte{1}}
+	return scriptCheck{tx, nil, idx, srpt}
+}
+
+func TestRunScriptChecks(t *testing.T) {
+	valid := [][]byte{{s.OP_DATA_1}}
+	for _, n := range []int{0, 1, 2, 100} {
+		var checks []scriptCheck
+		for i := 0; i < n; i++ {
+			checks = append(checks, scriptTestCheck(i, valid))
+		}
+		if err := runScriptChecks(checks); err != nil {
+			t.Fatalf("%d valid checks: %v", n, err)
+		}
+	}
+}
+
+//Un seul input invalide parmi tous ceux vérifiés en parallèle invalide l'ensemble
+func TestRunScriptChecksOneInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		srpt   [][]byte
+		errMsg string
+	}{
+		{"false result", [][]byte{{s.OP_0}}, WRONG_SCRIPT},
+		{"script error", [][]byte{{s.OP_DATA_1}, {s.OP_ENDIF}}, "OP_ENDIF"},
+	}
+	for _, test := range tests {
+		for _, bad := range []int{0, 37, 99} {
+			var checks []scriptCheck
+			for i := 0; i < 100; i++ {
+				srpt := [][]byte{{s.OP_DATA_1}}
+				if i == bad {
+					srpt = test.srpt
+				}
+				checks = append(checks, scriptTestCheck(i, srpt))
+			}
+			err := runScriptChecks(checks)
+			if err == nil || strings.Contains(err.Error(), test.errMsg) == false {
+				t.Fatalf("%s at input %d: error %v", test.name, bad, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"tway/twayutil"
+	"tway/util"
+)
+
+//Transactions de la mempool dont les outputs peuvent être dépensés par une autre
+//transaction de la mempool. Les transactions d'un block ne peuvent dépenser que
+//des outputs confirmés, elles sont vérifiées avec une vue vide.
+//Les transactions absentes de la vue sont recherchées dans la blockchain.
+type TxView map[string]*twayutil.Transaction
+
+//Récupère une transaction de la vue ou de la blockchain
+//Retourne nil si la transaction n'existe pas
+func (v TxView) GetTx(hash []byte) *twayutil.Transaction {
+	if tx, exist := v[hex.EncodeToString(hash)]; exist {
+		return tx
+	}
+	tx, _, height := GetTxByHash(hash)
+	if height == -1 {
+		return nil
+	}
+	return tx
+}
+
+//Récupère la liste des transactions ayant permis la création des inputs de la transaction
+func (v TxView) GetPrevTxs(tx *twayutil.Transaction) map[string]*twayutil.Transaction {
+	prevTXs := make(map[string]*twayutil.Transaction)
+	for _, in := range tx.Inputs {
+		if prevTx := v.GetTx(in.PrevTransactionHash); prevTx != nil {
+			prevTXs[hex.EncodeToString(in.PrevTransactionHash)] = prevTx
+		} else {
+			fmt.Println("error in GetPrevTxs")
+		}
+	}
+	return prevTXs
+}
+
+//Retourne le montant total des outputs dépensés par la transaction
+func (v TxView) GetAmountsInput(tx *twayutil.Transaction) int {
+	var total_inputs = 0
+	if tx.IsCoinbase() {
+		return 0
+	}
+	for _, in := range tx.Inputs {
+		prevTx := v.GetTx(in.PrevTransactionHash)
+		vout := util.DecodeInt(in.Vout)
+		if prevTx == nil || vout < 0 || vout >= len(prevTx.Outputs) {
+			fmt.Println("ERROR IN GetAmountsInput")
+			return 0
+		}
+		total_inputs += util.DecodeInt(prevTx.Outputs[vout].Value)
+	}
+	return total_inputs
+}
+
+//Retourne le montant total des inputs, le montant total des outputs et les frais de la transaction
+func (v TxView) GetAmounts(tx *twayutil.Transaction) (int, int, int) {
+	var total_outputs = GetAmountsOutput(tx)
+	if tx.IsCoinbase() {
+		return 0, total_outputs, 0
+	}
+	var total_inputs = v.GetAmountsInput(tx)
+	return total_inputs, total_outputs, total_inputs - total_outputs
+}
+
+//Verifie que l'output dépensé par l'input est un UTXO ou un output d'une transaction de la vue
+func (v TxView) CheckIfInputIsUnspent(in *twayutil.Input) error {
+	if prevTx, exist := v[hex.EncodeToString(in.PrevTransactionHash)]; exist {
+		vout := util.DecodeInt(in.Vout)
+		if vout < 0 || vout >= len(prevTx.Outputs) {
+			return errors.New(NOT_FOUND)
+		}
+		return nil
+	}
+	if UTXO.GetUnSpentOutputByVoutAndTxHash(util.DecodeInt(in.Vout), in.PrevTransactionHash) == nil {
+		return errors.New(NOT_FOUND)
+	}
+	return nil
+}
+
+//Verifie les scripts des inputs de la transaction, qui peut dépenser
+//les outputs des transactions de la vue
+//La transaction doit pouvoir être incluse dans le prochain block
+func (v TxView) CheckTx(tx *twayutil.Transaction) error {
+	if tx.IsCoinbase() == true {
+		return nil
+	}
+	checks, err := prepareScriptChecks(tx, v, BC.Height+1)
+	if err != nil {
+		return err
+	}
+	return runScriptChecks(checks)
+}
@@ -20,6 +20,7 @@ func BlockPrintUsage(){
 	fmt.Println(" --loop \t Loop execution of a cmd. /!\""+"Works only with : --new and --remove")
 	fmt.Println(" --new \t Create and add new blockchain onto the blockchain")
 	fmt.Println(" --remove \t Remove block. /!\""+"Works only with --last")
+	fmt.Println(" --wallet \t name of a loaded wallet to use instead of the default wallet of the node")
 }
 
 
@@ -58,7 +59,12 @@ func printBlock(block *twayutil.Block){
 }
 
 func NewBlock(txs []twayutil.Transaction, fees int){
-	block := twayutil.NewBlock(txs, b.BC.Tip, wallet.NewMiningWallet(), fees, b.BC.GetNewBits())
+	payout, err := wallet.NewMiningWallet()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	block := twayutil.NewBlock(txs, b.BC.Tip, payout, fees, b.BC.GetNewBits())
 	//Créer une target de proof of work
 	pow := b.NewProofOfWork(block)
 	//cherche le nonce correspondant à la target
@@ -131,7 +137,11 @@ func BlockPrintCli(){
 	height := blockCMD.Int("height", 0, "Print a block by its height.")
 	remove := blockCMD.Bool("remove", false, "remove block. /!\""+"Works only with --last")
 
+	walletName := walletFlag(blockCMD)
 	handleParsingError(blockCMD)
+	if selectWallet(*walletName) == false {
+		return
+	}
 
 	if *hash != "" {
 		hashCMD(*hash)
@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 	"os"
+	"tway/wallet"
 )
 
 type CLI struct {
@@ -14,11 +15,17 @@ func (cli *CLI) printUsage() {
 	fmt.Println(" block \t Manage block")
 	fmt.Println(" blockchain \t Manage blockchain")
 	fmt.Println(" blockchain_print \t Print blockchain")
+	fmt.Println(" fee \t Estimate transaction fees")
+	fmt.Println(" htlc \t Fund, claim and refund hash time locked contracts")
 	fmt.Println(" input \t Manage input")
+	fmt.Println(" multisig \t Manage multisig accounts")
+	fmt.Println(" psbt \t Create, sign, combine and finalize partially signed transactions")
+	fmt.Println(" script \t Compile, decode and classify scripts")
 	fmt.Println(" server \t Manage server")
 	fmt.Println(" tx \t Manage transactions")
 	fmt.Println(" tx_create \t Create transaction")
 	fmt.Println(" wallet \t Manage local wallets")
+	fmt.Println(" watchonly \t Watch addresses without their private keys")
 	fmt.Println(" utxo \t Manage UTXOs")
 }
 
@@ -34,6 +41,11 @@ func (cli *CLI) validateArgs() {
 func Start() {
 	cli := new(CLI)
 	cli.validateArgs()
+	//les commandes demandent la passphrase lorsqu'une clé privée est nécessaire,
+	//le serveur ne la demande qu'à son démarrage avec --unlock
+	if os.Args[1] != "server" {
+		wallet.PassphrasePrompt = askPassphrase
+	}
 	cli.listMenu()
 }
 
@@ -49,9 +61,24 @@ func (cli *CLI) listMenu() {
 	case "blockchain_print":
 		BlockchainPrintCli()
 
+	case "fee":
+		feeCli()
+
+	case "htlc":
+		htlcCli()
+
 	case "input":
 		inputCli()
 
+	case "multisig":
+		multisigCli()
+
+	case "psbt":
+		psbtCli()
+
+	case "script":
+		scriptCli()
+
 	case "server":
 		serverCli()
 
@@ -64,6 +91,9 @@ func (cli *CLI) listMenu() {
 	case "wallet":
 		walletCli()
 
+	case "watchonly":
+		watchOnlyCli()
+
 	case "utxo":
 		UTXOCli()
 	default:
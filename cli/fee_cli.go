@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"tway/mempool"
+)
+
+//Délai de confirmation utilisé par défaut pour estimer les frais d'une transaction
+const defaultConfTarget = 6
+
+func feeUsage() {
+	fmt.Println(" Options:")
+	fmt.Println(" --estimate \t Print the fee rate needed to confirm a transaction. Works with [--target]")
+	fmt.Println(" --stats \t Print fee rates and confirmation delays of transactions seen in the mempool")
+}
+
+//Retourne le taux de frais estimé pour une confirmation dans target blocks
+//ou 0 si l'estimateur n'a pas assez de données
+func estimateFeeRate(target int) int {
+	feeRate, err := mempool.FeeEstimator.EstimateFeeRate(target)
+	if err != nil {
+		fmt.Println(err, "- no fee rate applied")
+		return 0
+	}
+	return feeRate
+}
+
+func printFeeEstimates(target int) {
+	targets := []int{1, 2, 3, 6, 12, mempool.MaxConfirmTarget}
+	if target > 0 {
+		targets = []int{target}
+	}
+	for _, t := range targets {
+		feeRate, err := mempool.FeeEstimator.EstimateFeeRate(t)
+		if err != nil {
+			fmt.Printf("%d blocks\t%s\n", t, err)
+		} else {
+			fmt.Printf("%d blocks\t%d coins per byte\n", t, feeRate)
+		}
+	}
+}
+
+func printFeeStats() {
+	stats, pending := mempool.FeeEstimator.Stats()
+	fmt.Println(pending, "transactions waiting for confirmation")
+	for _, s := range stats {
+		fmt.Printf(">= %.1f coins per byte\t%.2f txs", s.FeeRate, s.Total)
+		for _, t := range []int{1, 3, 6, mempool.MaxConfirmTarget} {
+			fmt.Printf("\t%d blocks: %.0f%%", t, 100*s.Confirmed[t-1]/s.Total)
+		}
+		fmt.Println()
+	}
+}
+
+func feeCli() {
+	feeCMD := flag.NewFlagSet("fee", flag.ExitOnError)
+	estimate := feeCMD.Bool("estimate", false, "Print estimated fee rates")
+	target := feeCMD.Int("target", 0, "number of blocks in which the transaction should be confirmed")
+	stats := feeCMD.Bool("stats", false, "Print fee estimator statistics")
+	handleParsingError(feeCMD)
+
+	if *estimate {
+		printFeeEstimates(*target)
+	} else if *stats {
+		printFeeStats()
+	} else {
+		feeUsage()
+	}
+}
@@ -0,0 +1,258 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	b "tway/blockchain"
+	conf "tway/config"
+	"tway/script"
+	"tway/server"
+	"tway/twayutil"
+	"tway/util"
+	"tway/wallet"
+)
+
+func htlcUsage() {
+	fmt.Println(" Options:")
+	fmt.Println(" --fund \t Lock coins in a hash time locked contract. Works with --to --amount --timeout [--hash] [--fees]")
+	fmt.Println(" --claim \t Spend a HTLC output by revealing its secret. Works with --txid --vout --preimage [--to] [--fees]")
+	fmt.Println(" --refund \t Get back coins of an expired HTLC output. Works with --txid --vout [--to] [--fees]")
+	fmt.Println(" --list \t Print HTLC outputs linked with local wallets")
+	fmt.Println(" --broadcast \t send the transaction to network's nodes")
+	fmt.Println(" --wallet \t name of a loaded wallet to use instead of the default wallet of the node")
+}
+
+//Envoie la transaction au réseau ou la mine localement
+func submitTx(tx *twayutil.Transaction, fees int, broadcast bool) {
+	//Affichage de la transaction
+	printTx(tx)
+	if broadcast == false {
+		NewBlock([]twayutil.Transaction{*tx}, fees)
+	} else {
+		broadcastTx(tx)
+	}
+}
+
+//Envoie la transaction au main node, ses inputs ne sont plus
+//sélectionnés par le wallet tant qu'elle n'est pas confirmée
+func broadcastTx(tx *twayutil.Transaction) bool {
+	s := server.NewServer(false, false, false)
+	if _, err := s.SendTx(server.GetMainNode(), tx); err != nil {
+		fmt.Println(err)
+		return false
+	}
+	wallet.AddPendingTx(tx)
+	return true
+}
+
+//Créer un output HTLC vers le destinataire
+func fundHTLC(to string, amount, fees, timeout int, hashHex string, broadcast bool) {
+	//le destinataire réclame les fonds avec sa signature et sa clé publique
+	recipientPubKeyH, err := wallet.DecodePubKeyHashAddress(to)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var preimage, hash []byte
+	if hashHex != "" {
+		h, err := hex.DecodeString(hashHex)
+		if err != nil || len(h) != 32 {
+			fmt.Println("hash must be a sha256 hash at hex format")
+			return
+		}
+		hash = h
+	} else {
+		//génère un nouveau secret
+		preimage = make([]byte, script.HTLCPreimageLength)
+		if _, err := rand.Read(preimage); err != nil {
+			fmt.Println(err)
+			return
+		}
+		hash = util.Sha256(preimage)
+	}
+	if timeout <= 0 {
+		fmt.Println("--timeout must be a number of blocks greater than 0")
+		return
+	}
+	lockTime := b.BC.Height + timeout
+
+	//les fonds sont remboursés sur une nouvelle adresse locale
+	refundAddr, err := wallet.GenerateWallet()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	refundPubKeyH := wallet.GetPubKeyHashFromAddress([]byte(refundAddr))
+
+	lockingScript := script.Script.HTLCLockingScript(hash, recipientPubKeyH, refundPubKeyH, lockTime)
+	out := twayutil.NewTxOutput(lockingScript, amount)
+
+	ctxInfo := &createTxInfo{amount: amount, fees: fees, outputs: []twayutil.Output{out}}
+	tx := createTx(ctxInfo)
+	if tx == nil {
+		return
+	}
+	if preimage != nil {
+		fmt.Println("preimage:", hex.EncodeToString(preimage))
+	}
+	fmt.Println("hash:", hex.EncodeToString(hash))
+	fmt.Println("locktime:", lockTime)
+	fmt.Println("refund address:", refundAddr)
+	fmt.Println()
+	submitTx(tx, ctxInfo.fees, broadcast)
+}
+
+//Récupère un output HTLC local par son txid et son index
+func getLocalHTLCOutput(txid string, vout int) *wallet.LocalHTLCOutput {
+	txidBytes, err := hex.DecodeString(txid)
+	if err != nil {
+		return nil
+	}
+	for _, out := range wallet.GetLocalHTLCOutputs() {
+		if bytes.Compare(out.TxID, txidBytes) == 0 && out.Idx == vout {
+			return &out
+		}
+	}
+	return nil
+}
+
+//Créer une transaction dépensant un output HTLC
+//unlock génère le scriptSig à partir de la signature et de la clé publique du wallet
+func spendHTLC(out *wallet.LocalHTLCOutput, w *wallet.Wallet, to string, fees, lockTime int, unlock func(signature, pubKey []byte) [][]byte) *twayutil.Transaction {
+	if err := wallet.CheckUnlocked(); err != nil {
+		fmt.Println(err)
+		return nil
+	}
+	toPubKeyH := wallet.HashPubKey(w.PublicKey)
+	if to != "" {
+		pubKeyHash, err := wallet.DecodePubKeyHashAddress(to)
+		if err != nil {
+			fmt.Println(err)
+			return nil
+		}
+		toPubKeyH = pubKeyHash
+	}
+	if fees >= out.Amount {
+		fmt.Println("fees are higher than the HTLC amount")
+		return nil
+	}
+
+	var emptyScript [][]byte
+	input := twayutil.NewTxInput(out.TxID, util.EncodeInt(out.Idx), emptyScript)
+	output := twayutil.NewTxOutput(script.Script.LockingScript([][]byte{toPubKeyH}, 0), out.Amount-fees)
+	tx := &twayutil.Transaction{
+		Version:    []byte{conf.VERSION},
+		InCounter:  util.EncodeInt(1),
+		Inputs:     []twayutil.Input{input},
+		OutCounter: util.EncodeInt(1),
+		Outputs:    []twayutil.Output{output},
+		LockTime:   util.EncodeInt(lockTime),
+	}
+
+	prevTx, _, height := b.GetTxByHash(out.TxID)
+	if height == -1 {
+		fmt.Println("HTLC transaction not found")
+		return nil
+	}
+	prevTXs := map[string]*util.Transaction{hex.EncodeToString(out.TxID): prevTx.ToTxUtil()}
+	signature, err := tx.SignInput(prevTXs, 0, &w.PrivateKey)
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+	tx.Inputs[0] = twayutil.NewTxInput(out.TxID, util.EncodeInt(out.Idx), unlock(signature, w.PublicKey))
+	return tx
+}
+
+func claimHTLC(txid string, vout int, preimageHex, to string, fees int, broadcast bool) {
+	out := getLocalHTLCOutput(txid, vout)
+	if out == nil || out.ClaimW == nil {
+		fmt.Println("any HTLC output claimable by a local wallet")
+		return
+	}
+	preimage, err := hex.DecodeString(preimageHex)
+	if err != nil || bytes.Compare(util.Sha256(preimage), out.Info.Hash) != 0 {
+		fmt.Println("preimage doesn't match with the HTLC hash")
+		return
+	}
+	tx := spendHTLC(out, out.ClaimW, to, fees, 0, func(signature, pubKey []byte) [][]byte {
+		return script.Script.HTLCClaimScript(signature, pubKey, preimage)
+	})
+	if tx == nil {
+		return
+	}
+	submitTx(tx, fees, broadcast)
+}
+
+func refundHTLC(txid string, vout int, to string, fees int, broadcast bool) {
+	out := getLocalHTLCOutput(txid, vout)
+	if out == nil || out.RefundW == nil {
+		fmt.Println("any HTLC output refundable by a local wallet")
+		return
+	}
+	//la transaction de remboursement sera incluse au plus tôt dans le prochain block
+	if out.IsExpired(b.BC.Height+1) == false {
+		fmt.Println("HTLC hasn't expired yet, refund is possible at height", out.Info.LockTime)
+		return
+	}
+	tx := spendHTLC(out, out.RefundW, to, fees, out.Info.LockTime, script.Script.HTLCRefundScript)
+	if tx == nil {
+		return
+	}
+	submitTx(tx, fees, broadcast)
+}
+
+func printLocalHTLCs() {
+	nextHeight := b.BC.Height + 1
+	for _, out := range wallet.GetLocalHTLCOutputs() {
+		fmt.Println("txID:", hex.EncodeToString(out.TxID))
+		fmt.Println("vout:", out.Idx)
+		fmt.Println("value:", out.Amount)
+		fmt.Println("hash:", hex.EncodeToString(out.Info.Hash))
+		fmt.Println("locktime:", out.Info.LockTime, "\texpired:", out.IsExpired(nextHeight))
+		if out.ClaimW != nil {
+			fmt.Println("claimable by:", string(out.ClaimW.GetAddress()))
+		}
+		if out.RefundW != nil {
+			fmt.Println("refundable to:", string(out.RefundW.GetAddress()))
+		}
+		fmt.Println()
+	}
+}
+
+func htlcCli() {
+	htlcCMD := flag.NewFlagSet("htlc", flag.ExitOnError)
+	fund := htlcCMD.Bool("fund", false, "Lock coins in a HTLC")
+	claim := htlcCMD.Bool("claim", false, "Spend a HTLC with its secret")
+	refund := htlcCMD.Bool("refund", false, "Get back coins of an expired HTLC")
+	list := htlcCMD.Bool("list", false, "Print HTLC outputs linked with local wallets")
+	to := htlcCMD.String("to", "", "recipient address")
+	amount := htlcCMD.Int("amount", 0, "amount to lock")
+	fees := htlcCMD.Int("fees", 0, "fees to offer to miner")
+	timeout := htlcCMD.Int("timeout", 0, "number of blocks before the refund is possible")
+	hash := htlcCMD.String("hash", "", "sha256 of the secret at hex format. A new secret is generated if empty")
+	txid := htlcCMD.String("txid", "", "hash of the transaction containing the HTLC output")
+	vout := htlcCMD.Int("vout", -1, "index of the HTLC output")
+	preimage := htlcCMD.String("preimage", "", "secret at hex format")
+	broadcast := htlcCMD.Bool("broadcast", false, "broadcast transaction to the main node")
+	walletName := walletFlag(htlcCMD)
+	handleParsingError(htlcCMD)
+	if selectWallet(*walletName) == false {
+		return
+	}
+
+	if *fund && *to != "" && *amount > 0 {
+		fundHTLC(*to, *amount, *fees, *timeout, *hash, *broadcast)
+	} else if *claim && *txid != "" && *vout > -1 && *preimage != "" {
+		claimHTLC(*txid, *vout, *preimage, *to, *fees, *broadcast)
+	} else if *refund && *txid != "" && *vout > -1 {
+		refundHTLC(*txid, *vout, *to, *fees, *broadcast)
+	} else if *list {
+		printLocalHTLCs()
+	} else {
+		htlcUsage()
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/hex"
 	"flag"
 	"fmt"
-	"strings"
 	"tway/script"
 	"tway/twayutil"
 	"tway/util"
@@ -32,16 +31,10 @@ func inputCli() {
 			prevTxHashBytes, _ := hex.DecodeString(*prevTxHash)
 			voutBytes := util.EncodeInt(*vout)
 
-			var scriptSig [][]byte
-			opcodeArr := strings.Split(*scriptSigString, " ")
-			for _, op := range opcodeArr {
-				b, found := script.GetOpcodeValueByName(op)
-				if found == true {
-					scriptSig = append(scriptSig, []byte{b})
-				} else {
-					bytes, _ := hex.DecodeString(op)
-					scriptSig = append(scriptSig, bytes)
-				}
+			scriptSig, err := script.Script.Compile(*scriptSigString)
+			if err != nil {
+				fmt.Println(err)
+				return
 			}
 			in := twayutil.NewTxInput(prevTxHashBytes, voutBytes, scriptSig)
 			fmt.Println(hex.EncodeToString(in.Serialize()))
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"tway/twayutil"
+	"tway/util"
+	"tway/wallet"
+)
+
+func multisigUsage() {
+	fmt.Println(" Options:")
+	fmt.Println(" --add \t Register a multisig account. Works with --nsig --pubkeys [--label]")
+	fmt.Println(" --list \t Print registered multisig accounts with their balance")
+	fmt.Println(" --utxos \t Print unspent outputs of a multisig account. Works with --address")
+	fmt.Println(" --spend \t Create a partially signed transaction signed by local keys. Works with --address --to --amount [--fees] [--out]")
+	fmt.Println(" --pubkeys \t public keys or local addresses separated by a , in the order of the script")
+	fmt.Println(" --wallet \t name of a loaded wallet to use instead of the default wallet of the node")
+}
+
+//Parse une liste de clés publiques ou d'adresses locales séparées par une virgule
+func parseMultiSigKeys(list string) ([][]byte, error) {
+	var pubKeys [][]byte
+	for _, elem := range strings.Split(strings.Replace(list, " ", "", -1), ",") {
+		if wallet.IsAddressStored(elem) {
+			pubKeys = append(pubKeys, wallet.GetWallet(elem).PublicKey)
+			continue
+		}
+		pubKey, err := hex.DecodeString(elem)
+		if err != nil {
+			return nil, fmt.Errorf("%s is neither a public key nor a local address", elem)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	return pubKeys, nil
+}
+
+func addMultiSigAccount(label string, nSig int, pubKeysList string) {
+	pubKeys, err := parseMultiSigKeys(pubKeysList)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	account, err := wallet.NewMultiSigAccount(label, nSig, pubKeys)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	addr, err := wallet.AddMultiSigAccount(account)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("address:", addr)
+	fmt.Printf("%d-of-%d multisig account, %d local keys\n", account.NSig, len(account.PubKeys), len(account.LocalWallets()))
+}
+
+func printMultiSigAccounts() {
+	var addrs []string
+	for addr := range wallet.MultiSigAccounts {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	for _, addr := range addrs {
+		account := wallet.MultiSigAccounts[addr]
+		amount, _ := account.GetUnspentOutputs()
+		fmt.Printf("%s\t%d\t%d-of-%d\t%s\n", addr, amount, account.NSig, len(account.PubKeys), account.Label)
+		for _, pubKey := range account.PubKeys {
+			if account.IsLocalKey(pubKey) {
+				fmt.Println("    ", hex.EncodeToString(pubKey), "(local)")
+			} else {
+				fmt.Println("    ", hex.EncodeToString(pubKey))
+			}
+		}
+	}
+}
+
+func printMultiSigUTXOs(account *wallet.MultiSigAccount) {
+	_, unspents := account.GetUnspentOutputs()
+	for _, us := range unspents {
+		fmt.Printf("%x:%d\t%d\n", us.TxID, us.Idx, util.DecodeInt(us.Output.Value))
+	}
+}
+
+//Créer une transaction dépensant les fonds du compte multisig,
+//signée par les clés locales du compte.
+//Les signatures restantes sont ajoutées par les autres cosignataires
+func spendMultiSig(account *wallet.MultiSigAccount, to [][]byte, amount, fees int) (*twayutil.PSBT, error) {
+	var inputs []twayutil.Input
+	var amountGot int
+	_, unspents := account.GetUnspentOutputs()
+	for _, us := range unspents {
+		if amountGot >= amount+fees {
+			break
+		}
+		var emptyScript [][]byte
+		inputs = append(inputs, twayutil.NewTxInput(us.TxID, util.EncodeInt(us.Idx), emptyScript))
+		amountGot += util.DecodeInt(us.Output.Value)
+	}
+	if amountGot < amount+fees {
+		return nil, errors.New("You don't have enough coin to perform this transaction.")
+	}
+
+	psbt, err := createPSBT(inputs, to, amount, fees, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := signPSBTWithWallets(psbt, account.LocalWallets()); err != nil {
+		return nil, err
+	}
+	return psbt, nil
+}
+
+func multisigCli() {
+	multisigCMD := flag.NewFlagSet("multisig", flag.ExitOnError)
+	add := multisigCMD.Bool("add", false, "Register a multisig account")
+	list := multisigCMD.Bool("list", false, "Print multisig accounts")
+	utxos := multisigCMD.Bool("utxos", false, "Print unspent outputs of a multisig account")
+	spend := multisigCMD.Bool("spend", false, "Create a transaction spending multisig account funds")
+	label := multisigCMD.String("label", "", "name of the multisig account")
+	nSig := multisigCMD.Int("nsig", 0, "Number of signature required to spend the account funds")
+	pubKeys := multisigCMD.String("pubkeys", "", "public keys or local addresses separated by a ,")
+	address := multisigCMD.String("address", "", "address of the multisig account")
+	toString := multisigCMD.String("to", "", "address to send")
+	amount := multisigCMD.Int("amount", 0, "amount to send")
+	fees := multisigCMD.Int("fees", 0, "fees to offer to miner")
+	out := multisigCMD.String("out", "", "write the partially signed transaction in this file")
+	walletName := walletFlag(multisigCMD)
+	handleParsingError(multisigCMD)
+	if selectWallet(*walletName) == false {
+		return
+	}
+
+	if *add && *nSig > 0 && *pubKeys != "" {
+		addMultiSigAccount(*label, *nSig, *pubKeys)
+	} else if *list {
+		printMultiSigAccounts()
+	} else if (*utxos || *spend) && *address != "" {
+		account := wallet.GetMultiSigAccount(*address)
+		if account == nil {
+			fmt.Println("multisig account not found")
+			return
+		}
+		if *utxos {
+			printMultiSigUTXOs(account)
+			return
+		}
+		if *toString == "" || *amount <= 0 {
+			multisigUsage()
+			return
+		}
+		toPubKeyHash, err := wallet.DecodePubKeyHashAddress(*toString)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		psbt, err := spendMultiSig(account, [][]byte{toPubKeyHash}, *amount, *fees)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		got, required := psbt.Inputs[0].SignatureCount()
+		fmt.Printf("signatures: %d/%d\n", got, required)
+		if psbt.IsComplete() {
+			fmt.Println("transaction is fully signed, use psbt --finalize to get it")
+		}
+		writePSBT(psbt, *out)
+	} else {
+		multisigUsage()
+	}
+}
@@ -0,0 +1,275 @@
+package cli
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	b "tway/blockchain"
+	conf "tway/config"
+	"tway/script"
+	"tway/twayutil"
+	"tway/util"
+	"tway/wallet"
+)
+
+func psbtUsage() {
+	fmt.Println(" Options:")
+	fmt.Println(" --create \t Create a partially signed transaction. Works with --utxos --to --amount [--nsig] [--fees]")
+	fmt.Println(" --sign \t Sign inputs with local wallets. Works with --psbt [--address]")
+	fmt.Println(" --combine \t Merge signatures of several partially signed transactions. Works with --psbt PSBT_1,PSBT_2")
+	fmt.Println(" --finalize \t Print the signed transaction. Works with --psbt")
+	fmt.Println(" --broadcast \t Finalize and send the transaction to network's nodes. Works with --psbt")
+	fmt.Println(" --decode \t Print a partially signed transaction. Works with --psbt")
+	fmt.Println(" --psbt \t partially signed transaction at hex format or path of a file containing it")
+	fmt.Println(" --out \t write the partially signed transaction in this file")
+	fmt.Println(" --wallet \t name of a loaded wallet to use instead of the default wallet of the node")
+}
+
+//Récupère une transaction partiellement signée
+//depuis une chaîne hexadecimale ou un fichier la contenant
+func readPSBT(arg string) (*twayutil.PSBT, error) {
+	hexString := arg
+	if _, err := os.Stat(arg); err == nil {
+		data, err := ioutil.ReadFile(arg)
+		if err != nil {
+			return nil, err
+		}
+		hexString = string(data)
+	}
+	data, err := hex.DecodeString(strings.TrimSpace(hexString))
+	if err != nil {
+		return nil, errors.New("partially signed transaction is not at hex format")
+	}
+	return twayutil.DeserializePSBT(data)
+}
+
+//Affiche la transaction partiellement signée au format hexadecimal
+//ou l'écrit dans le fichier out
+func writePSBT(psbt *twayutil.PSBT, out string) {
+	hexString := hex.EncodeToString(psbt.Serialize())
+	if out == "" {
+		fmt.Println(hexString)
+		return
+	}
+	if err := ioutil.WriteFile(out, []byte(hexString+"\n"), 0644); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("partially signed transaction written in", out)
+}
+
+//Parse une liste d'outputs au format txid:vout séparés par une virgule
+func parseOutpoints(utxos string) ([]twayutil.Input, error) {
+	var inputs []twayutil.Input
+	for _, outpoint := range strings.Split(strings.Replace(utxos, " ", "", -1), ",") {
+		parts := strings.Split(outpoint, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s is not at txid:vout format", outpoint)
+		}
+		txid, err := hex.DecodeString(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid txid", parts[0])
+		}
+		vout, err := strconv.Atoi(parts[1])
+		if err != nil || vout < 0 {
+			return nil, fmt.Errorf("%s is not a valid output index", parts[1])
+		}
+		var emptyScript [][]byte
+		inputs = append(inputs, twayutil.NewTxInput(txid, util.EncodeInt(vout), emptyScript))
+	}
+	return inputs, nil
+}
+
+//Créer une transaction partiellement signée dépensant les inputs passés en paramètre
+//L'excédent est renvoyé sur le scriptPubKey du premier input
+func createPSBT(inputs []twayutil.Input, to [][]byte, amount, fees, nSig int) (*twayutil.PSBT, error) {
+	prevTXs := make(map[string]*twayutil.Transaction)
+	var amountGot int
+	var changeScript [][]byte
+	for _, in := range inputs {
+		vout := util.DecodeInt(in.Vout)
+		uo := b.UTXO.GetUnSpentOutputByVoutAndTxHash(vout, in.PrevTransactionHash)
+		if uo == nil {
+			return nil, fmt.Errorf("%x:%d is not an unspent output", in.PrevTransactionHash, vout)
+		}
+		prevTx, _, height := b.GetTxByHash(in.PrevTransactionHash)
+		if height == -1 {
+			return nil, fmt.Errorf("transaction %x not found", in.PrevTransactionHash)
+		}
+		prevTXs[hex.EncodeToString(in.PrevTransactionHash)] = prevTx
+		amountGot += util.DecodeInt(uo.Output.Value)
+		if changeScript == nil {
+			changeScript = uo.Output.ScriptPubKey
+		}
+	}
+	if amount+fees > amountGot {
+		return nil, errors.New("You don't have enough coin to perform this transaction.")
+	}
+
+	outputs := []twayutil.Output{twayutil.NewTxOutput(script.Script.LockingScript(to, nSig), amount)}
+	if amountGot > amount+fees {
+		outputs = append(outputs, twayutil.NewTxOutput(changeScript, amountGot-(amount+fees)))
+	}
+	tx := &twayutil.Transaction{
+		Version:    []byte{conf.VERSION},
+		InCounter:  util.EncodeInt(len(inputs)),
+		Inputs:     inputs,
+		OutCounter: util.EncodeInt(len(outputs)),
+		Outputs:    outputs,
+	}
+	return twayutil.NewPSBT(tx, prevTXs)
+}
+
+//Signe la transaction partiellement signée avec les wallets locaux
+//ou seulement avec le wallet lié à address
+func signPSBT(psbt *twayutil.PSBT, address string) error {
+	var wallets []*wallet.Wallet
+	if address != "" {
+		if wallet.IsAddressStored(address) == false {
+			return errors.New("address is not stored locally")
+		}
+		wallets = append(wallets, wallet.GetWallet(address))
+	} else {
+		for _, w := range wallet.WalletList {
+			wallets = append(wallets, w)
+		}
+	}
+
+	signed, err := signPSBTWithWallets(psbt, wallets)
+	if err != nil {
+		return err
+	}
+	if signed == 0 {
+		return errors.New("any input can be signed by local wallets")
+	}
+	return nil
+}
+
+//Signe la transaction partiellement signée avec chacun des wallets
+//Retourne le nombre de signatures ajoutées
+func signPSBTWithWallets(psbt *twayutil.PSBT, wallets []*wallet.Wallet) (int, error) {
+	if err := wallet.CheckUnlocked(); err != nil {
+		return 0, err
+	}
+	signed := 0
+	for _, w := range wallets {
+		n, err := psbt.Sign(&w.PrivateKey, w.PublicKey)
+		if err != nil {
+			return signed, err
+		}
+		signed += n
+	}
+	return signed, nil
+}
+
+//Combine plusieurs transactions partiellement signées séparées par une virgule
+func combinePSBTs(list string) (*twayutil.PSBT, error) {
+	var psbt *twayutil.PSBT
+	for _, arg := range strings.Split(list, ",") {
+		other, err := readPSBT(strings.TrimSpace(arg))
+		if err != nil {
+			return nil, err
+		}
+		if psbt == nil {
+			psbt = other
+		} else if err := psbt.Combine(other); err != nil {
+			return nil, err
+		}
+	}
+	return psbt, nil
+}
+
+func printPSBT(psbt *twayutil.PSBT) {
+	printTx(&psbt.Tx)
+	fmt.Println("Inputs status:")
+	for idx, in := range psbt.Inputs {
+		got, required := in.SignatureCount()
+		fmt.Printf("    [%d] %s \t value: %d \t signatures: %d/%d\n", idx, script.Script.GetScriptClass(in.ScriptPubKey()), in.Amount(), got, required)
+	}
+	fmt.Println("Complete:", psbt.IsComplete())
+}
+
+func psbtCli() {
+	psbtCMD := flag.NewFlagSet("psbt", flag.ExitOnError)
+	create := psbtCMD.Bool("create", false, "Create a partially signed transaction")
+	sign := psbtCMD.Bool("sign", false, "Sign a partially signed transaction")
+	combine := psbtCMD.Bool("combine", false, "Combine partially signed transactions")
+	finalize := psbtCMD.Bool("finalize", false, "Print the signed transaction")
+	broadcast := psbtCMD.Bool("broadcast", false, "broadcast the signed transaction to the main node")
+	decode := psbtCMD.Bool("decode", false, "Print a partially signed transaction")
+	psbtString := psbtCMD.String("psbt", "", "partially signed transaction at hex format or file path")
+	out := psbtCMD.String("out", "", "output file")
+	utxos := psbtCMD.String("utxos", "", "outputs to spend at txid:vout format, separated by a ,")
+	toString := psbtCMD.String("to", "", "address to send")
+	amount := psbtCMD.Int("amount", 0, "amount to send")
+	fees := psbtCMD.Int("fees", 0, "fees to offer to miner")
+	nSig := psbtCMD.Int("nsig", 0, "Number of signature required to spend a pay to script hash tx")
+	address := psbtCMD.String("address", "", "sign only with the wallet linked with this address")
+	walletName := walletFlag(psbtCMD)
+	handleParsingError(psbtCMD)
+	if selectWallet(*walletName) == false {
+		return
+	}
+
+	if *create && *utxos != "" && *toString != "" && *amount > 0 {
+		to, err := parseRecipient(*toString, *nSig)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		inputs, err := parseOutpoints(*utxos)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		psbt, err := createPSBT(inputs, to, *amount, *fees, *nSig)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		writePSBT(psbt, *out)
+	} else if *combine && *psbtString != "" {
+		psbt, err := combinePSBTs(*psbtString)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		writePSBT(psbt, *out)
+	} else if (*sign || *finalize || *broadcast || *decode) && *psbtString != "" {
+		psbt, err := readPSBT(*psbtString)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if *decode {
+			printPSBT(psbt)
+			return
+		}
+		if *sign {
+			if err := signPSBT(psbt, *address); err != nil {
+				fmt.Println(err)
+				return
+			}
+			writePSBT(psbt, *out)
+			return
+		}
+		tx, err := psbt.Finalize()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		printTx(tx)
+		if *broadcast {
+			broadcastTx(tx)
+		} else {
+			fmt.Println(hex.EncodeToString(tx.Serialize()))
+		}
+	} else {
+		psbtUsage()
+	}
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"tway/script"
+	"tway/util"
+	"tway/wallet"
+)
+
+func scriptUsage() {
+	fmt.Println(" Options:")
+	fmt.Println(" --asm \t Compile a script written in ASM (ex: \"OP_DUP OP_HASH160 <hex> OP_EQUALVERIFY OP_CHECKSIG\") to hex")
+	fmt.Println(" --hex \t Decode an hex script to ASM")
+}
+
+//Affiche les informations d'un script
+func printScript(srpt [][]byte) {
+	encoded, err := script.Script.Serialize(srpt)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	scriptHash, _ := script.Script.Hash(srpt)
+	class := script.Script.GetScriptClass(srpt)
+
+	fmt.Println("asm:", script.Script.String(srpt))
+	fmt.Println("hex:", hex.EncodeToString(encoded))
+	fmt.Println("type:", class)
+	fmt.Println("script hash:", hex.EncodeToString(scriptHash))
+	switch class {
+	case script.PubKeyHashTy:
+		fmt.Println("address:", string(wallet.GetAddressFromPubKeyHash(srpt[2])))
+	case script.PubKeyTy:
+		fmt.Println("address:", string(wallet.GetAddressFromPubKeyHash(util.Ripemd160(util.Sha256(srpt[0])))))
+	}
+	fmt.Println("p2sh address:", string(wallet.GetAddressFromScriptHash(scriptHash)))
+}
+
+func scriptCli() {
+	scriptCMD := flag.NewFlagSet("script", flag.ExitOnError)
+	asm := scriptCMD.String("asm", "", "Script at ASM format to compile")
+	hexScript := scriptCMD.String("hex", "", "Script at hex format to decode")
+	handleParsingError(scriptCMD)
+
+	if *asm != "" {
+		srpt, err := script.Script.Compile(*asm)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		printScript(srpt)
+	} else if *hexScript != "" {
+		data, err := hex.DecodeString(*hexScript)
+		if err != nil {
+			fmt.Println("script is not a valid hex string")
+			return
+		}
+		srpt, err := script.Script.Deserialize(data)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		printScript(srpt)
+	} else {
+		scriptUsage()
+	}
+}
@@ -3,8 +3,10 @@ package cli
 import (
 	"flag"
 	"fmt"
+	"time"
 
 	"tway/server"
+	"tway/wallet"
 )
 
 func ServerUsage() {
@@ -12,6 +14,28 @@ func ServerUsage() {
 	fmt.Println(" --mining \t Enable mining")
 	fmt.Println(" --log-server \t Print server's logs")
 	fmt.Println(" --log-mining \t Print mining's logs")
+	fmt.Println(" --wallet \t name of a loaded wallet to use instead of the default wallet of the node")
+	fmt.Println(" --unlock \t Ask the passphrase of an encrypted wallet and keep its keys decrypted in memory, needed to mine. Works with [--timeout]. wallet --unlock and --lock apply to the running server")
+	fmt.Printf(" --notify-cmd \t command run on wallet events (%%e event, %%t txid, %%a amount, %%c confirmations, %%h height, %%b block hash, %%w wallet name)\n")
+	fmt.Println(" --notify-url \t local URL receiving wallet events as JSON POST requests")
+	fmt.Println(" --notify-retries \t number of retries when a hook fails (default 3)")
+	fmt.Println(" --notify-retry-delay \t seconds before the first retry, doubled on each retry (default 5)")
+	fmt.Println(" --notify-confirmations \t notify each new confirmation of wallet transactions up to this number (default 6)")
+}
+
+//Déverrouille le wallet du serveur, les clés restent dans la mémoire du processus
+func unlockWallet(timeout int) bool {
+	passphrase, err := askPassphrase()
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	if err := wallet.Unlock(passphrase, time.Duration(timeout)*time.Second); err != nil {
+		fmt.Println(err)
+		return false
+	}
+	fmt.Println("wallet unlocked for", timeout, "seconds")
+	return true
 }
 
 func serverCli() {
@@ -21,14 +45,46 @@ func serverCli() {
 	logServer := serverCMD.Bool("log-server", false, "Print logs")
 	logMining := serverCMD.Bool("log-mining", false, "Print mining logs")
 	help := serverCMD.Bool("help", false, "Print usage of server CMD")
+	unlock := serverCMD.Bool("unlock", false, "Decrypt private keys of the wallet")
+	timeout := serverCMD.Int("timeout", 24*3600, "number of seconds before the wallet is locked again")
+	notifyCmd := serverCMD.String("notify-cmd", "", "Command run on wallet events")
+	notifyURL := serverCMD.String("notify-url", "", "URL receiving wallet events")
+	notifyRetries := serverCMD.Int("notify-retries", 3, "Number of retries when a hook fails")
+	notifyRetryDelay := serverCMD.Int("notify-retry-delay", 5, "Seconds before the first retry")
+	notifyConfirmations := serverCMD.Int("notify-confirmations", wallet.NotifyConfirmations, "Number of confirmations notified")
 
+	walletName := walletFlag(serverCMD)
 	handleParsingError(serverCMD)
+	if selectWallet(*walletName) == false {
+		return
+	}
 
 	if *help == true {
 		ServerUsage()
 		return
 	}
 
+	if *notifyCmd != "" || *notifyURL != "" {
+		wallet.NotifyConfirmations = *notifyConfirmations
+		wallet.RegisterHook(&wallet.Hook{
+			Command:    *notifyCmd,
+			URL:        *notifyURL,
+			Retries:    *notifyRetries,
+			RetryDelay: time.Duration(*notifyRetryDelay) * time.Second,
+		})
+	}
+
+	if *unlock && unlockWallet(*timeout) == false {
+		return
+	}
+	//wallet --unlock, --lock et --encrypt s'appliquent aux clés du serveur
+	if err := wallet.ListenControl(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	//les wallets chargés reçoivent aussi les évènements, les hooks les distinguent par leur nom
+	wallet.OpenLoadedWallets()
+
 	s := server.NewServer(*logServer, *mining, *logMining)
 	s.StartServer()
 }
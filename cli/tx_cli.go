@@ -1,16 +1,17 @@
 package cli
 
 import (
-	"crypto/ecdsa"
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"strings"
 	b "tway/blockchain"
 	conf "tway/config"
+	"tway/keys"
 	"tway/script"
-	"tway/server"
 	"tway/twayutil"
 	"tway/util"
 	"tway/wallet"
@@ -24,141 +25,150 @@ func TxCreateUsage() {
 	fmt.Println(" --fees \t number of coins gived to the minor.")
 	fmt.Println(" --from \t get utxos to create the transaction from the address linked with this field.")
 	fmt.Println(" --amount \t amount to send")
+	fmt.Println(" --fee-rate \t number of coins per byte gived to the minor in addition to --fees. Estimated if neither --fees nor --fee-rate is set")
+	fmt.Println(" --conf-target \t number of blocks in which the transaction should be confirmed, used to estimate the fee rate")
+	fmt.Println(" --recipients \t file listing the recipients of a batch payment, CSV (address,amount per line) or JSON ([{\"address\": ..., \"amount\": ...}]). Replaces --to and --amount")
+	fmt.Println(" --utxo \t spend these outpoints of local wallets, at format txid:vout and separated by a ,")
+	fmt.Println(" --uri \t pay a tway: payment request URI. Replaces --to, and --amount if the URI has an amount")
+	fmt.Println(" --replaceable \t signal that the transaction can be replaced with higher fees, see wallet --bumpfee")
+	fmt.Printf(" --coin-select \t strategy used to select UTXOs: %s (default %s)\n", strings.Join(wallet.CoinSelectorNames(), ", "), wallet.DefaultCoinSelector)
+	fmt.Println(" --wallet \t name of a loaded wallet to use instead of the default wallet of the node")
 }
 
 type createTxInfo struct {
 	from    string
 	to      [][]byte
 	amount  int
-	fees    int
+	fees    int //frais fixes, mis à jour avec les frais totaux de la transaction créée
 	nSig    int
 	outputs []twayutil.Output
 	inputs  []twayutil.Input
+	//frais par octet ajoutés aux frais fixes, estimés si négatifs
+	feeRate    int
+	confTarget int
+	//stratégie de sélection des UTXOs, voir wallet.SelectCoins
+	coinSelect string
+	//UTXOs choisis par l'utilisateur, la sélection automatique n'est pas utilisée
+	utxos []wallet.LocalUnspentOutput
+	//la transaction signale qu'elle peut être remplacée
+	replaceable bool
 }
 
-func createTx(ctxInfo createTxInfo) *twayutil.Transaction {
-	var inputs []twayutil.Input
-	var inputsPubKey [][]byte
-	var inputsPrivKey []ecdsa.PrivateKey
-	var outputs []twayutil.Output
-	var localUnspents []wallet.LocalUnspentOutput
+func createTx(ctxInfo *createTxInfo) *twayutil.Transaction {
 	var amountGot int
 
-	from := ctxInfo.from
 	to := ctxInfo.to
 	amount := ctxInfo.amount
-	fees := ctxInfo.fees
 	nSig := ctxInfo.nSig
-	inputs = ctxInfo.inputs
-	outputs = ctxInfo.outputs
+	inputs := ctxInfo.inputs
+	outputs := ctxInfo.outputs
 
-	Walletinfo := wallet.Walletinfo
+	if len(ctxInfo.outputs) == 0 {
+		//on génére l'output vers l'address de notre destinaire
+		out := twayutil.NewTxOutput(script.Script.LockingScript(to, nSig), amount)
+		outputs = append(outputs, out)
+	}
 
 	if len(ctxInfo.inputs) == 0 {
-
-		if from == "" {
-			//on récupère une liste d'output qui totalise le montant a envoyer
-			//on récupère aussi amountGot, qui est le total de la somme de value des outputs
-			//Cette variable est indispensable, car si la valeur total obtenu est supérieur
-			//au montant d'envoie, on doit transferer l'excédant sur le wallet du créateur de la tx
-			var notAcceptedAddr []byte
-			if len(to) == 1 {
-				notAcceptedAddr = wallet.GetAddressFromPubKeyHash(to[0])
-			}
-
-			amountGot, localUnspents = Walletinfo.GetLocalUnspentOutputs(amount+fees, string(notAcceptedAddr))
-		} else {
-			if wallet.IsAddressValid(from) == false {
-				fmt.Println("sender address is not a valid address")
-				return nil
+		feeRate := ctxInfo.feeRate
+		if feeRate < 0 {
+			if ctxInfo.confTarget <= 0 {
+				ctxInfo.confTarget = defaultConfTarget
 			}
-			amountGot, localUnspents = wallet.GetLocalUnspentOutputsByPubKeyHash(wallet.GetPubKeyHashFromAddress([]byte(from)), amount+fees)
+			feeRate = estimateFeeRate(ctxInfo.confTarget)
 		}
-
-		//Si le montant d'envoie est inférieur au total des wallets locaux
-		if (from == "" && (amount+fees) > Walletinfo.Amount) || (from != "" && (amount+fees) > amountGot) {
-			log.Println("You don't have enough coin to perform this transaction.")
-			return nil
-		}
-
-		//Pour chaque output non dépensé
-		for _, localUs := range localUnspents {
-			if localUs.AmountLockedByMultiSig > 0 {
-				continue
-			}
-			var emptyScript [][]byte
-			//on génère un input à partir de l'output
-			input := twayutil.NewTxInput(localUs.TxID, util.EncodeInt(localUs.Idx), emptyScript)
-			//et on l'ajoute à la liste
-			inputs = append(inputs, input)
-			//on ajoute dans un tableau de string la clé publique correspondant
-			//au wallet proprietaire de l'output permettant la création de
-			//cette input.
-			inputsPubKey = append(inputsPubKey, localUs.W.PublicKey)
-			//on ajoute dans un tableau de clé privée la clé privée correspondant
-			//au wallet proprietaire de l'output permettant la signature de
-			//cette input.
-			//Ce tableau de clé privée permettra de signer chaque input.
-			inputsPrivKey = append(inputsPrivKey, localUs.W.PrivateKey)
-		}
-
-	} else {
-		for _, in := range inputs {
-			uo := b.UTXO.GetUnSpentOutputByVoutAndTxHash(util.DecodeInt(in.Vout), in.PrevTransactionHash)
-			if uo == nil {
-				log.Println("Wrong inputs")
-				return nil
-			}
-			amountGot += util.DecodeInt(uo.Output.Value)
+		opts := wallet.FundOptions{From: ctxInfo.from, Fees: ctxInfo.fees, FeeRate: feeRate, CoinSelect: ctxInfo.coinSelect, UTXOs: ctxInfo.utxos, Replaceable: ctxInfo.replaceable}
+		//les UTXOs de l'adresse du destinataire ne sont pas dépensés
+		if len(to) == 1 {
+			opts.Exclude = []string{string(wallet.GetAddressFromPubKeyHash(to[0]))}
 		}
-		if amountGot > amount {
-			fees = amount - amountGot
-		} else if amount > amountGot {
-			log.Println("You don't have enough coin to perform this transaction.")
+		tx, selection, err := wallet.FundTransaction(outputs, opts)
+		if err != nil {
+			log.Println(err)
 			return nil
 		}
+		//les frais incluent la part proportionnelle à la taille de la transaction
+		//et l'excédent trop faible pour créer un rendu
+		ctxInfo.fees = selection.Fee
+		return tx
 	}
 
-	if len(ctxInfo.outputs) == 0 {
-		//on génére l'output vers l'address de notre destinaire
-		out := twayutil.NewTxOutput(script.Script.LockingScript(to, nSig), amount)
-		outputs = append(outputs, out)
+	for _, in := range inputs {
+		uo := b.UTXO.GetUnSpentOutputByVoutAndTxHash(util.DecodeInt(in.Vout), in.PrevTransactionHash)
+		if uo == nil {
+			log.Println("Wrong inputs")
+			return nil
+		}
+		amountGot += util.DecodeInt(uo.Output.Value)
 	}
-
-	//Si le montant récupére par les wallets locaux est supérieur
-	//au montant que l'on décide d'envoyer
-	if amountGot > (amount+fees) && len(ctxInfo.inputs) == 0 && len(ctxInfo.outputs) == 0 {
-		//on utilise la clé public du dernier output ajouté à la liste
-		fromPubKeyHash := [][]byte{wallet.HashPubKey(localUnspents[len(localUnspents)-1].W.PublicKey)}
-		//on génére un output vers le dernier output de la liste d'utxo récupéré
-		//et on envoie l'excédant
-		exc := twayutil.NewTxOutput(script.Script.LockingScript(fromPubKeyHash, nSig), amountGot-(amount+fees))
-		outputs = append(outputs, exc)
+	if amount > amountGot {
+		log.Println("You don't have enough coin to perform this transaction.")
+		return nil
 	}
 
-	tx := &twayutil.Transaction{
+	return &twayutil.Transaction{
 		Version:    []byte{conf.VERSION},
 		InCounter:  util.EncodeInt(len(inputs)),
 		Inputs:     inputs,
 		OutCounter: util.EncodeInt(len(outputs)),
 		Outputs:    outputs,
 	}
+}
 
-	if len(ctxInfo.inputs) > 0 {
-		return tx
+//Récupère les UTXOs de wallets locaux d'une liste d'outpoints séparés par une virgule
+func parseLocalOutpoints(list string) ([]wallet.LocalUnspentOutput, error) {
+	var utxos []wallet.LocalUnspentOutput
+	if list == "" {
+		return utxos, nil
+	}
+	seen := make(map[string]bool)
+	for _, op := range strings.Split(list, ",") {
+		txID, vout, err := wallet.ParseOutpoint(op)
+		if err != nil {
+			return nil, err
+		}
+		if seen[strings.TrimSpace(op)] {
+			return nil, fmt.Errorf("outpoint %s is used twice", op)
+		}
+		seen[strings.TrimSpace(op)] = true
+		us, err := wallet.GetLocalUnspentOutput(txID, vout)
+		if err != nil {
+			return nil, err
+		}
+		utxos = append(utxos, *us)
 	}
+	return utxos, nil
+}
 
-	prevTXs := make(map[string]*util.Transaction)
-	//on récupère la liste des transactions précédant
-	//la liste des inputs de la tx
-	for _, in := range tx.Inputs {
-		prevTx, _, _ := b.GetTxByHash(in.PrevTransactionHash)
-		txid := hex.EncodeToString(prevTx.GetHash())
-		prevTXs[txid] = prevTx.ToTxUtil()
+//Parse le destinataire d'une transaction : une adresse (PayToPubKeyHash)
+//ou une liste de clés publiques séparées par une virgule (Multisig)
+func parseRecipient(toString string, nSig int) ([][]byte, error) {
+	var to [][]byte
+	//si il y a plusieurs clé publique
+	if strings.Contains(toString, ",") {
+		toString = strings.Replace(toString, " ", "", -1)
+		for _, pkString := range strings.Split(toString, ",") {
+			pkBytes, _ := hex.DecodeString(pkString)
+			if keys.IsPubKey(pkBytes) == false {
+				return nil, fmt.Errorf("%s is not a valid public key", pkString)
+			}
+			to = append(to, pkBytes)
+		}
+		if nSig == 0 {
+			return nil, errors.New("\n/!\\ You must use --nsig parameter")
+		}
+		//si il y a une addresse
+	} else if toString != "" {
+		addr, err := wallet.DecodeAddress(toString)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid address: %s", toString, err)
+		}
+		if addr.Type != wallet.PubKeyHashAddress {
+			return nil, fmt.Errorf("%s is not a pay to public key hash address", toString)
+		}
+		to = append(to, addr.Hash)
 	}
-	//on signe la transaction
-	tx.Sign(prevTXs, inputsPrivKey, inputsPubKey)
-	return tx
+	return to, nil
 }
 
 func TxCreateCli() {
@@ -182,7 +192,26 @@ func TxCreateCli() {
 	inputsString := TxCMD.String("inputs", "", "Inputs manually created at hex format.")
 	//Si spécifié, la transaction est envoyé au noeud principal qui la relaiera ensuite a tout le réseau
 	broadcast := TxCMD.Bool("broadcast", false, "broadcast transaction to the main node")
+	//Frais par octet, calculés à partir de la taille estimée de la transaction
+	//Par défaut, le taux est estimé à partir des transactions confirmées récemment
+	feeRate := TxCMD.Int("fee-rate", -1, "fees per byte to offer to miner")
+	confTarget := TxCMD.Int("conf-target", defaultConfTarget, "number of blocks in which the transaction should be confirmed")
+	//Stratégie de sélection des UTXOs
+	coinSelect := TxCMD.String("coin-select", wallet.DefaultCoinSelector, "UTXOs selection strategy")
+	//Outpoints à dépenser, séparés par une virgule
+	//Exemple : "TXID_1:0,TXID_2:1"
+	utxoString := TxCMD.String("utxo", "", "outpoints to spend at format txid:vout")
+	//Fichier CSV ou JSON listant les destinataires d'un paiement groupé
+	recipientsFile := TxCMD.String("recipients", "", "CSV or JSON file of recipients")
+	//La transaction pourra être remplacée par une transaction payant plus de frais
+	replaceable := TxCMD.Bool("replaceable", false, "signal that the transaction can be replaced")
+	//URI de paiement tway:<address>?amount=…&label=…&message=…
+	uriString := TxCMD.String("uri", "", "payment request URI to pay")
+	walletName := walletFlag(TxCMD)
 	handleParsingError(TxCMD)
+	if selectWallet(*walletName) == false {
+		return
+	}
 
 	var txInputs []twayutil.Input
 	if *inputsString != "" {
@@ -201,24 +230,64 @@ func TxCreateCli() {
 		}
 	}
 
-	var to [][]byte
-	//si il y a plusieurs clé publique
-	if strings.Contains(*toString, ",") {
-		*toString = strings.Replace(*toString, " ", "", -1)
-		for _, pkString := range strings.Split(*toString, ",") {
-			pkBytes, _ := hex.DecodeString(pkString)
-			to = append(to, pkBytes)
+	var paymentURI *wallet.PaymentURI
+	if *uriString != "" {
+		if *toString != "" || *recipientsFile != "" {
+			fmt.Println("--uri can't be used with --to or --recipients")
+			return
 		}
-		if *nSig == 0 {
-			fmt.Println("\n/!\\ You must use --nsig parameter")
+		var err error
+		if paymentURI, err = wallet.DecodeURI(*uriString); err != nil {
+			fmt.Println(err)
 			return
 		}
-		//si il y a une addresse
-	} else if *toString != "" {
-		to = append(to, wallet.GetPubKeyHashFromAddress([]byte(*toString)))
+		if paymentURI.Amount > 0 {
+			if *amount > 0 && *amount != paymentURI.Amount {
+				fmt.Println("--amount doesn't match the amount of the payment request")
+				return
+			}
+			*amount = paymentURI.Amount
+		}
+		*toString = paymentURI.Address
 	}
-	if len(to) > 0 && *amount > 0 {
-		ctxInfo := createTxInfo{*from, to, *amount, *fees, *nSig, []twayutil.Output{}, txInputs}
+
+	to, err := parseRecipient(*toString, *nSig)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	outpoints, err := parseLocalOutpoints(*utxoString)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	//des frais fixes désactivent l'estimation
+	if *fees > 0 && *feeRate < 0 {
+		*feeRate = 0
+	}
+	var outputs []twayutil.Output
+	if *recipientsFile != "" {
+		if len(to) > 0 || len(txInputs) > 0 {
+			fmt.Println("--recipients can't be used with --to or --inputs")
+			return
+		}
+		data, err := ioutil.ReadFile(*recipientsFile)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		recipients, err := wallet.ParseRecipients(data)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if outputs, *amount, err = wallet.RecipientOutputs(recipients); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	if (len(to) > 0 || len(outputs) > 0) && *amount > 0 {
+		ctxInfo := &createTxInfo{from: *from, to: to, amount: *amount, fees: *fees, nSig: *nSig, outputs: outputs, inputs: txInputs, feeRate: *feeRate, confTarget: *confTarget, coinSelect: *coinSelect, utxos: outpoints, replaceable: *replaceable}
 		tx := createTx(ctxInfo)
 
 		if tx == nil {
@@ -228,11 +297,20 @@ func TxCreateCli() {
 		printTx(tx)
 		//on mine un nouveau block localement
 		if *broadcast == false {
-			NewBlock([]twayutil.Transaction{*tx}, *fees)
-		} else {
-			//on l'envoie au main node qui la diffusera ensuite a tout le reseau
-			s := server.NewServer(false, false, false)
-			s.SendTx(server.GetMainNode(), tx)
+			NewBlock([]twayutil.Transaction{*tx}, ctxInfo.fees)
+		} else if broadcastTx(tx) == false {
+			//l'envoi au main node a échoué, rien n'est conservé dans l'historique
+			return
+		}
+		//le message de la demande de paiement est conservé dans l'historique
+		if paymentURI != nil {
+			memo := paymentURI.Message
+			if memo == "" {
+				memo = paymentURI.Label
+			}
+			if memo != "" {
+				wallet.SetTxMemo(hex.EncodeToString(tx.GetHash()), memo)
+			}
 		}
 	} else {
 		TxCreateUsage()
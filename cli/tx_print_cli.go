@@ -1,8 +1,6 @@
 package cli
 
 import (
-	"crypto/ecdsa"
-	"crypto/rand"
 	"encoding/hex"
 	"flag"
 	"fmt"
@@ -21,6 +19,7 @@ func TxPrintUsage() {
 	fmt.Println(" --address \t select a wallet linked with this address")
 	fmt.Println("Others cmds starting by tx :")
 	fmt.Println("\t tx_reate")
+	fmt.Println(" --wallet \t name of a loaded wallet to use instead of the default wallet of the node")
 }
 
 func printTxBlockchain(tx *twayutil.Transaction, block *twayutil.Block, height int) {
@@ -76,7 +75,11 @@ func TxPrintCli() {
 	hash := TxCMD.String("hash", "", "Print tx if exist")
 	sign := TxCMD.String("sign", "", "Sign a transaction by its txid")
 	address := TxCMD.String("address", "", "Select a wallet linked by address")
+	walletName := walletFlag(TxCMD)
 	handleParsingError(TxCMD)
+	if selectWallet(*walletName) == false {
+		return
+	}
 
 	if *hash != "" {
 		h, _ := hex.DecodeString(*hash)
@@ -87,14 +90,21 @@ func TxPrintCli() {
 	} else if *sign != "" && *address != "" {
 		h, _ := hex.DecodeString(*sign)
 		tx, _, _ := b.GetTxByHash(h)
-		w := wallet.WalletList[*address]
-		r, s, err := ecdsa.Sign(rand.Reader, &w.PrivateKey, tx.ToTxUtil().Serialize())
+		if err := wallet.CheckUnlocked(); err != nil {
+			fmt.Println(err)
+			return
+		}
+		w := wallet.GetWallet(*address)
+		if w == nil {
+			fmt.Println("address is not stored locally")
+			return
+		}
+		signature, err := util.Sign(&w.PrivateKey, util.Sha256(tx.ToTxUtil().Serialize()))
 		if err != nil {
 			fmt.Println(err)
 			log.Panic(err)
 		}
 
-		signature := append(r.Bytes(), s.Bytes()...)
 		fmt.Println("signature:", hex.EncodeToString(signature))
 
 	} else {
@@ -1,9 +1,16 @@
 package cli
 
 import (
+	"bufio"
+	"errors"
 	"flag"
-	"os"
+	"fmt"
 	"log"
+	"os"
+	"strings"
+	"tway/wallet"
+
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 func handleParsingError(set *flag.FlagSet) {
@@ -12,4 +19,58 @@ func handleParsingError(set *flag.FlagSet) {
 		log.Panic(err)
 		os.Exit(2)
 	}
-}
\ No newline at end of file
+}
+
+// Ajoute l'option --wallet sélectionnant le wallet utilisé par la commande
+func walletFlag(set *flag.FlagSet) *string {
+	return set.String("wallet", wallet.DefaultWalletName, "name of a loaded wallet to use instead of the default wallet of the node")
+}
+
+// Sélectionne le wallet de l'option --wallet
+// Retourne false si le wallet n'existe pas ou n'est pas chargé
+func selectWallet(name string) bool {
+	if err := wallet.SelectWallet(name); err != nil {
+		fmt.Println(err)
+		return false
+	}
+	return true
+}
+
+// Demande une passphrase sans l'afficher
+// Si l'entrée standard n'est pas un terminal, la passphrase est lue sur la première ligne
+func readPassphrase(prompt string) (string, error) {
+	fmt.Print(prompt)
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		passphrase, err := terminal.ReadPassword(fd)
+		fmt.Println()
+		return string(passphrase), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Println()
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Demande la passphrase du wallet lorsqu'une commande a besoin de ses clés privées
+func askPassphrase() (string, error) {
+	return readPassphrase("Passphrase: ")
+}
+
+// Demande une nouvelle passphrase deux fois
+func readNewPassphrase() (string, error) {
+	passphrase, err := readPassphrase("New passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	confirm, err := readPassphrase("Confirm passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase != confirm {
+		return "", errors.New("passphrases don't match")
+	}
+	return passphrase, nil
+}
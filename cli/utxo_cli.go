@@ -19,6 +19,7 @@ func utxoUsage(){
 	fmt.Println("	--mine		Print all UTXOs linked with local wallets")
 	fmt.Println("	--printTX	Print tx linked with each UTXO")
 	fmt.Println("	--txid		Print UTXOs linked with a txID")
+	fmt.Println("	--wallet	Name of a loaded wallet to use instead of the default wallet")
 }
 
 func printAll(printTX bool){
@@ -43,7 +44,7 @@ func printAll(printTX bool){
 }
 
 func printMine(printTX bool){
-	Walletinfo := wallet.Walletinfo
+	Walletinfo := wallet.GetWalletInfo()
 
 	_, localUTXO := Walletinfo.GetLocalUnspentOutputs(conf.MAX_COIN, "")		
 	slice.Sort(localUTXO[:], func(i, j int) bool {
@@ -114,7 +115,11 @@ func UTXOCli(){
 	printTX := utxoCMD.Bool("printTX", false, "Print tx linked with an utxo")
 	check := utxoCMD.Bool("check", false, "return true if utxos are well indexed")
 
+	walletName := walletFlag(utxoCMD)
 	handleParsingError(utxoCMD)
+	if selectWallet(*walletName) == false {
+		return
+	}
 	if *all == true {
 		printAll(*printTX)
 	} else if *mine == true {
@@ -5,6 +5,14 @@ import (
 	"encoding/hex"
 	"flag"
 	"fmt"
+	"strings"
+	"time"
+	b "tway/blockchain"
+	conf "tway/config"
+	"tway/script"
+	"tway/server"
+	"tway/twayutil"
+	"tway/util"
 	"tway/wallet"
 
 	"github.com/bradfitz/slice"
@@ -13,44 +21,420 @@ import (
 func walletUsage() {
 	fmt.Println(" Options:")
 	fmt.Println("	--new					Generate a new wallet")
+	fmt.Println("	--create-wallet 		Create and load a named wallet in the node's data directory")
+	fmt.Println("	--load-wallet 			Load a named wallet: commands can select it with --wallet and the node server keeps its history up to date")
+	fmt.Println("	--unload-wallet 		Unload a named wallet, its files are kept")
+	fmt.Println("	--list-wallets 			Print named wallets of the node")
+	fmt.Println("	--wallet 				Name of a loaded wallet to use instead of the default wallet of the node")
 	fmt.Println("	--list					Print list of local wallets")
 	fmt.Println("	--total 				Print total amount available in local wallets")
 	fmt.Println("	--pubkeyhash-to-addr 	Print addr from a public key hashed")
+	fmt.Println("	--bech32 				Print addresses at bech32 format. Works with --list, --new and --pubkeyhash-to-addr")
+	fmt.Println("	--convert-address 		Print an address at base58 and bech32 formats")
+	fmt.Println("	--hd-new 				Create a HD seed, new addresses will be derived from it")
+	fmt.Println("	--hd-restore 			Restore a HD seed from its mnemonic and rescan the blockchain")
+	fmt.Println("	--hd-mnemonic 			Print the mnemonic of the HD seed")
+	fmt.Println("	--rescan 				Recover used addresses of the HD seed. Works with [--gap]")
+	fmt.Println("	--encrypt 				Encrypt private keys with a passphrase")
+	fmt.Println("	--unlock 				Decrypt private keys in the running server for a while. Works with [--timeout]")
+	fmt.Println("	--lock 					Forget the private keys decrypted by the running server")
+	fmt.Println("	--history 				Print wallet transactions. Works with [--direction] [--address] [--min-conf] [--limit] [--txid]")
+	fmt.Println("	--memo 					Attach a memo to a wallet transaction. Works with --txid")
+	fmt.Println("	--lock-unspent 			Reserve outpoints (txid:vout separated by a ,), coin selection won't use them")
+	fmt.Println("	--unlock-unspent 		Release reserved outpoints")
+	fmt.Println("	--list-locked 			Print reserved outpoints")
+	fmt.Println("	--abandon 				Forget an unconfirmed transaction so its inputs can be spent again. Works with --txid")
+	fmt.Println("	--bumpfee 				Replace an unconfirmed replaceable transaction with a higher fee rate. Works with [--fee-rate] [--conf-target] [--broadcast]")
+	fmt.Println("	--rebuild-history 		Rebuild wallet transactions from the blockchain. Works with [--from]")
+	fmt.Println("	--export-key 			Print the encoded private key of --address")
+	fmt.Println("	--request 				Create a payment request to a new address and print its URI. Works with [--amount] [--label] [--message]")
+	fmt.Println("	--requests 				Print payment requests and their status: pending, seen unconfirmed or paid")
+	fmt.Println("	--signmessage 			Sign a message with the private key of --address to prove its ownership. Works with --message")
+	fmt.Println("	--verifymessage 		Verify a message signed by --address. Works with --signature --message")
+	fmt.Println("	--import-key 			Add an encoded private key to the wallet. Works with [--from] [--no-rescan]")
+	fmt.Println("	--sweep 				Send all coins of an encoded private key to an unused wallet address. Works with [--fees] [--fee-rate] [--conf-target] [--broadcast]")
+}
+
+func encryptWallet() {
+	passphrase, err := readNewPassphrase()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	//le serveur du noeud chiffre les clés qu'il garde en mémoire
+	err = wallet.EncryptServer(passphrase)
+	if err == wallet.ErrNoServer {
+		err = wallet.EncryptWallet(passphrase)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("wallet encrypted and locked")
+}
+
+//Déverrouille le wallet du serveur du noeud, les commandes demandent
+//la passphrase lorsqu'elles ont besoin d'une clé privée
+func unlockServer(timeout int) {
+	passphrase, err := askPassphrase()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := wallet.UnlockServer(passphrase, time.Duration(timeout)*time.Second); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("wallet unlocked for", timeout, "seconds")
+}
+
+func initHD(mnemonic string) bool {
+	mnemonic, err := wallet.InitHD(mnemonic)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	fmt.Println("mnemonic:", mnemonic)
+	fmt.Println("Write down these words, they are the only backup needed to recover your addresses.")
+	return true
+}
+
+func rescanHD(gap int) {
+	added, err := wallet.RescanHD(gap)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(added, "addresses recovered")
 }
 
 //Afficher les adresses du wallet
-func PrintAddressStored(pubkey bool, privkey bool) {
-	wsList := wallet.Walletinfo.Ws
+func PrintAddressStored(pubkey bool, privkey bool, bech32 bool) {
+	if privkey {
+		if err := wallet.CheckUnlocked(); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	wsList := wallet.GetWalletInfo().Ws
 
 	slice.Sort(wsList[:], func(i, j int) bool {
 		return bytes.Compare(wsList[i].Address, wsList[j].Address) < 0
 	})
 
 	for _, ws := range wsList {
-		fmt.Print(string(ws.W.GetAddress()), "\t", ws.Amount)
+		addr := string(ws.W.GetAddress())
+		if bech32 {
+			addr = ws.W.GetBech32Address()
+		}
+		fmt.Print(addr, "\t", ws.Amount)
 		if ws.AmountLockedByMultiSig != 0 {
 			fmt.Print("\t", ws.AmountLockedByMultiSig)
 		}
+		if ws.Unconfirmed != 0 {
+			fmt.Print("\tunconfirmed: ", ws.Unconfirmed)
+		}
+		if ws.Immature != 0 {
+			fmt.Print("\timmature: ", ws.Immature)
+		}
 		if pubkey || privkey {
 			fmt.Println()
 		}
 		if pubkey {
 			fmt.Println("Public key:", hex.EncodeToString(ws.W.PublicKey))
+			if ws.W.HDPath != "" {
+				fmt.Println("HD path:", ws.W.HDPath)
+			}
+			if ws.W.Change {
+				fmt.Println("Change address")
+			}
 		}
 		if privkey {
-			fmt.Println("Private key:", hex.EncodeToString(ws.W.PrivateKey.D.Bytes()))
+			fmt.Println("Private key:", wallet.EncodePrivateKey(ws.W))
 		}
 		fmt.Print("\n")
 	}
 }
 
+func importKey(encoded string, from int, rescan bool) {
+	addr, err := wallet.ImportKey(encoded)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("address:", addr)
+	if rescan {
+		fmt.Println(wallet.RebuildHistory(from), "wallet transactions found")
+	}
+}
+
+//Créer une transaction envoyant tous les UTXOs d'une clé privée externe
+//vers une nouvelle adresse du wallet
+func sweepKey(encoded string, fees, feeRate, confTarget int) (*twayutil.Transaction, int) {
+	w, err := wallet.DecodePrivateKey(encoded)
+	if err != nil {
+		fmt.Println(err)
+		return nil, 0
+	}
+	priv, pubKey := &w.PrivateKey, w.PublicKey
+	pubKeyHash := wallet.HashPubKey(pubKey)
+	if wallet.IsAddressStored(string(wallet.GetAddressFromPubKeyHash(pubKeyHash))) {
+		fmt.Println("private key is already stored in the wallet")
+		return nil, 0
+	}
+
+	//les outputs multisig et HTLC nécessitent un autre scriptSig
+	var unspents []b.UnspentOutput
+	var total int
+	_, list := b.UTXO.GetUnspentOutputsByPubKOrPubKH([][]byte{pubKeyHash, pubKey}, conf.MAX_COIN)
+	for _, us := range list {
+		if us.MultiSig == false && us.HTLC == false {
+			unspents = append(unspents, us)
+			total += util.DecodeInt(us.Output.Value)
+		}
+	}
+	if len(unspents) == 0 {
+		fmt.Println("any coin to sweep")
+		return nil, 0
+	}
+
+	if feeRate < 0 {
+		feeRate = estimateFeeRate(confTarget)
+	}
+	//la transaction n'a pas de rendu, son unique output P2PKH a la taille
+	//d'un output verrouillé avec un pubKeyHash quelconque
+	model := wallet.NewCostModel(0, fees, feeRate, [][][]byte{script.Script.LockingScript([][]byte{make([]byte, conf.PubKeyHLength)}, 0)})
+	fees = model.Target() + len(unspents)*model.InputCost()
+	if fees >= total {
+		fmt.Println("fees are higher than the amount to sweep")
+		return nil, 0
+	}
+
+	//les coins sont envoyés vers une adresse de rendu inutilisée, aucun index HD
+	//n'est consommé si la transaction n'est pas diffusée
+	changeW, err := wallet.GetChangeWallet()
+	if err != nil {
+		fmt.Println(err)
+		return nil, 0
+	}
+	var emptyScript [][]byte
+	var inputs []twayutil.Input
+	prevTXs := make(map[string]*util.Transaction)
+	for _, us := range unspents {
+		inputs = append(inputs, twayutil.NewTxInput(us.TxID, util.EncodeInt(us.Idx), emptyScript))
+		prevTx, _, height := b.GetTxByHash(us.TxID)
+		if height == -1 {
+			fmt.Println("transaction", hex.EncodeToString(us.TxID), "not found")
+			return nil, 0
+		}
+		prevTXs[hex.EncodeToString(us.TxID)] = prevTx.ToTxUtil()
+	}
+	toPubKeyH := wallet.HashPubKey(changeW.PublicKey)
+	output := twayutil.NewTxOutput(script.Script.LockingScript([][]byte{toPubKeyH}, 0), total-fees)
+	tx := &twayutil.Transaction{
+		Version:    []byte{conf.VERSION},
+		InCounter:  util.EncodeInt(len(inputs)),
+		Inputs:     inputs,
+		OutCounter: util.EncodeInt(1),
+		Outputs:    []twayutil.Output{output},
+		LockTime:   util.EncodeInt(0),
+	}
+
+	for idx, us := range unspents {
+		signature, err := tx.SignInput(prevTXs, idx, priv)
+		if err != nil {
+			fmt.Println(err)
+			return nil, 0
+		}
+		//les récompenses de minage sont verrouillées par la clé publique
+		unlocking := script.Script.UnlockingScript(signature, pubKey)
+		if script.Script.GetScriptClass(us.Output.ScriptPubKey) == script.PubKeyTy {
+			unlocking = script.Script.CoinbaseUnlockingScript(signature)
+		}
+		tx.Inputs[idx] = twayutil.NewTxInput(us.TxID, util.EncodeInt(us.Idx), unlocking)
+	}
+	fmt.Println(total-fees, "coins swept to", string(changeW.GetAddress()))
+	return tx, fees
+}
+
+//Créer une demande de paiement et affiche son URI
+func createPaymentRequest(amount int, label, message string) {
+	if amount < 0 {
+		fmt.Println("--amount must be positive")
+		return
+	}
+	r, err := wallet.NewPaymentRequest(amount, label, message)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("address:", r.Address)
+	fmt.Println("uri:", wallet.EncodeURI(r.PaymentURI))
+}
+
+func printPaymentRequests() {
+	list := wallet.ListPaymentRequests()
+	if len(list) == 0 {
+		fmt.Println("no payment request")
+		return
+	}
+	for _, r := range list {
+		fmt.Printf("%s\t%s\tseen: %d, paid: %d/%d\n", r.Address, r.Status(), r.Seen(), r.Paid(), r.Amount)
+		fmt.Println("    uri:", wallet.EncodeURI(r.PaymentURI))
+		fmt.Println("    created:", time.Unix(r.Created, 0).Format("2006-01-02 15:04:05"))
+		for txID, p := range r.Payments {
+			fmt.Printf("    payment: %s %d (%d confirmations)\n", txID, p.Amount, p.Confirmations())
+		}
+		if r.IsPaid() {
+			fmt.Println("    paid by:", r.PaidBy, "at", time.Unix(r.PaidAt, 0).Format("2006-01-02 15:04:05"))
+		}
+	}
+}
+
+//Créer, charge, décharge ou liste les wallets nommés du noeud
+func manageWallets(create, load, unload string, list bool) {
+	var err error
+	switch {
+	case create != "":
+		if err = wallet.CreateWallet(create); err == nil {
+			fmt.Println("wallet", create, "created, select it with --wallet", create)
+		}
+	case load != "":
+		if err = wallet.LoadWallet(load); err == nil {
+			fmt.Println("wallet", load, "loaded")
+		}
+	case unload != "":
+		if err = wallet.UnloadWallet(unload); err == nil {
+			fmt.Println("wallet", unload, "unloaded")
+		}
+	case list:
+		var wallets []wallet.NamedWallet
+		if wallets, err = wallet.ListWallets(); err == nil {
+			if len(wallets) == 0 {
+				fmt.Println("no named wallet")
+			}
+			for _, w := range wallets {
+				if w.Loaded {
+					fmt.Println(w.Name, "\tloaded")
+				} else {
+					fmt.Println(w.Name, "\tunloaded")
+				}
+			}
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+//Accélère la confirmation d'une transaction non confirmée du wallet
+//en la remplaçant par une transaction payant plus de frais
+func bumpFee(txID string, feeRate int, broadcast bool) {
+	tx, selection, err := wallet.BumpFee(txID, feeRate)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	printTx(tx)
+	fmt.Println("replacement pays", selection.Fee, "coins of fees")
+	if broadcast {
+		s := server.NewServer(false, false, false)
+		if _, err := s.SendTx(server.GetMainNode(), tx); err != nil {
+			fmt.Println(err)
+			return
+		}
+		wallet.ReplacePendingTx(txID, tx)
+	} else {
+		NewBlock([]twayutil.Transaction{*tx}, selection.Fee)
+	}
+}
+
+//Réserve puis libère les outpoints des listes passées en paramètre
+func updateLockedUnspents(lockList, unlockList string) {
+	for _, action := range []struct {
+		list   string
+		update func([]byte, int) error
+	}{{lockList, wallet.LockUnspent}, {unlockList, wallet.UnlockUnspent}} {
+		if action.list == "" {
+			continue
+		}
+		for _, op := range strings.Split(action.list, ",") {
+			txID, vout, err := wallet.ParseOutpoint(op)
+			if err == nil {
+				err = action.update(txID, vout)
+			}
+			if err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
+
+//Afficher l'historique des transactions du wallet
+func printHistory(direction, address string, minConf, limit int, txid string) {
+	if direction != "" && direction != wallet.TxReceived && direction != wallet.TxSent && direction != wallet.TxSelf {
+		fmt.Println("--direction must be", wallet.TxReceived, "or", wallet.TxSent, "or", wallet.TxSelf)
+		return
+	}
+	printed := 0
+	for _, wtx := range wallet.GetHistory() {
+		if limit > 0 && printed >= limit {
+			break
+		}
+		if (direction != "" && wtx.Direction != direction) ||
+			(address != "" && wtx.HasAddress(address) == false) ||
+			(txid != "" && hex.EncodeToString(wtx.TxID) != txid) ||
+			wtx.Confirmations() < minConf {
+			continue
+		}
+		kind := wtx.Direction
+		if wtx.Coinbase {
+			kind = "mined"
+		}
+		if wtx.WatchOnly {
+			kind += " (watch-only)"
+		}
+		fmt.Printf("%x\t%s\t%d\tfee: %d\tconfirmations: %d\n", wtx.TxID, kind, wtx.Amount, wtx.Fee, wtx.Confirmations())
+		if wtx.Height > -1 {
+			fmt.Println("    block:", wtx.Height)
+		}
+		fmt.Println("    time:", time.Unix(wtx.Time, 0).Format("2006-01-02 15:04:05"))
+		if len(wtx.Counterparties) > 0 {
+			fmt.Println("    counterparties:", strings.Join(wtx.Counterparties, ", "))
+		}
+		if len(wtx.Addresses) > 0 {
+			fmt.Println("    local addresses:", strings.Join(wtx.Addresses, ", "))
+		}
+		if wtx.Memo != "" {
+			fmt.Println("    memo:", wtx.Memo)
+		}
+		if wtx.ReplacedBy != "" {
+			fmt.Println("    replaced by:", wtx.ReplacedBy)
+		}
+		printed++
+	}
+	if printed == 0 {
+		fmt.Println("no transaction found")
+	}
+}
+
 func PrintTotalAmountAvailable() {
-	wsList := wallet.Walletinfo.Ws
+	info := wallet.GetWalletInfo()
+	wsList := info.Ws
 	var total int
 	for _, ws := range wsList {
 		total += ws.Amount
 	}
 	fmt.Println(total, "coins are free to spend")
+	if info.UnconfirmedAmount > 0 {
+		fmt.Println(info.UnconfirmedAmount, "coins are waiting for confirmation")
+	}
+	if info.ImmatureAmount > 0 {
+		fmt.Println(info.ImmatureAmount, "coins are immature mining rewards")
+	}
+	if len(info.WatchOnly) > 0 {
+		fmt.Println(info.WatchOnlyAmount, "coins are watch-only")
+	}
 }
 
 func walletCli() {
@@ -61,26 +445,258 @@ func walletCli() {
 	pubkey := walletCMD.Bool("pubkey", false, "Print public key of each wallet stored. Only works with --list")
 	privkey := walletCMD.Bool("privkey", false, "Print private key of each wallet stored. Only works with --list")
 	pubkeyHToAddr := walletCMD.String("pubkeyhash-to-addr", "", "convert a pubKeyHash to an address")
+	bech32 := walletCMD.Bool("bech32", false, "print addresses at bech32 format. Works with --list, --new and --pubkeyhash-to-addr")
+	convertAddress := walletCMD.String("convert-address", "", "print an address at base58 and bech32 formats")
+	hdNew := walletCMD.Bool("hd-new", false, "Create a HD seed")
+	hdRestore := walletCMD.String("hd-restore", "", "mnemonic of the HD seed to restore")
+	hdMnemonic := walletCMD.Bool("hd-mnemonic", false, "Print the mnemonic of the HD seed")
+	rescan := walletCMD.Bool("rescan", false, "Recover used addresses of the HD seed")
+	gap := walletCMD.Int("gap", wallet.HDGapLimit, "number of consecutive unused addresses before stopping the rescan")
+	encrypt := walletCMD.Bool("encrypt", false, "Encrypt private keys with a passphrase")
+	unlock := walletCMD.Bool("unlock", false, "Decrypt private keys in the running server")
+	timeout := walletCMD.Int("timeout", 300, "number of seconds before the wallet is locked again")
+	lock := walletCMD.Bool("lock", false, "Lock the wallet of the running server")
+	history := walletCMD.Bool("history", false, "Print wallet transactions")
+	direction := walletCMD.String("direction", "", "only print transactions received, sent or self")
+	address := walletCMD.String("address", "", "only print transactions involving this address")
+	minConf := walletCMD.Int("min-conf", 0, "only print transactions with at least this number of confirmations")
+	limit := walletCMD.Int("limit", 0, "maximum number of transactions to print, 0 for all")
+	txid := walletCMD.String("txid", "", "hash of a wallet transaction")
+	memo := walletCMD.String("memo", "", "memo to attach to the transaction --txid")
+	lockUnspent := walletCMD.String("lock-unspent", "", "outpoints to reserve at format txid:vout")
+	unlockUnspent := walletCMD.String("unlock-unspent", "", "reserved outpoints to release at format txid:vout")
+	listLocked := walletCMD.Bool("list-locked", false, "Print reserved outpoints")
+	abandon := walletCMD.Bool("abandon", false, "Forget the unconfirmed transaction --txid")
+	rebuildHistory := walletCMD.Bool("rebuild-history", false, "Rebuild wallet transactions from the blockchain")
+	from := walletCMD.Int("from", 0, "height of the first block to scan with --rebuild-history or --import-key")
+	exportKey := walletCMD.Bool("export-key", false, "Print the encoded private key of --address")
+	signMessage := walletCMD.Bool("signmessage", false, "Sign --message with the private key of --address")
+	verifyMessage := walletCMD.Bool("verifymessage", false, "Verify that --message is signed by --address")
+	message := walletCMD.String("message", "", "message to sign or verify, or message of the payment request")
+	request := walletCMD.Bool("request", false, "Create a payment request to a new address")
+	requests := walletCMD.Bool("requests", false, "Print payment requests")
+	amount := walletCMD.Int("amount", 0, "amount of the payment request, free if 0")
+	label := walletCMD.String("label", "", "label of the payment request")
+	signature := walletCMD.String("signature", "", "signature of --message returned by --signmessage")
+	importKeyData := walletCMD.String("import-key", "", "encoded private key to add to the wallet")
+	noRescan := walletCMD.Bool("no-rescan", false, "don't scan the blockchain after the import")
+	sweep := walletCMD.String("sweep", "", "encoded private key whose coins are sent to an unused wallet address")
+	fees := walletCMD.Int("fees", 0, "fees to offer to miner")
+	feeRate := walletCMD.Int("fee-rate", -1, "fees per byte to offer to miner, estimated if neither --fees nor --fee-rate is set")
+	confTarget := walletCMD.Int("conf-target", defaultConfTarget, "number of blocks in which the sweep should be confirmed")
+	broadcast := walletCMD.Bool("broadcast", false, "broadcast the sweep or the fee bump to the main node instead of mining it locally")
+	bumpFeeTx := walletCMD.String("bumpfee", "", "hash of an unconfirmed wallet transaction to replace with a higher fee rate")
+	createWallet := walletCMD.String("create-wallet", "", "name of the wallet to create")
+	loadWallet := walletCMD.String("load-wallet", "", "name of the wallet to load")
+	unloadWallet := walletCMD.String("unload-wallet", "", "name of the wallet to unload")
+	listWallets := walletCMD.Bool("list-wallets", false, "Print named wallets")
+	walletName := walletFlag(walletCMD)
 
 	handleParsingError(walletCMD)
 
+	if *createWallet != "" || *loadWallet != "" || *unloadWallet != "" || *listWallets {
+		manageWallets(*createWallet, *loadWallet, *unloadWallet, *listWallets)
+		return
+	}
+	if selectWallet(*walletName) == false {
+		return
+	}
+
 	if *pubkeyHToAddr != "" {
 		pubKeyHashBytes, _ := hex.DecodeString(*pubkeyHToAddr)
+		if *bech32 {
+			addr, err := wallet.EncodeBech32Address(wallet.PubKeyHashAddress, pubKeyHashBytes)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Println(addr)
+			return
+		}
 		addr := wallet.GetAddressFromPubKeyHash(pubKeyHashBytes)
 		fmt.Println(string(addr))
 		return
 	}
+	if *convertAddress != "" {
+		addr, err := wallet.DecodeAddress(*convertAddress)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		bech32Addr, err := wallet.EncodeBech32Address(addr.Type, addr.Hash)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println("base58:", addr.String())
+		fmt.Println("bech32:", bech32Addr)
+		return
+	}
+	if *encrypt {
+		encryptWallet()
+		return
+	}
+	if *unlock {
+		unlockServer(*timeout)
+		return
+	}
+	if *lock {
+		if err := wallet.LockServer(); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println("wallet locked")
+		return
+	}
+	if *hdNew {
+		initHD("")
+		return
+	}
+	if *hdRestore != "" {
+		if initHD(*hdRestore) {
+			rescanHD(*gap)
+		}
+		return
+	}
+	if *hdMnemonic {
+		if wallet.HD == nil {
+			fmt.Println("no HD seed, use --hd-new or --hd-restore")
+			return
+		}
+		if err := wallet.CheckUnlocked(); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(wallet.HD.Mnemonic)
+		return
+	}
+	if *rescan {
+		rescanHD(*gap)
+		return
+	}
+	if *exportKey {
+		if *address == "" {
+			walletUsage()
+			return
+		}
+		encoded, err := wallet.ExportKey(*address)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(encoded)
+		return
+	}
+	if *request {
+		createPaymentRequest(*amount, *label, *message)
+		return
+	}
+	if *requests {
+		printPaymentRequests()
+		return
+	}
+	if *signMessage {
+		if *address == "" {
+			walletUsage()
+			return
+		}
+		sig, err := wallet.SignMessage(*address, *message)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(sig)
+		return
+	}
+	if *verifyMessage {
+		if *address == "" || *signature == "" {
+			walletUsage()
+			return
+		}
+		valid, err := wallet.VerifyMessage(*address, *signature, *message)
+		if err != nil {
+			fmt.Println(err)
+		} else if valid {
+			fmt.Println("signature is valid")
+		} else {
+			fmt.Println("signature is not valid")
+		}
+		return
+	}
+	if *importKeyData != "" {
+		importKey(*importKeyData, *from, *noRescan == false)
+		return
+	}
+	if *sweep != "" {
+		if *fees > 0 && *feeRate < 0 {
+			*feeRate = 0
+		}
+		if tx, fee := sweepKey(*sweep, *fees, *feeRate, *confTarget); tx != nil {
+			submitTx(tx, fee, *broadcast)
+		}
+		return
+	}
+	if *rebuildHistory {
+		fmt.Println(wallet.RebuildHistory(*from), "wallet transactions found")
+		return
+	}
+	if *memo != "" {
+		if *txid == "" {
+			walletUsage()
+		} else if wallet.SetTxMemo(*txid, *memo) == false {
+			fmt.Println("transaction not found in wallet history")
+		}
+		return
+	}
+	if *lockUnspent != "" || *unlockUnspent != "" {
+		updateLockedUnspents(*lockUnspent, *unlockUnspent)
+		return
+	}
+	if *listLocked {
+		for _, op := range wallet.ListLockedUnspents() {
+			fmt.Println(op)
+		}
+		return
+	}
+	if *abandon {
+		if *txid == "" {
+			walletUsage()
+		} else if err := wallet.AbandonTx(*txid); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+	if *bumpFeeTx != "" {
+		if *feeRate < 0 {
+			*feeRate = estimateFeeRate(*confTarget)
+		}
+		bumpFee(*bumpFeeTx, *feeRate, *broadcast)
+		return
+	}
+	if *history {
+		printHistory(*direction, *address, *minConf, *limit, *txid)
+		return
+	}
 	if *list {
 		//affiche la liste des addresses locals
-		PrintAddressStored(*pubkey, *privkey)
+		PrintAddressStored(*pubkey, *privkey, *bech32)
 	} else if *new {
 		//genere un nouveau wallet
-		w := wallet.NewWallet()
+		w, err := wallet.NewWallet()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
 		addr := string(w.GetAddress())[:]
-		wallet.WalletList[addr] = w
-		wallet.SaveToFile()
+		if err := wallet.AddWallet(addr, w); err != nil {
+			fmt.Println(err)
+			return
+		}
 
 		fmt.Println("address:", hex.EncodeToString(w.GetAddress()))
+		if *bech32 {
+			fmt.Println("bech32 address:", w.GetBech32Address())
+		}
 		fmt.Println("public key:", hex.EncodeToString(w.PublicKey))
 	} else if *total {
 		PrintTotalAmountAvailable()
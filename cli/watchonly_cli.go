@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"tway/script"
+	"tway/twayutil"
+	"tway/util"
+	"tway/wallet"
+)
+
+func watchOnlyUsage() {
+	fmt.Println(" Options:")
+	fmt.Println(" --import \t Watch an address, a public key or a multisig script at hex format. Works with [--label] [--from] [--no-rescan]")
+	fmt.Println(" --remove \t Stop watching an address. Works with --address")
+	fmt.Println(" --list \t Print watched addresses with their balance")
+	fmt.Println(" --rescan \t Add transactions of watched addresses to the wallet history. Works with [--from]")
+	fmt.Println(" --spend \t Create an unsigned transaction spending watched funds. Works with --address --to --amount [--fees] [--fee-rate] [--coin-select] [--out]")
+	fmt.Println(" --wallet \t name of a loaded wallet to use instead of the default wallet of the node")
+}
+
+func importWatchOnly(data, label string, from int, rescan bool) {
+	entry, err := wallet.NewWatchOnlyEntry(label, data)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	addr, err := wallet.ImportWatchOnly(entry)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("watching", addr)
+	if rescan {
+		fmt.Println(wallet.RebuildHistory(from), "wallet transactions found")
+	}
+}
+
+func printWatchOnly() {
+	info := wallet.GetWalletInfo()
+	list := info.WatchOnly
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Address < list[j].Address
+	})
+	for _, ws := range list {
+		kind := "address"
+		if len(ws.Entry.Script) > 0 {
+			kind = "multisig"
+		} else if len(ws.Entry.PubKey) > 0 {
+			kind = "pubkey"
+		}
+		fmt.Printf("%s\t%d\t%s\t%s\n", ws.Address, ws.Amount, kind, ws.Entry.Label)
+	}
+	fmt.Println(info.WatchOnlyAmount, "coins are watch-only")
+}
+
+//Créer une transaction non signée dépensant les fonds d'une entrée watch-only
+//La transaction est signée par le détenteur des clés privées avec psbt --sign
+func spendWatchOnly(entry *wallet.WatchOnlyEntry, to [][]byte, amount, fees, feeRate int, coinSelect string) (*twayutil.PSBT, error) {
+	var candidates []wallet.LocalUnspentOutput
+	_, unspents := entry.GetUnspentOutputs()
+	for _, us := range unspents {
+		candidates = append(candidates, wallet.LocalUnspentOutput{TxID: us.TxID, Idx: us.Idx, Amount: util.DecodeInt(us.Output.Value)})
+	}
+	model := wallet.NewCostModel(amount, fees, feeRate, [][][]byte{script.Script.LockingScript(to, 0)})
+	selection, err := wallet.SelectCoins(coinSelect, candidates, model)
+	if err != nil {
+		return nil, err
+	}
+	var inputs []twayutil.Input
+	for _, us := range selection.Inputs {
+		var emptyScript [][]byte
+		inputs = append(inputs, twayutil.NewTxInput(us.TxID, util.EncodeInt(us.Idx), emptyScript))
+	}
+	return createPSBT(inputs, to, amount, selection.Fee, 0)
+}
+
+func watchOnlyCli() {
+	watchCMD := flag.NewFlagSet("watchonly", flag.ExitOnError)
+	importData := watchCMD.String("import", "", "address, public key or multisig script to watch")
+	remove := watchCMD.Bool("remove", false, "Stop watching an address")
+	list := watchCMD.Bool("list", false, "Print watched addresses")
+	rescan := watchCMD.Bool("rescan", false, "Add transactions of watched addresses to the wallet history")
+	spend := watchCMD.Bool("spend", false, "Create an unsigned transaction spending watched funds")
+	label := watchCMD.String("label", "", "name of the watched address")
+	from := watchCMD.Int("from", 0, "height of the first block to scan")
+	noRescan := watchCMD.Bool("no-rescan", false, "don't scan the blockchain after the import")
+	address := watchCMD.String("address", "", "watched address")
+	toString := watchCMD.String("to", "", "address to send")
+	amount := watchCMD.Int("amount", 0, "amount to send")
+	fees := watchCMD.Int("fees", 0, "fees to offer to miner")
+	feeRate := watchCMD.Int("fee-rate", 0, "fees per byte to offer to miner")
+	coinSelect := watchCMD.String("coin-select", wallet.DefaultCoinSelector, "UTXOs selection strategy")
+	out := watchCMD.String("out", "", "write the unsigned transaction in this file")
+	walletName := walletFlag(watchCMD)
+	handleParsingError(watchCMD)
+	if selectWallet(*walletName) == false {
+		return
+	}
+
+	if *importData != "" {
+		importWatchOnly(*importData, *label, *from, *noRescan == false)
+	} else if *remove && *address != "" {
+		if err := wallet.RemoveWatchOnly(*address); err != nil {
+			fmt.Println(err)
+		}
+	} else if *list {
+		printWatchOnly()
+	} else if *rescan {
+		fmt.Println(wallet.RebuildHistory(*from), "wallet transactions found")
+	} else if *spend && *address != "" && *toString != "" && *amount > 0 {
+		entry := wallet.GetWatchOnly(*address)
+		if entry == nil {
+			fmt.Println("address is not watched")
+			return
+		}
+		toPubKeyHash, err := wallet.DecodePubKeyHashAddress(*toString)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		psbt, err := spendWatchOnly(entry, [][]byte{toPubKeyHash}, *amount, *fees, *feeRate, *coinSelect)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		writePSBT(psbt, *out)
+	} else {
+		watchOnlyUsage()
+	}
+}
@@ -37,6 +37,7 @@ func InitPKG() {
 	}
 	DB_FILE += NODE_ID
 	WALLET_FILE += NODE_ID
+	initNetwork()
 	ip, err := util.GetIP()
 	if err != nil {
 		fmt.Printf(err.Error())
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+//Paramètres d'encodage des adresses et des clés d'un réseau
+type NetParams struct {
+	Name string
+	//version des adresses PayToPubKeyHash encodées en base58
+	PubKeyHashAddrID byte
+	//version des adresses PayToScriptHash encodées en base58
+	ScriptHashAddrID byte
+	//version des clés privées encodées
+	PrivateKeyID byte
+	//préfixe des adresses encodées en bech32
+	Bech32HRP string
+}
+
+var (
+	MainNetParams = NetParams{
+		Name:             "mainnet",
+		PubKeyHashAddrID: 0x00,
+		ScriptHashAddrID: 0x05,
+		PrivateKeyID:     0x80,
+		Bech32HRP:        "tw",
+	}
+	TestNetParams = NetParams{
+		Name:             "testnet",
+		PubKeyHashAddrID: 0x6f,
+		ScriptHashAddrID: 0xc4,
+		PrivateKeyID:     0xef,
+		Bech32HRP:        "tt",
+	}
+	RegTestParams = NetParams{
+		Name:             "regtest",
+		PubKeyHashAddrID: 0x6f,
+		ScriptHashAddrID: 0xc4,
+		PrivateKeyID:     0xef,
+		Bech32HRP:        "twrt",
+	}
+
+	//Réseau du noeud, choisi avec la variable d'environnement NETWORK
+	ActiveNet = &MainNetParams
+)
+
+//Retourne les paramètres du réseau nommé, le mainnet si name est vide
+func GetNetParams(name string) (*NetParams, error) {
+	switch name {
+	case "", MainNetParams.Name:
+		return &MainNetParams, nil
+	case TestNetParams.Name:
+		return &TestNetParams, nil
+	case RegTestParams.Name:
+		return &RegTestParams, nil
+	}
+	return nil, fmt.Errorf("unknown network %s, must be %s, %s or %s", name, MainNetParams.Name, TestNetParams.Name, RegTestParams.Name)
+}
+
+//Sélectionne le réseau à partir de la variable d'environnement NETWORK
+func initNetwork() {
+	params, err := GetNetParams(os.Getenv("NETWORK"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	ActiveNet = params
+}
@@ -2,7 +2,10 @@ package config
 
 const (
 	P2PKHSize     = 7
-	PubKeyLength  = 64
-	SigLength     = 64
+	PubKeyLength  = 33 //clé publique compressée
+	SigLength     = 72 //taille maximale d'une signature DER
 	PubKeyHLength = 20
+
+	//Nombre maximum de signatures valides gardées en cache
+	MaxSigCacheEntries = 50000
 )
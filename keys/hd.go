@@ -0,0 +1,106 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	//Index à partir duquel une dérivation est dite renforcée (hardened)
+	HardenedKeyStart = 0x80000000
+	//Clé HMAC utilisée pour générer la clé maîtresse depuis une seed
+	masterKeySeed = "Tway seed"
+)
+
+//Une clé invalide a été générée, il faut passer à l'index suivant
+var ErrInvalidChild = errors.New("invalid child key, use the next index")
+
+//Clé privée étendue d'un wallet déterministe hiérarchique
+//(dérivation inspirée du BIP32, appliquée à la courbe P-256)
+type ExtendedKey struct {
+	Key       []byte //clé privée sur 32 octets
+	ChainCode []byte
+}
+
+//Génère la clé maîtresse à partir d'une seed
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	mac := hmac.New(sha512.New, []byte(masterKeySeed))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	k := new(big.Int).SetBytes(sum[:32])
+	if k.Sign() == 0 || k.Cmp(Curve().Params().N) >= 0 {
+		return nil, errors.New("unusable seed")
+	}
+	return &ExtendedKey{sum[:32], sum[32:]}, nil
+}
+
+//Dérive la clé enfant à l'index i
+//Les index supérieurs ou égaux à HardenedKeyStart utilisent la clé privée
+//du parent, les autres sa clé publique
+func (k *ExtendedKey) Child(i uint32) (*ExtendedKey, error) {
+	var data []byte
+	if i >= HardenedKeyStart {
+		data = append([]byte{0x00}, k.Key...)
+	} else {
+		data = PubKeyFromPrivate(k.PrivateKey()).SerializeCompressed()
+	}
+	var index [4]byte
+	binary.BigEndian.PutUint32(index[:], i)
+	data = append(data, index[:]...)
+
+	mac := hmac.New(sha512.New, k.ChainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := Curve().Params().N
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(n) >= 0 {
+		return nil, ErrInvalidChild
+	}
+	child := il.Add(il, new(big.Int).SetBytes(k.Key))
+	child.Mod(child, n)
+	if child.Sign() == 0 {
+		return nil, ErrInvalidChild
+	}
+	key := make([]byte, PrivKeyBytesLen)
+	childBytes := child.Bytes()
+	copy(key[PrivKeyBytesLen-len(childBytes):], childBytes)
+	return &ExtendedKey{key, sum[32:]}, nil
+}
+
+//Dérive la clé correspondant à une liste d'index depuis la clé courante
+func (k *ExtendedKey) Derive(path []uint32) (*ExtendedKey, error) {
+	key := k
+	for _, i := range path {
+		child, err := key.Child(i)
+		if err != nil {
+			return nil, err
+		}
+		key = child
+	}
+	return key, nil
+}
+
+//Retourne la clé privée ecdsa de la clé étendue
+func (k *ExtendedKey) PrivateKey() *ecdsa.PrivateKey {
+	return ParsePrivateKey(k.Key)
+}
+
+//Formate un chemin de dérivation (ex : m/0'/1/5)
+func PathString(path []uint32) string {
+	s := "m"
+	for _, i := range path {
+		if i >= HardenedKeyStart {
+			s += fmt.Sprintf("/%d'", i-HardenedKeyStart)
+		} else {
+			s += fmt.Sprintf("/%d", i)
+		}
+	}
+	return s
+}
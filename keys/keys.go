@@ -0,0 +1,150 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"tway/util"
+)
+
+const (
+	//Taille d'une clé publique compressée : 0x02|0x03 <X>
+	PubKeyBytesLenCompressed = 33
+	//Taille d'une clé publique non compressée : 0x04 <X> <Y>
+	PubKeyBytesLenUncompressed = 65
+	//Taille maximum d'une clé publique des premières versions : <X> <Y>
+	//sans préfixe, les zéros de tête des coordonnées ne sont pas encodés
+	PubKeyBytesLenLegacy = 64
+
+	pubkeyCompressed   byte = 0x2
+	pubkeyUncompressed byte = 0x4
+)
+
+//Courbe utilisée par toutes les clés du réseau
+func Curve() elliptic.Curve {
+	return elliptic.P256()
+}
+
+//Structure représentant une clé publique validée
+type PublicKey struct {
+	ecdsa.PublicKey
+}
+
+//Génère une nouvelle clé privée
+func NewPrivateKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(Curve(), rand.Reader)
+}
+
+//Taille d'une clé privée encodée
+const PrivKeyBytesLen = 32
+
+//Encode une clé privée sur 32 octets
+func SerializePrivateKey(priv *ecdsa.PrivateKey) []byte {
+	d := make([]byte, PrivKeyBytesLen)
+	dBytes := priv.D.Bytes()
+	copy(d[PrivKeyBytesLen-len(dBytes):], dBytes)
+	return d
+}
+
+//Decode une clé privée encodée sur 32 octets
+func ParsePrivateKey(d []byte) *ecdsa.PrivateKey {
+	priv := new(ecdsa.PrivateKey)
+	priv.Curve = Curve()
+	priv.D = new(big.Int).SetBytes(d)
+	priv.PublicKey.X, priv.PublicKey.Y = priv.Curve.ScalarBaseMult(d)
+	return priv
+}
+
+//Retourne la clé publique liée à une clé privée
+func PubKeyFromPrivate(priv *ecdsa.PrivateKey) *PublicKey {
+	return &PublicKey{priv.PublicKey}
+}
+
+//Decode une clé publique au format SEC compressé (33 octets), non compressé
+//(65 octets) ou X||Y des premières versions et verifie que le point est sur la courbe
+//OP_HASH160 hash les octets de la clé tels quels : chaque format d'une même clé
+//correspond à une adresse différente, le wallet conserve le format de ses clés
+func ParsePubKey(data []byte) (*PublicKey, error) {
+	curve := Curve()
+	pk := &PublicKey{ecdsa.PublicKey{Curve: curve}}
+
+	switch {
+	case len(data) == PubKeyBytesLenCompressed:
+		if data[0]&^0x1 != pubkeyCompressed {
+			return nil, errors.New("invalid magic in compressed pubkey")
+		}
+		pk.X, pk.Y = elliptic.UnmarshalCompressed(curve, data)
+	case len(data) == PubKeyBytesLenUncompressed:
+		if data[0] != pubkeyUncompressed {
+			return nil, errors.New("invalid magic in uncompressed pubkey")
+		}
+		pk.X, pk.Y = elliptic.Unmarshal(curve, data)
+	case len(data) > PubKeyBytesLenCompressed && len(data) <= PubKeyBytesLenLegacy:
+		pk.X, pk.Y = parseLegacyPubKey(data)
+	default:
+		return nil, errors.New("invalid pubkey length")
+	}
+	//Unmarshal retourne nil si le point n'est pas sur la courbe
+	if pk.X == nil || pk.Y == nil {
+		return nil, errors.New("pubkey isn't on the curve")
+	}
+	return pk, nil
+}
+
+//Decode une clé publique X||Y : la taille de chaque coordonnée n'étant pas
+//encodée, on cherche la séparation pour laquelle le point est sur la courbe
+func parseLegacyPubKey(data []byte) (*big.Int, *big.Int) {
+	size := PubKeyBytesLenLegacy / 2
+	for i := len(data) - size; i <= size; i++ {
+		//les coordonnées sont encodées sans zéro de tête
+		if i <= 0 || data[0] == 0 || data[i] == 0 {
+			continue
+		}
+		uncompressed := make([]byte, PubKeyBytesLenUncompressed)
+		uncompressed[0] = pubkeyUncompressed
+		copy(uncompressed[1+size-i:1+size], data[:i])
+		copy(uncompressed[PubKeyBytesLenUncompressed-(len(data)-i):], data[i:])
+		if x, y := elliptic.Unmarshal(Curve(), uncompressed); x != nil {
+			return x, y
+		}
+	}
+	return nil, nil
+}
+
+//Retourne true si data est une clé publique valide
+func IsPubKey(data []byte) bool {
+	_, err := ParsePubKey(data)
+	return err == nil
+}
+
+//Encode la clé publique au format SEC compressé (33 octets)
+func (pk *PublicKey) SerializeCompressed() []byte {
+	return elliptic.MarshalCompressed(pk.Curve, pk.X, pk.Y)
+}
+
+//Encode la clé publique au format SEC non compressé (65 octets)
+func (pk *PublicKey) SerializeUncompressed() []byte {
+	return elliptic.Marshal(pk.Curve, pk.X, pk.Y)
+}
+
+//Encode la clé publique au format X||Y des premières versions
+func (pk *PublicKey) SerializeLegacy() []byte {
+	return append(pk.X.Bytes(), pk.Y.Bytes()...)
+}
+
+//Retourne true si data est une clé publique valide au format X||Y
+func IsLegacyPubKey(data []byte) bool {
+	return len(data) > PubKeyBytesLenCompressed && len(data) <= PubKeyBytesLenLegacy && IsPubKey(data)
+}
+
+//Hash la clé publique compressée (ripemd160(sha256(pubkey)))
+func (pk *PublicKey) Hash160() []byte {
+	return util.Ripemd160(util.Sha256(pk.SerializeCompressed()))
+}
+
+//Verifie une signature DER sur un hash avec la clé publique
+func (pk *PublicKey) Verify(hash, signature []byte) bool {
+	return util.VerifySignature(&pk.PublicKey, hash, signature)
+}
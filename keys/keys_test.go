@@ -0,0 +1,157 @@
+package keys
+
+import (
+	"bytes"
+	"testing"
+	"tway/util"
+)
+
+func testPubKey(t *testing.T) *PublicKey {
+	priv, err := NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return PubKeyFromPrivate(priv)
+}
+
+func TestParsePubKeyRoundTrip(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		pk := testPubKey(t)
+		for _, data := range [][]byte{pk.SerializeCompressed(), pk.SerializeUncompressed(), pk.SerializeLegacy()} {
+			parsed, err := ParsePubKey(data)
+			if err != nil {
+				t.Fatalf("%x: %v", data, err)
+			}
+			if parsed.X.Cmp(pk.X) != 0 || parsed.Y.Cmp(pk.Y) != 0 {
+				t.Fatalf("%x parsed as another point", data)
+			}
+			if bytes.Compare(parsed.SerializeCompressed(), pk.SerializeCompressed()) != 0 {
+				t.Fatalf("%x: compressed encoding changed", data)
+			}
+		}
+		if len(pk.SerializeCompressed()) != PubKeyBytesLenCompressed || len(pk.SerializeUncompressed()) != PubKeyBytesLenUncompressed {
+			t.Fatal("unexpected encoding length")
+		}
+	}
+}
+
+//Les coordonnées d'une clé X||Y sont encodées sans zéro de tête
+func TestParseLegacyPubKeyShortCoordinates(t *testing.T) {
+	found := 0
+	for found < 3 {
+		pk := testPubKey(t)
+		legacy := pk.SerializeLegacy()
+		if len(legacy) == PubKeyBytesLenLegacy {
+			continue
+		}
+		found++
+		parsed, err := ParsePubKey(legacy)
+		if err != nil {
+			t.Fatalf("%x: %v", legacy, err)
+		}
+		if parsed.X.Cmp(pk.X) != 0 || parsed.Y.Cmp(pk.Y) != 0 {
+			t.Fatalf("%x parsed as another point", legacy)
+		}
+		if IsLegacyPubKey(legacy) == false {
+			t.Fatalf("%x is not a legacy key", legacy)
+		}
+	}
+}
+
+//La clé du block genesis est au format X||Y
+func TestParseGenesisPubKey(t *testing.T) {
+	genesis := []byte{189, 208, 30, 89, 219, 197, 16, 58, 25, 114, 192, 26, 220, 144, 175, 157, 49, 159, 118, 140, 125, 205, 53, 177, 7, 217, 176, 2, 32, 103, 6, 158, 41, 70, 93, 47, 232, 197, 86, 128, 148, 98, 99, 151, 120, 33, 166, 193, 45, 123, 29, 252, 213, 142, 130, 88, 248, 152, 109, 119, 89, 243, 129, 88}
+	pk, err := ParsePubKey(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(pk.SerializeLegacy(), genesis) != 0 {
+		t.Fatalf("genesis key encoded as %x", pk.SerializeLegacy())
+	}
+}
+
+func TestParsePubKeyBadPrefix(t *testing.T) {
+	pk := testPubKey(t)
+	compressed := pk.SerializeCompressed()
+	uncompressed := pk.SerializeUncompressed()
+	for _, prefix := range []byte{0x00, 0x01, 0x04, 0x05, 0x06, 0x07, 0xff} {
+		data := append([]byte{prefix}, compressed[1:]...)
+		if _, err := ParsePubKey(data); err == nil {
+			t.Fatalf("compressed key with prefix %x accepted", prefix)
+		}
+	}
+	for _, prefix := range []byte{0x00, 0x02, 0x03, 0x06, 0x07} {
+		data := append([]byte{prefix}, uncompressed[1:]...)
+		if _, err := ParsePubKey(data); err == nil {
+			t.Fatalf("uncompressed key with prefix %x accepted", prefix)
+		}
+	}
+}
+
+func TestParsePubKeyBadLength(t *testing.T) {
+	pk := testPubKey(t)
+	compressed := pk.SerializeCompressed()
+	uncompressed := pk.SerializeUncompressed()
+	tests := [][]byte{
+		nil,
+		{0x02},
+		compressed[:PubKeyBytesLenCompressed-1],
+		append(uncompressed, 0x00),
+		uncompressed[:PubKeyBytesLenUncompressed-1],
+		make([]byte, 20),
+		make([]byte, 72),
+	}
+	for _, data := range tests {
+		if _, err := ParsePubKey(data); err == nil {
+			t.Fatalf("%d bytes key accepted", len(data))
+		}
+	}
+}
+
+func TestParsePubKeyOffCurve(t *testing.T) {
+	pk := testPubKey(t)
+	uncompressed := pk.SerializeUncompressed()
+	uncompressed[PubKeyBytesLenUncompressed-1] ^= 0x01
+	if _, err := ParsePubKey(uncompressed); err == nil {
+		t.Fatal("uncompressed point off the curve accepted")
+	}
+	legacy := pk.SerializeLegacy()
+	legacy[len(legacy)-1] ^= 0x01
+	if _, err := ParsePubKey(legacy); err == nil {
+		t.Fatal("legacy point off the curve accepted")
+	}
+	//une abscisse sans ordonnée sur la courbe
+	compressed := pk.SerializeCompressed()
+	for i := 0; i < 256; i++ {
+		compressed[PubKeyBytesLenCompressed-1] = byte(i)
+		if _, err := ParsePubKey(compressed); err != nil {
+			return
+		}
+	}
+	t.Fatal("every x coordinate accepted")
+}
+
+func TestVerify(t *testing.T) {
+	priv, err := NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := util.Sha256([]byte("message"))
+	sig, err := util.Sign(priv, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := PubKeyFromPrivate(priv)
+	for _, data := range [][]byte{pk.SerializeCompressed(), pk.SerializeUncompressed(), pk.SerializeLegacy()} {
+		parsed, err := ParsePubKey(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Verify(hash, sig) == false {
+			t.Fatalf("%x: valid signature rejected", data)
+		}
+		if parsed.Verify(util.Sha256(hash), sig) {
+			t.Fatalf("%x: signature of another hash accepted", data)
+		}
+	}
+}
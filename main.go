@@ -6,6 +6,7 @@ import (
 	"tway/blockchain"
 	"tway/cli"
 	"tway/config"
+	"tway/mempool"
 	"tway/wallet"
 )
 
@@ -13,6 +14,7 @@ func init() {
 	rand.Seed(time.Now().UTC().UnixNano())
 	config.InitPKG()
 	blockchain.InitPKG()
+	mempool.InitPKG()
 	wallet.InitPKG()
 }
 
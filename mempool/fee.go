@@ -0,0 +1,289 @@
+package mempool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"tway/blockchain"
+	"tway/twayutil"
+	"tway/util"
+
+	"github.com/boltdb/bolt"
+)
+
+const (
+	//Nom du bucket contenant les statistiques de l'estimateur de frais
+	FEE_BUCKET = "feeestimates"
+	//Nombre maximum de blocks de confirmation suivis par l'estimateur
+	MaxConfirmTarget = 25
+	//Part des transactions d'un palier devant être confirmées dans le délai
+	//pour que son taux de frais soit retenu
+	feeSuccessThreshold = 0.85
+	//Les statistiques sont multipliées par ce facteur à chaque block
+	//afin que les transactions récentes aient plus de poids
+	feeDecay = 0.998
+	//Nombre de transactions (pondérées) nécessaires pour qu'un palier soit pris en compte
+	minBucketTxs = 1.0
+	//Paliers de taux de frais (frais par octet) : 0, 1, puis croissance de feeBucketSpacing
+	feeBucketSpacing = 1.5
+	maxFeeRate       = 1000000
+)
+
+var statsKey = []byte("stats")
+
+var ErrNoFeeEstimate = errors.New("not enough transactions seen to estimate fees")
+
+var FeeEstimator = NewEstimator()
+
+//Transaction de la mempool en attente de confirmation
+type pendingTx struct {
+	Height int //hauteur de la chain lors de l'entrée dans la mempool
+	Bucket int
+}
+
+//Statistiques de l'estimateur sauvegardées dans la db
+type feeStats struct {
+	//Confirmed[t-1][b] : transactions du palier b confirmées en t blocks ou moins
+	Confirmed [][]float64
+	//Total[b] : transactions du palier b confirmées ou abandonnées après MaxConfirmTarget blocks
+	Total   []float64
+	Pending map[string]pendingTx
+}
+
+//Estimateur de frais : enregistre le taux de frais des transactions acceptées
+//dans la mempool et le nombre de blocks nécessaires à leur confirmation
+type Estimator struct {
+	mu      sync.Mutex
+	buckets []float64 //taux de frais minimum de chaque palier
+	stats   feeStats
+}
+
+func NewEstimator() *Estimator {
+	fe := &Estimator{buckets: []float64{0}}
+	for rate := 1.0; rate < maxFeeRate; rate *= feeBucketSpacing {
+		fe.buckets = append(fe.buckets, rate)
+	}
+	fe.reset()
+	return fe
+}
+
+func (fe *Estimator) reset() {
+	fe.stats = feeStats{
+		Confirmed: make([][]float64, MaxConfirmTarget),
+		Total:     make([]float64, len(fe.buckets)),
+		Pending:   make(map[string]pendingTx),
+	}
+	for t := range fe.stats.Confirmed {
+		fe.stats.Confirmed[t] = make([]float64, len(fe.buckets))
+	}
+}
+
+//Charge les statistiques de la db et met à jour l'estimateur
+//à chaque nouvelle transaction de la mempool et à chaque nouveau block.
+//Les statistiques ne sont sauvegardées qu'à chaque nouveau block : les transactions
+//acceptées depuis le dernier block sont perdues au redémarrage, comme la mempool.
+func InitPKG() {
+	if err := FeeEstimator.load(); err != nil {
+		log.Println("fee estimator:", err)
+	}
+	blockchain.OnBlockConnected(FeeEstimator.blockConnected)
+	Mempool.OnTxAccepted(FeeEstimator.txAccepted)
+	Mempool.OnTxReplaced(FeeEstimator.txReplaced)
+}
+
+func (fe *Estimator) load() error {
+	var data []byte
+	err := blockchain.BC.DB.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(FEE_BUCKET)); b != nil {
+			data = append(data, b.Get(statsKey)...)
+		}
+		return nil
+	})
+	if err != nil || len(data) == 0 {
+		return err
+	}
+	var stats feeStats
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&stats); err != nil {
+		return err
+	}
+	//les statistiques d'une autre répartition des paliers sont ignorées
+	if len(stats.Total) != len(fe.buckets) || len(stats.Confirmed) != MaxConfirmTarget {
+		return nil
+	}
+	if stats.Pending == nil {
+		stats.Pending = make(map[string]pendingTx)
+	}
+	fe.mu.Lock()
+	fe.stats = stats
+	fe.mu.Unlock()
+	return nil
+}
+
+//Sauvegarde les statistiques, fe.mu doit être verrouillé par l'appelant
+func (fe *Estimator) save() {
+	var content bytes.Buffer
+	if err := gob.NewEncoder(&content).Encode(fe.stats); err != nil {
+		log.Panic(err)
+	}
+	err := blockchain.BC.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(FEE_BUCKET))
+		if err != nil {
+			return err
+		}
+		return b.Put(statsKey, content.Bytes())
+	})
+	if err != nil {
+		log.Println("fee estimator:", err)
+	}
+}
+
+//Retourne l'index du palier correspondant au taux de frais
+func (fe *Estimator) bucketIndex(feeRate float64) int {
+	idx := 0
+	for i, rate := range fe.buckets {
+		if feeRate >= rate {
+			idx = i
+		}
+	}
+	return idx
+}
+
+//Calcule les frais d'une transaction à partir des outputs dépensés
+//Retourne false si une transaction précédente est introuvable
+func getTxFees(tx *twayutil.Transaction, pool *TxPool) (int, bool) {
+	var totalIn int
+	for _, in := range tx.Inputs {
+		prevTx, _, height := blockchain.GetTxByHash(in.PrevTransactionHash)
+		if height == -1 {
+			prevTx = pool.GetTx(hex.EncodeToString(in.PrevTransactionHash))
+		}
+		vout := util.DecodeInt(in.Vout)
+		if prevTx == nil || vout < 0 || vout >= len(prevTx.Outputs) {
+			return 0, false
+		}
+		totalIn += util.DecodeInt(prevTx.Outputs[vout].Value)
+	}
+	return totalIn - tx.GetValue(), true
+}
+
+func (fe *Estimator) txAccepted(tx *twayutil.Transaction) {
+	fees, ok := getTxFees(tx, Mempool)
+	if ok == false || tx.GetSize() == 0 {
+		return
+	}
+	feeRate := float64(fees) / float64(tx.GetSize())
+
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	fe.stats.Pending[hex.EncodeToString(tx.GetHash())] = pendingTx{blockchain.BC.Height, fe.bucketIndex(feeRate)}
+}
+
+//Une transaction remplacée ne sera jamais confirmée, elle n'est pas prise en compte
+func (fe *Estimator) txReplaced(replaced, by *twayutil.Transaction) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	delete(fe.stats.Pending, hex.EncodeToString(replaced.GetHash()))
+}
+
+func (fe *Estimator) blockConnected(block *twayutil.Block, height int) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	fe.recordBlock(block, height)
+	fe.save()
+}
+
+//Enregistre le délai de confirmation des transactions du block vues dans la mempool
+//Les transactions non confirmées après MaxConfirmTarget blocks sont comptées comme des échecs
+//fe.mu doit être verrouillé par l'appelant
+func (fe *Estimator) recordBlock(block *twayutil.Block, height int) {
+	for t := range fe.stats.Confirmed {
+		for b := range fe.stats.Confirmed[t] {
+			fe.stats.Confirmed[t][b] *= feeDecay
+		}
+	}
+	for b := range fe.stats.Total {
+		fe.stats.Total[b] *= feeDecay
+	}
+
+	for _, tx := range block.Transactions {
+		txID := hex.EncodeToString(tx.GetHash())
+		p, exist := fe.stats.Pending[txID]
+		if exist == false {
+			continue
+		}
+		delay := height - p.Height
+		if delay < 1 {
+			delay = 1
+		}
+		for t := delay; t <= MaxConfirmTarget; t++ {
+			fe.stats.Confirmed[t-1][p.Bucket]++
+		}
+		fe.stats.Total[p.Bucket]++
+		delete(fe.stats.Pending, txID)
+	}
+	for txID, p := range fe.stats.Pending {
+		if height-p.Height >= MaxConfirmTarget {
+			fe.stats.Total[p.Bucket]++
+			delete(fe.stats.Pending, txID)
+		}
+	}
+}
+
+//Retourne le taux de frais (frais par octet) nécessaire pour qu'une transaction
+//soit confirmée dans les target prochains blocks.
+//Le taux retourné est le plus petit palier dont les transactions, ainsi que celles
+//des paliers supérieurs, ont été confirmées dans le délai avec une probabilité suffisante.
+func (fe *Estimator) EstimateFeeRate(target int) (int, error) {
+	if target < 1 || target > MaxConfirmTarget {
+		return 0, fmt.Errorf("confirmation target must be between 1 and %d", MaxConfirmTarget)
+	}
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+
+	found := -1
+	for b := len(fe.buckets) - 1; b >= 0; b-- {
+		total := fe.stats.Total[b]
+		if total < minBucketTxs {
+			continue
+		}
+		if fe.stats.Confirmed[target-1][b]/total < feeSuccessThreshold {
+			break
+		}
+		found = b
+	}
+	if found == -1 {
+		return 0, ErrNoFeeEstimate
+	}
+	return int(math.Ceil(fe.buckets[found])), nil
+}
+
+//Statistiques d'un palier de taux de frais
+type FeeBucketStats struct {
+	FeeRate   float64
+	Total     float64
+	Confirmed []float64 //transactions confirmées en t blocks ou moins, pour t de 1 à MaxConfirmTarget
+}
+
+//Retourne les statistiques des paliers contenant des transactions
+//ainsi que le nombre de transactions en attente de confirmation
+func (fe *Estimator) Stats() ([]FeeBucketStats, int) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	var list []FeeBucketStats
+	for b, rate := range fe.buckets {
+		if fe.stats.Total[b] < minBucketTxs {
+			continue
+		}
+		s := FeeBucketStats{FeeRate: rate, Total: fe.stats.Total[b]}
+		for t := range fe.stats.Confirmed {
+			s.Confirmed = append(s.Confirmed, fe.stats.Confirmed[t][b])
+		}
+		list = append(list, s)
+	}
+	return list, len(fe.stats.Pending)
+}
@@ -0,0 +1,143 @@
+package mempool
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+	"tway/twayutil"
+	"tway/util"
+)
+
+//Transaction unique identifiée par n
+func feeTestTx(n int) twayutil.Transaction {
+	return twayutil.Transaction{Version: []byte{1}, LockTime: util.EncodeInt(n)}
+}
+
+//Ajoute les transactions dans la mempool de l'estimateur à la hauteur height
+//et retourne le block qui les contient
+func addFeeTestTxs(fe *Estimator, height int, feeRate float64, from, count int) *twayutil.Block {
+	block := new(twayutil.Block)
+	for n := from; n < from+count; n++ {
+		tx := feeTestTx(n)
+		fe.stats.Pending[hex.EncodeToString(tx.GetHash())] = pendingTx{height, fe.bucketIndex(feeRate)}
+		block.Transactions = append(block.Transactions, tx)
+	}
+	return block
+}
+
+func TestFeeBucketIndex(t *testing.T) {
+	fe := NewEstimator()
+	tests := []struct {
+		feeRate float64
+		bucket  int
+	}{
+		{0, 0},
+		{0.5, 0},
+		{1, 1},
+		{1.4, 1},
+		{1.5, 2},
+		{2.25, 3},
+		{maxFeeRate * 10, len(fe.buckets) - 1},
+	}
+	for _, test := range tests {
+		if b := fe.bucketIndex(test.feeRate); b != test.bucket {
+			t.Fatalf("fee rate %v: bucket %d, want %d", test.feeRate, b, test.bucket)
+		}
+	}
+}
+
+func TestEstimateFeeRateTarget(t *testing.T) {
+	fe := NewEstimator()
+	for _, target := range []int{0, MaxConfirmTarget + 1} {
+		if _, err := fe.EstimateFeeRate(target); err == nil || err == ErrNoFeeEstimate {
+			t.Fatalf("target %d accepted", target)
+		}
+	}
+	if _, err := fe.EstimateFeeRate(1); err != ErrNoFeeEstimate {
+		t.Fatalf("estimate without data: %v", err)
+	}
+}
+
+//Les transactions à 10/octet sont confirmées au block suivant,
+//celles à 1/octet attendent 5 blocks
+func TestEstimateFeeRate(t *testing.T) {
+	fe := NewEstimator()
+	high := addFeeTestTxs(fe, 100, 10, 0, 10)
+	low := addFeeTestTxs(fe, 100, 1, 10, 10)
+	fe.recordBlock(high, 101)
+	for height := 102; height < 105; height++ {
+		fe.recordBlock(new(twayutil.Block), height)
+	}
+	fe.recordBlock(low, 105)
+	if len(fe.stats.Pending) != 0 {
+		t.Fatalf("%d transactions still pending", len(fe.stats.Pending))
+	}
+
+	highRate := int(math.Ceil(fe.buckets[fe.bucketIndex(10)]))
+	tests := []struct {
+		target  int
+		feeRate int
+	}{
+		{1, highRate},
+		{4, highRate},
+		{5, 1},
+		{MaxConfirmTarget, 1},
+	}
+	for _, test := range tests {
+		feeRate, err := fe.EstimateFeeRate(test.target)
+		if err != nil {
+			t.Fatalf("target %d: %v", test.target, err)
+		}
+		if feeRate != test.feeRate {
+			t.Fatalf("target %d: fee rate %d, want %d", test.target, feeRate, test.feeRate)
+		}
+	}
+}
+
+//Un palier supérieur qui échoue empêche de retenir les paliers inférieurs
+func TestEstimateFeeRateFailingBucket(t *testing.T) {
+	fe := NewEstimator()
+	fe.recordBlock(addFeeTestTxs(fe, 100, 1, 0, 10), 101)
+	addFeeTestTxs(fe, 100, 50, 10, 10)
+	for height := 102; height <= 100+MaxConfirmTarget; height++ {
+		fe.recordBlock(new(twayutil.Block), height)
+	}
+	if len(fe.stats.Pending) != 0 {
+		t.Fatalf("%d expired transactions still pending", len(fe.stats.Pending))
+	}
+	b := fe.bucketIndex(50)
+	if fe.stats.Total[b] < 9 || fe.stats.Confirmed[MaxConfirmTarget-1][b] != 0 {
+		t.Fatalf("expired bucket: total %v, confirmed %v", fe.stats.Total[b], fe.stats.Confirmed[MaxConfirmTarget-1][b])
+	}
+	if _, err := fe.EstimateFeeRate(1); err != ErrNoFeeEstimate {
+		t.Fatalf("estimate below a failing bucket: %v", err)
+	}
+}
+
+func TestFeeStatsDecay(t *testing.T) {
+	fe := NewEstimator()
+	fe.recordBlock(addFeeTestTxs(fe, 100, 1, 0, 1), 101)
+	b := fe.bucketIndex(1)
+	if fe.stats.Total[b] != 1 || fe.stats.Confirmed[0][b] != 1 {
+		t.Fatalf("total %v, confirmed %v", fe.stats.Total[b], fe.stats.Confirmed[0][b])
+	}
+	fe.recordBlock(new(twayutil.Block), 102)
+	if fe.stats.Total[b] != feeDecay || fe.stats.Confirmed[0][b] != feeDecay {
+		t.Fatalf("decayed total %v, confirmed %v", fe.stats.Total[b], fe.stats.Confirmed[0][b])
+	}
+}
+
+//Une transaction remplacée n'est comptée ni comme confirmée ni comme échouée
+func TestFeeTxReplaced(t *testing.T) {
+	fe := NewEstimator()
+	block := addFeeTestTxs(fe, 100, 1, 0, 1)
+	replacement := feeTestTx(1)
+	fe.txReplaced(&block.Transactions[0], &replacement)
+	if len(fe.stats.Pending) != 0 {
+		t.Fatal("replaced transaction still pending")
+	}
+	fe.recordBlock(block, 101)
+	if fe.stats.Total[fe.bucketIndex(1)] != 0 {
+		t.Fatal("replaced transaction recorded")
+	}
+}
@@ -19,6 +19,10 @@ type TxPool struct {
 	pool     sync.Map
 	download sync.Map
 	log      bool
+	//fonctions appelées après l'ajout d'une transaction dans la mempool
+	acceptListeners []func(tx *twayutil.Transaction)
+	//fonctions appelées lorsqu'une transaction est retirée par une transaction la remplaçant
+	replaceListeners []func(replaced, by *twayutil.Transaction)
 }
 
 type DownloadInformations struct {
@@ -79,21 +83,53 @@ func (tp *TxPool) AddTx(tx *twayutil.Transaction) error {
 	if res := tp.GetTx(hash); res != nil {
 		return errors.New("this tx already exist in mempool")
 	}
-	if err := tp.CheckIfTxInputsAlreadyUsedInMempool(tx); err != nil {
+	//une transaction dépensant les mêmes outputs qu'une transaction de la mempool
+	//doit respecter les règles de remplacement
+	evicted, err := tp.checkReplacement(tx)
+	if err != nil {
 		return err
 	}
-	if err := blockchain.CheckIfTxIsCorrect(tx); err != nil {
+	//la transaction peut dépenser les outputs des transactions de la mempool
+	view := make(blockchain.TxView)
+	poolTxs := tp.PoolToTxSlice()
+	for i := range poolTxs {
+		if poolHash := hex.EncodeToString(poolTxs[i].GetHash()); evicted[poolHash] == nil {
+			view[poolHash] = &poolTxs[i]
+		}
+	}
+	if err := view.CheckTx(tx); err != nil {
 		return err
 	}
+	for replacedHash, replaced := range evicted {
+		tp.RemoveTx(replacedHash)
+		tp.Log(false, replacedHash, " replaced by ", hash)
+		for _, listener := range tp.replaceListeners {
+			listener(replaced, tx)
+		}
+	}
 	//go func() {
 	tp.pool.Store(hex.EncodeToString(tx.GetHash()), tx)
 	//di.addedAt = time.Now().UnixNano()
 	//tp.download.Store(tx.GetHash(), di)
 	//}()
 	tp.Log(false, hex.EncodeToString(tx.GetHash()), " added")
+	for _, listener := range tp.acceptListeners {
+		listener(tx)
+	}
 	return nil
 }
 
+//Enregistre une fonction appelée lorsqu'une transaction est remplacée (replace-by-fee)
+//Les descendants de la transaction remplacée sont également retirés de la mempool
+func (tp *TxPool) OnTxReplaced(listener func(replaced, by *twayutil.Transaction)) {
+	tp.replaceListeners = append(tp.replaceListeners, listener)
+}
+
+//Enregistre une fonction appelée après l'acceptation d'une transaction dans la mempool
+func (tp *TxPool) OnTxAccepted(listener func(tx *twayutil.Transaction)) {
+	tp.acceptListeners = append(tp.acceptListeners, listener)
+}
+
 func (tp *TxPool) GetTx(hash string) *twayutil.Transaction {
 	val, exist := tp.pool.Load(hash)
 	if exist == false {
@@ -103,6 +139,26 @@ func (tp *TxPool) GetTx(hash string) *twayutil.Transaction {
 	return ret
 }
 
+//Retourne les transactions de la mempool pouvant être incluses dans le prochain block.
+//Un block ne peut dépenser que des outputs confirmés : une transaction dépensant
+//l'output d'une autre transaction de la mempool y reste jusqu'à ce que celle-ci soit minée
+func (tp *TxPool) BlockTxs() []twayutil.Transaction {
+	var ret []twayutil.Transaction
+	for _, tx := range tp.PoolToTxSlice() {
+		confirmed := true
+		for _, in := range tx.Inputs {
+			if tp.GetTx(hex.EncodeToString(in.PrevTransactionHash)) != nil {
+				confirmed = false
+				break
+			}
+		}
+		if confirmed {
+			ret = append(ret, tx)
+		}
+	}
+	return ret
+}
+
 func (tp *TxPool) RemoveTxListIfExist(txs []twayutil.Transaction) {
 	for _, tx := range txs {
 		tp.RemoveTx(hex.EncodeToString(tx.GetHash()))
@@ -114,13 +170,34 @@ func (tp *TxPool) RemoveTx(hash string) error {
 	return nil
 }
 
+//Retourne les transactions de la mempool, chaque transaction
+//est placée après les transactions dont elle dépense les outputs
 func (tp *TxPool) PoolToTxSlice() []twayutil.Transaction {
-	var ret []twayutil.Transaction
+	txs := make(map[string]*twayutil.Transaction)
+	var hashes []string
 	tp.pool.Range(func(key, val interface{}) bool {
-		tx := val.(*twayutil.Transaction)
-		ret = append(ret, *tx)
+		txs[key.(string)] = val.(*twayutil.Transaction)
+		hashes = append(hashes, key.(string))
 		return true
 	})
+	var ret []twayutil.Transaction
+	added := make(map[string]bool)
+	var add func(hash string)
+	add = func(hash string) {
+		if added[hash] {
+			return
+		}
+		added[hash] = true
+		for _, in := range txs[hash].Inputs {
+			if parent := hex.EncodeToString(in.PrevTransactionHash); txs[parent] != nil {
+				add(parent)
+			}
+		}
+		ret = append(ret, *txs[hash])
+	}
+	for _, hash := range hashes {
+		add(hash)
+	}
 	return ret
 }
 
@@ -0,0 +1,96 @@
+package mempool
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"tway/twayutil"
+)
+
+//Retourne les transactions de la mempool dépensant un des outputs dépensés par la transaction
+func (tp *TxPool) getConflicts(tx *twayutil.Transaction) map[string]*twayutil.Transaction {
+	conflicts := make(map[string]*twayutil.Transaction)
+	for _, poolTx := range tp.PoolToTxSlice() {
+		for _, in := range poolTx.Inputs {
+			for _, txInput := range tx.Inputs {
+				if bytes.Compare(txInput.PrevTransactionHash, in.PrevTransactionHash) == 0 && bytes.Compare(txInput.Vout, in.Vout) == 0 {
+					poolTx := poolTx
+					conflicts[hex.EncodeToString(poolTx.GetHash())] = &poolTx
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+//Ajoute à la liste les transactions de la mempool dépensant les outputs
+//des transactions de la liste, récursivement
+func (tp *TxPool) addDescendants(txs map[string]*twayutil.Transaction) {
+	poolTxs := tp.PoolToTxSlice()
+	//les parents sont placés avant leurs enfants, un seul parcours suffit
+	for i := range poolTxs {
+		for _, in := range poolTxs[i].Inputs {
+			if txs[hex.EncodeToString(in.PrevTransactionHash)] != nil {
+				txs[hex.EncodeToString(poolTxs[i].GetHash())] = &poolTxs[i]
+				break
+			}
+		}
+	}
+}
+
+//Vérifie que la transaction peut remplacer les transactions de la mempool
+//dépensant les mêmes outputs (replace-by-fee) :
+//  - les transactions remplacées signalent qu'elles sont remplaçables
+//  - les frais sont supérieurs aux frais cumulés des transactions retirées,
+//    descendants des transactions remplacées compris
+//  - le taux de frais est supérieur à celui de chaque transaction remplacée
+//  - la transaction ne dépense pas les outputs d'une transaction retirée
+//Retourne les transactions à retirer de la mempool
+func (tp *TxPool) checkReplacement(tx *twayutil.Transaction) (map[string]*twayutil.Transaction, error) {
+	conflicts := tp.getConflicts(tx)
+	if len(conflicts) == 0 {
+		return conflicts, nil
+	}
+	fees, ok := getTxFees(tx, tp)
+	if ok == false || tx.GetSize() == 0 {
+		return nil, errors.New("An input of this tx is already spent in mempool")
+	}
+	for hash, conflict := range conflicts {
+		if conflict.SignalsReplacement() == false {
+			return nil, errors.New("An input of this tx is already spent in mempool by a non replaceable tx")
+		}
+		conflictFees, ok := getTxFees(conflict, tp)
+		if ok == false {
+			return nil, fmt.Errorf("fees of replaced tx %s are unknown", hash)
+		}
+		//fees / size > conflictFees / conflictSize
+		if fees*int(conflict.GetSize()) <= conflictFees*int(tx.GetSize()) {
+			return nil, fmt.Errorf("fee rate must be higher than the fee rate of replaced tx %s", hash)
+		}
+	}
+
+	evicted := make(map[string]*twayutil.Transaction)
+	for hash, conflict := range conflicts {
+		evicted[hash] = conflict
+	}
+	tp.addDescendants(evicted)
+	var evictedFees int
+	for hash, evictedTx := range evicted {
+		//les frais retirés ne peuvent pas être sous-estimés
+		evictedTxFees, ok := getTxFees(evictedTx, tp)
+		if ok == false {
+			return nil, fmt.Errorf("fees of evicted tx %s are unknown", hash)
+		}
+		evictedFees += evictedTxFees
+		for _, in := range tx.Inputs {
+			if hex.EncodeToString(in.PrevTransactionHash) == hash {
+				return nil, errors.New("tx spends an output of a tx it replaces")
+			}
+		}
+	}
+	if fees <= evictedFees {
+		return nil, fmt.Errorf("fees must be higher than the %d coins of fees of replaced txs", evictedFees)
+	}
+	return evicted, nil
+}
@@ -0,0 +1,130 @@
+package mempool
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"tway/blockchain"
+	"tway/twayutil"
+	"tway/util"
+)
+
+//Input dépensant l'output vout de la transaction, remplaçable si rbf est vrai
+func spendOutput(tx *twayutil.Transaction, vout int, rbf bool) twayutil.Input {
+	in := twayutil.NewTxInput(tx.GetHash(), util.EncodeInt(vout), nil)
+	if rbf {
+		in.Sequence = util.EncodeInt(twayutil.MaxRBFSequence)
+	}
+	return in
+}
+
+func replaceTestTx(inputs []twayutil.Input, values ...int) *twayutil.Transaction {
+	tx := &twayutil.Transaction{Version: []byte{1}, Inputs: inputs}
+	for _, value := range values {
+		tx.Outputs = append(tx.Outputs, twayutil.NewTxOutput(nil, value))
+	}
+	return tx
+}
+
+//Mempool contenant :
+//  - funding : transaction sans input dont les outputs sont dépensés par les autres
+//  - parent : dépense funding:0 et signale qu'elle est remplaçable, 1000 de frais
+//  - child : dépense parent:0, 500 de frais
+func replaceTestPool(t *testing.T) (*TxPool, *twayutil.Transaction, *twayutil.Transaction, *twayutil.Transaction) {
+	//aucun block : les transactions précédentes sont recherchées dans la mempool
+	blockchain.BC = &blockchain.Blockchain{}
+	tp := NewMempool()
+	tp.log = false
+	funding := replaceTestTx(nil, 10000, 10000)
+	parent := replaceTestTx([]twayutil.Input{spendOutput(funding, 0, true)}, 8000, 1000)
+	child := replaceTestTx([]twayutil.Input{spendOutput(parent, 0, false)}, 7500)
+	for _, tx := range []*twayutil.Transaction{funding, parent, child} {
+		tp.pool.Store(hex.EncodeToString(tx.GetHash()), tx)
+	}
+	return tp, funding, parent, child
+}
+
+func expectReplacementError(t *testing.T, tp *TxPool, tx *twayutil.Transaction, message string) {
+	_, err := tp.checkReplacement(tx)
+	if err == nil {
+		t.Fatalf("replacement accepted, want error %q", message)
+	}
+	if strings.Contains(err.Error(), message) == false {
+		t.Fatalf("error %q, want %q", err, message)
+	}
+}
+
+func TestReplacementWithoutConflict(t *testing.T) {
+	tp, funding, _, _ := replaceTestPool(t)
+	evicted, err := tp.checkReplacement(replaceTestTx([]twayutil.Input{spendOutput(funding, 1, false)}, 9000))
+	if err != nil || len(evicted) != 0 {
+		t.Fatalf("evicted %d txs, err %v", len(evicted), err)
+	}
+}
+
+func TestReplacementNotSignaled(t *testing.T) {
+	tp, funding, _, _ := replaceTestPool(t)
+	final := replaceTestTx([]twayutil.Input{spendOutput(funding, 1, false)}, 9000)
+	tp.pool.Store(hex.EncodeToString(final.GetHash()), final)
+	expectReplacementError(t, tp, replaceTestTx([]twayutil.Input{spendOutput(funding, 1, false)}, 5000), "non replaceable")
+}
+
+//Les signatures d'une transaction de version précédente n'engagent pas ses outputs :
+//elle ne peut pas être remplacée même si ses inputs le signalent
+func TestReplacementLegacyVersion(t *testing.T) {
+	tp, funding, _, _ := replaceTestPool(t)
+	legacy := replaceTestTx([]twayutil.Input{spendOutput(funding, 1, true)}, 9000)
+	legacy.Version = []byte{0}
+	tp.pool.Store(hex.EncodeToString(legacy.GetHash()), legacy)
+	expectReplacementError(t, tp, replaceTestTx([]twayutil.Input{spendOutput(funding, 1, false)}, 5000), "non replaceable")
+}
+
+//Mêmes frais que le parent pour une transaction plus grande
+func TestReplacementFeeRate(t *testing.T) {
+	tp, funding, _, _ := replaceTestPool(t)
+	expectReplacementError(t, tp, replaceTestTx([]twayutil.Input{spendOutput(funding, 0, false)}, 4000, 4000, 500, 500), "fee rate")
+}
+
+//Le taux de frais est supérieur à celui du parent mais les frais ne couvrent pas
+//ceux du parent et de son enfant retirés avec lui
+func TestReplacementAbsoluteFee(t *testing.T) {
+	tp, funding, _, _ := replaceTestPool(t)
+	expectReplacementError(t, tp, replaceTestTx([]twayutil.Input{spendOutput(funding, 0, false)}, 9000), "1500 coins")
+	expectReplacementError(t, tp, replaceTestTx([]twayutil.Input{spendOutput(funding, 0, false)}, 8500), "1500 coins")
+}
+
+func TestReplacementEvictsDescendants(t *testing.T) {
+	tp, funding, parent, child := replaceTestPool(t)
+	evicted, err := tp.checkReplacement(replaceTestTx([]twayutil.Input{spendOutput(funding, 0, false)}, 8000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evicted) != 2 || evicted[hex.EncodeToString(parent.GetHash())] == nil || evicted[hex.EncodeToString(child.GetHash())] == nil {
+		t.Fatalf("evicted %d txs, want the parent and its child", len(evicted))
+	}
+}
+
+func TestReplacementSpendsReplacedTx(t *testing.T) {
+	tp, funding, parent, _ := replaceTestPool(t)
+	tx := replaceTestTx([]twayutil.Input{spendOutput(funding, 0, false), spendOutput(parent, 1, false)}, 8000)
+	expectReplacementError(t, tp, tx, "spends an output of a tx it replaces")
+}
+
+//Les frais d'une transaction retirée doivent être connus pour ne pas être sous-estimés
+func TestReplacementUnknownEvictedFee(t *testing.T) {
+	tp, funding, parent, _ := replaceTestPool(t)
+	unknown := replaceTestTx(nil, 5000)
+	orphan := replaceTestTx([]twayutil.Input{spendOutput(parent, 1, false), spendOutput(unknown, 0, false)}, 5500)
+	tp.pool.Store(hex.EncodeToString(orphan.GetHash()), orphan)
+	expectReplacementError(t, tp, replaceTestTx([]twayutil.Input{spendOutput(funding, 0, false)}, 5000), "unknown")
+}
+
+//Les transactions dépensant les outputs d'une transaction de la mempool
+//attendent que celle-ci soit minée
+func TestBlockTxs(t *testing.T) {
+	tp, funding, _, _ := replaceTestPool(t)
+	txs := tp.BlockTxs()
+	if len(txs) != 1 || hex.EncodeToString(txs[0].GetHash()) != hex.EncodeToString(funding.GetHash()) {
+		t.Fatalf("%d txs can be mined, want only the funding tx", len(txs))
+	}
+}
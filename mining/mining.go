@@ -113,12 +113,19 @@ func (mm *MiningManager) StartMining(newBlock chan *twayutil.Block, tip []byte)
 	for stop == false {
 		var txs []twayutil.Transaction
 		for len(txs) == 0 {
-			txs = mempool.Mempool.PoolToTxSlice()
+			txs = mempool.Mempool.BlockTxs()
 			time.Sleep(time.Second * 1)
 		}
 		_, _, fees := b.GetTotalAmounts(txs)
 		time.Sleep(100 * time.Millisecond)
-		block := twayutil.NewBlock(txs, mm.tip, wallet.NewMiningWallet(), fees, mm.chain.GetNewBits())
+		payout, err := wallet.NewMiningWallet()
+		if err != nil {
+			//aucune adresse ne peut recevoir la récompense tant que le wallet est verrouillé
+			mm.Log(true, "can't get a payout address:", err)
+			time.Sleep(10 * time.Second)
+			continue
+		}
+		block := twayutil.NewBlock(txs, mm.tip, payout, fees, mm.chain.GetNewBits())
 		//Créer une target de proof of work
 		pow := b.NewProofOfWork(block)
 		mm.Log(true, "New block with", len(txs), "transactions in mempool is about to be mined")
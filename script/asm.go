@@ -0,0 +1,208 @@
+package script
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"tway/config"
+	"tway/keys"
+	"tway/util"
+)
+
+//Marqueurs utilisés par l'encodage hexadecimal d'un script
+//pour préfixer une donnée qui n'est pas un opcode.
+const (
+	OP_PUSHDATA1 = 0x4c // 76
+	OP_PUSHDATA2 = 0x4d // 77
+)
+
+// ScriptClass is an enumeration for the list of standard types of script.
+type ScriptClass byte
+
+// Classes of script recognised by the engine.
+const (
+	NonStandardTy ScriptClass = iota // None of the recognized forms.
+	PubKeyTy                         // Pay pubkey.
+	PubKeyHashTy                     // Pay pubkey hash.
+	MultiSigTy                       // Multi signature.
+	HTLCTy                           // Hash time locked contract.
+)
+
+var scriptClassToName = []string{
+	NonStandardTy: "nonstandard",
+	PubKeyTy:      "pubkey",
+	PubKeyHashTy:  "pubkeyhash",
+	MultiSigTy:    "multisig",
+	HTLCTy:        "htlc",
+}
+
+func (t ScriptClass) String() string {
+	if int(t) >= len(scriptClassToName) {
+		return "Invalid"
+	}
+	return scriptClassToName[t]
+}
+
+//Compile un script au format ASM (opcodes et données hexadecimales
+//séparés par un espace) vers le format utilisé par l'engine.
+//Exemple : "OP_DUP OP_HASH160 <pubKeyHash> OP_EQUALVERIFY OP_CHECKSIG"
+func (s *script) Compile(asm string) ([][]byte, error) {
+	var srpt [][]byte
+	for _, token := range strings.Fields(asm) {
+		if value, found := GetOpcodeValueByName(token); found == true {
+			srpt = append(srpt, []byte{value})
+			continue
+		}
+		data, err := hex.DecodeString(strings.Trim(token, "<>"))
+		if err != nil {
+			return nil, fmt.Errorf("unknown opcode or invalid hex data: %s", token)
+		}
+		srpt = append(srpt, data)
+	}
+	if len(srpt) == 0 {
+		return nil, errors.New("empty script")
+	}
+	return srpt, nil
+}
+
+//Encode un script en []byte
+//Un element d'un seul octet est un opcode, toute autre donnée est
+//précédée de OP_PUSHDATA1 <len> ou OP_PUSHDATA2 <len little endian>
+//OP_PUSHDATA1 et OP_PUSHDATA2 ne sont pas des opcodes : seuls, ils
+//seraient relus comme le préfixe d'une donnée et sont donc refusés
+func (s *script) Serialize(srpt [][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, elem := range srpt {
+		switch {
+		case len(elem) == 1 && (elem[0] == OP_PUSHDATA1 || elem[0] == OP_PUSHDATA2):
+			return nil, fmt.Errorf("script element 0x%x is a push prefix, not an opcode", elem[0])
+		case len(elem) == 1:
+			buf.WriteByte(elem[0])
+		case len(elem) <= 0xff:
+			buf.WriteByte(OP_PUSHDATA1)
+			buf.WriteByte(byte(len(elem)))
+			buf.Write(elem)
+		case len(elem) <= 0xffff:
+			var l [2]byte
+			binary.LittleEndian.PutUint16(l[:], uint16(len(elem)))
+			buf.WriteByte(OP_PUSHDATA2)
+			buf.Write(l[:])
+			buf.Write(elem)
+		default:
+			return nil, fmt.Errorf("script element too large: %d bytes", len(elem))
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+//Decode un script encodé avec Serialize
+func (s *script) Deserialize(data []byte) ([][]byte, error) {
+	var srpt [][]byte
+	i := 0
+	for i < len(data) {
+		op := data[i]
+		i++
+		var size int
+		switch op {
+		case OP_PUSHDATA1:
+			if i+1 > len(data) {
+				return nil, errors.New("malformed OP_PUSHDATA1")
+			}
+			size = int(data[i])
+			i++
+		case OP_PUSHDATA2:
+			if i+2 > len(data) {
+				return nil, errors.New("malformed OP_PUSHDATA2")
+			}
+			size = int(binary.LittleEndian.Uint16(data[i : i+2]))
+			i += 2
+		default:
+			srpt = append(srpt, []byte{op})
+			continue
+		}
+		if i+size > len(data) {
+			return nil, fmt.Errorf("push of %d bytes exceeds script length", size)
+		}
+		srpt = append(srpt, append([]byte{}, data[i:i+size]...))
+		i += size
+	}
+	return srpt, nil
+}
+
+//Hash un script (ripemd160(sha256(script)))
+func (s *script) Hash(srpt [][]byte) ([]byte, error) {
+	data, err := s.Serialize(srpt)
+	if err != nil {
+		return nil, err
+	}
+	return util.Ripemd160(util.Sha256(data)), nil
+}
+
+//Retourne true si l'element du script est l'opcode passé en paramètre
+func isOpcode(elem []byte, value byte) bool {
+	return len(elem) == 1 && elem[0] == value
+}
+
+//Retourne le nombre représenté par un opcode OP_DATA_1 à OP_DATA_16
+//ou -1 si l'element n'en est pas un
+func smallInt(elem []byte) int {
+	if len(elem) != 1 || elem[0] < OP_DATA_1 || elem[0] > OP_DATA_16 {
+		return -1
+	}
+	return int(elem[0])
+}
+
+//OP_DUP OP_HASH160 <pubKeyHash> OP_EQUALVERIFY OP_CHECKSIG
+func isPubKeyHashScript(srpt [][]byte) bool {
+	return len(srpt) == 5 &&
+		isOpcode(srpt[0], OP_DUP) &&
+		isOpcode(srpt[1], OP_HASH160) &&
+		len(srpt[2]) == config.PubKeyHLength &&
+		isOpcode(srpt[3], OP_EQUALVERIFY) &&
+		isOpcode(srpt[4], OP_CHECKSIG)
+}
+
+//<pubKey> OP_CHECKSIG
+func isPubKeyScript(srpt [][]byte) bool {
+	return len(srpt) == 2 &&
+		keys.IsPubKey(srpt[0]) &&
+		isOpcode(srpt[1], OP_CHECKSIG)
+}
+
+//N_SIG <pubkey>... N_PUBKEY OP_CHECKMULTISIG
+func isMultiSigScript(srpt [][]byte) bool {
+	l := len(srpt)
+	if l < 4 || isOpcode(srpt[l-1], OP_CHECKMULTISIG) == false {
+		return false
+	}
+	nSig := smallInt(srpt[0])
+	nPubKey := smallInt(srpt[l-2])
+	if nSig < 1 || nPubKey < nSig || nPubKey != l-3 {
+		return false
+	}
+	for _, pubKey := range srpt[1 : l-2] {
+		if keys.IsPubKey(pubKey) == false {
+			return false
+		}
+	}
+	return true
+}
+
+//Retourne la classe d'un scriptPubKey
+func (s *script) GetScriptClass(srpt [][]byte) ScriptClass {
+	switch {
+	case isPubKeyHashScript(srpt):
+		return PubKeyHashTy
+	case isPubKeyScript(srpt):
+		return PubKeyTy
+	case isMultiSigScript(srpt):
+		return MultiSigTy
+	case s.IsHTLCScript(srpt):
+		return HTLCTy
+	default:
+		return NonStandardTy
+	}
+}
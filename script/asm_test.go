@@ -0,0 +1,126 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+//Point générateur de P-256 au format compressé
+const testPubKey = "036b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c296"
+
+//Compile -> Serialize -> Deserialize -> String doit redonner l'ASM d'origine
+func TestScriptRoundTrip(t *testing.T) {
+	tests := []string{
+		"OP_DUP OP_HASH160 89abcdefabbaabbaabbaabbaabbaabbaabbaabba OP_EQUALVERIFY OP_CHECKSIG",
+		"OP_DATA_2 " + testPubKey + " " + testPubKey + " OP_DATA_2 OP_CHECKMULTISIG",
+		"OP_IF OP_SHA256 " + strings.Repeat("ab", 32) + " OP_EQUALVERIFY OP_ELSE OP_DATA_5 OP_CHECKLOCKTIMEVERIFY OP_DROP OP_ENDIF",
+		//donnée de plus de 255 octets encodée avec OP_PUSHDATA2
+		"OP_0 " + strings.Repeat("cd", 300) + " OP_DROP",
+		//donnée de deux octets commençant par un préfixe de push
+		"4c4d OP_DROP",
+	}
+	for _, asm := range tests {
+		srpt, err := Script.Compile(asm)
+		if err != nil {
+			t.Fatalf("compile %q: %v", asm, err)
+		}
+		encoded, err := Script.Serialize(srpt)
+		if err != nil {
+			t.Fatalf("serialize %q: %v", asm, err)
+		}
+		decoded, err := Script.Deserialize(encoded)
+		if err != nil {
+			t.Fatalf("deserialize %q: %v", asm, err)
+		}
+		if len(decoded) != len(srpt) {
+			t.Fatalf("%q: %d elements decoded, want %d", asm, len(decoded), len(srpt))
+		}
+		for i := range srpt {
+			if bytes.Compare(decoded[i], srpt[i]) != 0 {
+				t.Fatalf("%q: element %d is %x, want %x", asm, i, decoded[i], srpt[i])
+			}
+		}
+		if got := strings.TrimSpace(Script.String(decoded)); got != asm {
+			t.Fatalf("disassemble: got %q, want %q", got, asm)
+		}
+	}
+}
+
+//Le script compilé est identique à celui généré par le package et
+//s'exécute de la même façon dans l'engine après un aller-retour hex
+func TestCompileMatchesEngine(t *testing.T) {
+	tests := []struct {
+		asm       string
+		generated [][]byte
+		valid     bool
+	}{
+		{"OP_DATA_1 OP_DATA_4 OP_ADD OP_DATA_5 OP_EQUALVERIFY", Script.FiveEqualFive(), true},
+		{"OP_DATA_1 OP_DATA_3 OP_ADD OP_DATA_5 OP_EQUALVERIFY", Script.FourEqualFive(), false},
+	}
+	for _, test := range tests {
+		srpt, err := Script.Compile(test.asm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, _ := Script.Serialize(srpt)
+		expected, _ := Script.Serialize(test.generated)
+		if bytes.Compare(encoded, expected) != 0 {
+			t.Fatalf("%q: compiled to %x, want %x", test.asm, encoded, expected)
+		}
+		decoded, err := Script.Deserialize(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = NewEngine(nil, nil, 0).Run(decoded)
+		if (err == nil) != test.valid {
+			t.Fatalf("%q: run returned %v", test.asm, err)
+		}
+	}
+}
+
+func TestSerializeRejectsPushPrefix(t *testing.T) {
+	for _, prefix := range []byte{OP_PUSHDATA1, OP_PUSHDATA2} {
+		if _, err := Script.Serialize([][]byte{{prefix}, {OP_DROP}}); err == nil {
+			t.Fatalf("element 0x%x serialized", prefix)
+		}
+	}
+}
+
+func TestDeserializeMalformed(t *testing.T) {
+	tests := []string{
+		"4c",       //OP_PUSHDATA1 sans taille
+		"4d01",     //OP_PUSHDATA2 avec une taille tronquée
+		"4c0501",   //push de 5 octets, 1 seul présent
+		"4d0001ab", //push de 256 octets, 1 seul présent
+	}
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test)
+		if _, err := Script.Deserialize(data); err == nil {
+			t.Fatalf("%s deserialized", test)
+		}
+	}
+}
+
+func TestGetScriptClass(t *testing.T) {
+	tests := []struct {
+		asm   string
+		class ScriptClass
+	}{
+		{"OP_DUP OP_HASH160 89abcdefabbaabbaabbaabbaabbaabbaabbaabba OP_EQUALVERIFY OP_CHECKSIG", PubKeyHashTy},
+		{testPubKey + " OP_CHECKSIG", PubKeyTy},
+		{"OP_DATA_1 " + testPubKey + " " + testPubKey + " OP_DATA_2 OP_CHECKMULTISIG", MultiSigTy},
+		{"OP_DATA_3 " + testPubKey + " " + testPubKey + " OP_DATA_2 OP_CHECKMULTISIG", NonStandardTy},
+		{"OP_DATA_1 OP_DATA_4 OP_ADD OP_DATA_5 OP_EQUALVERIFY", NonStandardTy},
+	}
+	for _, test := range tests {
+		srpt, err := Script.Compile(test.asm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if class := Script.GetScriptClass(srpt); class != test.class {
+			t.Fatalf("%q: class %s, want %s", test.asm, class, test.class)
+		}
+	}
+}
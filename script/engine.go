@@ -6,15 +6,23 @@ import (
 	"tway/util"
 )
 
+// Conditional execution constants.
+const (
+	OpCondFalse = 0
+	OpCondTrue  = 1
+	OpCondSkip  = 2
+)
+
 // Engine is the virtual machine that executes scripts.
 type Engine struct {
 	scripts [][]parsedOpcode
 
-	dstack  stack // data stack
-	astack  stack // alt stack
-	tx      *util.Transaction
-	prevTxs map[string]*util.Transaction
-	txIdx   int
+	dstack    stack // data stack
+	astack    stack // alt stack
+	condStack []int
+	tx        *util.Transaction
+	prevTxs   map[string]*util.Transaction
+	txIdx     int
 }
 
 func (engine *Engine) PrintScript(idx int) {
@@ -62,6 +70,14 @@ func (engine *Engine) ParseScript(script [][]byte) error {
 	return nil
 }
 
+//Retourne true si la branche conditionnelle courante est executée
+func (engine *Engine) isBranchExecuting() bool {
+	if len(engine.condStack) == 0 {
+		return true
+	}
+	return engine.condStack[len(engine.condStack)-1] == OpCondTrue
+}
+
 //Si le script a l'index est vide
 func (engine *Engine) IsScriptEmpty(index int) bool {
 	return len(engine.scripts[index]) == 0
@@ -82,11 +98,18 @@ func (engine *Engine) Run(initialScript [][]byte) error {
 	//	engine.PrintScript(0)
 	var i = 0
 	for i < len(engine.scripts[0]) {
+		code := &engine.scripts[0][i]
+		if code.opcode.opfunc == nil {
+			return errors.New("unknown opcode")
+		}
 		//Pour chaque ordre du script, effectue la function correspondante
 		//push une valeur a la stake || effectue une action
-		err := engine.scripts[0][i].opcode.opfunc(&engine.scripts[0][i], engine)
-		if err != nil {
-			return err
+		//seuls les opcodes conditionnels sont executés dans une branche ignorée
+		if engine.isBranchExecuting() || code.isConditional() {
+			err := code.opcode.opfunc(code, engine)
+			if err != nil {
+				return err
+			}
 		}
 		var newLineScript []parsedOpcode
 		var j = i + 1
@@ -105,6 +128,9 @@ func (engine *Engine) Run(initialScript [][]byte) error {
 		//		engine.PrintScript(i + 1)
 		i++
 	}
+	if len(engine.condStack) != 0 {
+		return errors.New("end of script reached in conditional execution")
+	}
 	return nil
 }
 
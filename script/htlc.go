@@ -0,0 +1,103 @@
+package script
+
+import (
+	"errors"
+	"tway/config"
+	"tway/util"
+)
+
+//Taille du secret (preimage) d'un HTLC
+const HTLCPreimageLength = 32
+
+//Informations contenues dans un script HTLC
+type HTLCInfo struct {
+	Hash             []byte //sha256 du secret
+	RecipientPubKeyH []byte //pubKeyHash pouvant dépenser l'output avec le secret
+	RefundPubKeyH    []byte //pubKeyHash pouvant récupérer l'output après expiration
+	LockTime         int    //hauteur de block à partir de laquelle le remboursement est possible
+}
+
+//Generation d'un script de type Hash Time Locked Contract (ScriptPubKey)
+//Le destinataire dépense l'output en révélant le secret dont le hash est <hash>,
+//l'émetteur peut récupérer les fonds une fois la hauteur <lockTime> atteinte.
+//
+//OP_IF
+//	OP_SHA256 <hash> OP_EQUALVERIFY OP_DUP OP_HASH160 <recipientPubKeyHash>
+//OP_ELSE
+//	<lockTime> OP_CHECKLOCKTIMEVERIFY OP_DROP OP_DUP OP_HASH160 <refundPubKeyHash>
+//OP_ENDIF
+//OP_EQUALVERIFY OP_CHECKSIG
+func (s *script) HTLCLockingScript(hash, recipientPubKeyH, refundPubKeyH []byte, lockTime int) [][]byte {
+	return util.DupByteDoubleArray(
+		append([]byte{}, OP_IF),
+		append([]byte{}, OP_SHA256),
+		hash,
+		append([]byte{}, OP_EQUALVERIFY),
+		append([]byte{}, OP_DUP),
+		append([]byte{}, OP_HASH160),
+		recipientPubKeyH,
+		append([]byte{}, OP_ELSE),
+		util.EncodeInt(lockTime),
+		append([]byte{}, OP_CHECKLOCKTIMEVERIFY),
+		append([]byte{}, OP_DROP),
+		append([]byte{}, OP_DUP),
+		append([]byte{}, OP_HASH160),
+		refundPubKeyH,
+		append([]byte{}, OP_ENDIF),
+		append([]byte{}, OP_EQUALVERIFY),
+		append([]byte{}, OP_CHECKSIG),
+	)
+}
+
+//Generation du script d'input permettant de dépenser un HTLC avec le secret (ScriptSig)
+//<signature> <pubKey> <preimage> 1
+func (s *script) HTLCClaimScript(signature, pubKey, preimage []byte) [][]byte {
+	return util.DupByteDoubleArray(
+		signature,
+		pubKey,
+		preimage,
+		append([]byte{}, OP_DATA_1),
+	)
+}
+
+//Generation du script d'input permettant de récupérer un HTLC expiré (ScriptSig)
+//<signature> <pubKey> 0
+func (s *script) HTLCRefundScript(signature, pubKey []byte) [][]byte {
+	return util.DupByteDoubleArray(
+		signature,
+		pubKey,
+		append([]byte{}, OP_0),
+	)
+}
+
+//Retourne true si le script est un HTLC
+func (s *script) IsHTLCScript(srpt [][]byte) bool {
+	_, err := s.GetHTLCInfo(srpt)
+	return err == nil
+}
+
+//Récupère les informations d'un script HTLC
+func (s *script) GetHTLCInfo(srpt [][]byte) (*HTLCInfo, error) {
+	if len(srpt) != 17 {
+		return nil, errors.New("not a HTLC script")
+	}
+	opcodes := map[int]byte{
+		0: OP_IF, 1: OP_SHA256, 3: OP_EQUALVERIFY, 4: OP_DUP, 5: OP_HASH160,
+		7: OP_ELSE, 9: OP_CHECKLOCKTIMEVERIFY, 10: OP_DROP, 11: OP_DUP, 12: OP_HASH160,
+		14: OP_ENDIF, 15: OP_EQUALVERIFY, 16: OP_CHECKSIG,
+	}
+	for idx, value := range opcodes {
+		if isOpcode(srpt[idx], value) == false {
+			return nil, errors.New("not a HTLC script")
+		}
+	}
+	if len(srpt[2]) != 32 || len(srpt[6]) != config.PubKeyHLength || len(srpt[13]) != config.PubKeyHLength || len(srpt[8]) < 2 {
+		return nil, errors.New("not a HTLC script")
+	}
+	return &HTLCInfo{
+		Hash:             srpt[2],
+		RecipientPubKeyH: srpt[6],
+		RefundPubKeyH:    srpt[13],
+		LockTime:         util.DecodeInt(srpt[8]),
+	}, nil
+}
@@ -0,0 +1,110 @@
+package script
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"testing"
+	"tway/keys"
+	"tway/util"
+)
+
+const htlcTestLockTime = 100
+
+type htlcTest struct {
+	recipient, refund *ecdsa.PrivateKey
+	preimage          []byte
+	lockingScript     [][]byte
+}
+
+func newHTLCTest(t *testing.T) *htlcTest {
+	privs, _ := newTestKeys(t, 2)
+	h := &htlcTest{recipient: privs[0], refund: privs[1], preimage: util.Sha256([]byte("htlc secret"))}
+	h.lockingScript = Script.HTLCLockingScript(
+		util.Sha256(h.preimage),
+		keys.PubKeyFromPrivate(h.recipient).Hash160(),
+		keys.PubKeyFromPrivate(h.refund).Hash160(),
+		htlcTestLockTime,
+	)
+	return h
+}
+
+//Execute la dépense du HTLC par une transaction de locktime lockTime
+//unlock retourne le scriptSig à partir de la signature de la transaction
+func (h *htlcTest) spend(t *testing.T, lockTime int, signer *ecdsa.PrivateKey, unlock func(sig, pubKey []byte) [][]byte) (bool, error) {
+	prevTx := &util.Transaction{Version: []byte{1}, Outputs: []util.Output{{Value: util.EncodeInt(1000), ScriptPubKey: h.lockingScript}}}
+	prevHash := util.Sha256(prevTx.Serialize())
+	tx := &util.Transaction{
+		Version:  []byte{1},
+		Inputs:   []util.Input{{PrevTransactionHash: prevHash, Vout: util.EncodeInt(0)}},
+		Outputs:  []util.Output{{Value: util.EncodeInt(900)}},
+		LockTime: util.EncodeInt(lockTime),
+	}
+	sig, err := util.Sign(signer, util.SigHash(tx, 0, prevTx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey := keys.PubKeyFromPrivate(signer).SerializeCompressed()
+	prevTxs := map[string]*util.Transaction{hex.EncodeToString(prevHash): prevTx}
+	return runScript(tx, prevTxs, append(unlock(sig, pubKey), h.lockingScript...))
+}
+
+func (h *htlcTest) claim(preimage []byte) func(sig, pubKey []byte) [][]byte {
+	return func(sig, pubKey []byte) [][]byte {
+		return Script.HTLCClaimScript(sig, pubKey, preimage)
+	}
+}
+
+func htlcRefund(sig, pubKey []byte) [][]byte {
+	return Script.HTLCRefundScript(sig, pubKey)
+}
+
+func TestHTLCClaim(t *testing.T) {
+	h := newHTLCTest(t)
+	if ok, err := h.spend(t, 0, h.recipient, h.claim(h.preimage)); err != nil || ok == false {
+		t.Fatalf("claim rejected: result %v, err %v", ok, err)
+	}
+	wrong := append([]byte{}, h.preimage...)
+	wrong[31] ^= 0x01
+	if ok, err := h.spend(t, 0, h.recipient, h.claim(wrong)); err == nil && ok {
+		t.Fatal("claim with a wrong preimage accepted")
+	}
+	//le secret ne suffit pas, la clé du destinataire est requise
+	if ok, err := h.spend(t, 0, h.refund, h.claim(h.preimage)); err == nil && ok {
+		t.Fatal("claim signed by the refund key accepted")
+	}
+}
+
+func TestHTLCRefund(t *testing.T) {
+	h := newHTLCTest(t)
+	tests := []struct {
+		name     string
+		lockTime int
+		signer   *ecdsa.PrivateKey
+		valid    bool
+	}{
+		{"before locktime", htlcTestLockTime - 1, h.refund, false},
+		{"at locktime", htlcTestLockTime, h.refund, true},
+		{"after locktime", htlcTestLockTime + 10, h.refund, true},
+		{"recipient key", htlcTestLockTime, h.recipient, false},
+	}
+	for _, test := range tests {
+		ok, err := h.spend(t, test.lockTime, test.signer, htlcRefund)
+		if (err == nil && ok) != test.valid {
+			t.Fatalf("%s: result %v, err %v", test.name, ok, err)
+		}
+	}
+}
+
+func TestGetHTLCInfo(t *testing.T) {
+	h := newHTLCTest(t)
+	info, err := Script.GetHTLCInfo(h.lockingScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.LockTime != htlcTestLockTime || hex.EncodeToString(info.Hash) != hex.EncodeToString(util.Sha256(h.preimage)) {
+		t.Fatalf("decoded %+v", *info)
+	}
+	if Script.IsHTLCScript(h.lockingScript[:16]) {
+		t.Fatal("truncated script recognized as a HTLC")
+	}
+}
@@ -2,13 +2,10 @@ package script
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"crypto/elliptic"
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"math/big"
-	"tway/config"
+	"tway/keys"
 	"tway/util"
 )
 
@@ -60,14 +57,21 @@ const (
 	OP_DATA_15 = 0x0f // 15
 	OP_DATA_16 = 0x10 // 16
 
-	OP_DUP            = 0x76 // 118
-	OP_EQUALVERIFY    = 0x88 // 136
-	OP_ADD            = 0x93 // 147
-	OP_SUB            = 0x94 // 148
-	OP_HASH160        = 0xa9 // 169
-	OP_CHECKSIG       = 0xac // 172
-	OP_CHECKSIGVERIFY = 0xad // 173
-	OP_CHECKMULTISIG  = 0xae // 174
+	OP_IF                  = 0x63 // 99
+	OP_ELSE                = 0x67 // 103
+	OP_ENDIF               = 0x68 // 104
+	OP_DROP                = 0x75 // 117
+	OP_DUP                 = 0x76 // 118
+	OP_EQUALVERIFY         = 0x88 // 136
+	OP_ADD                 = 0x93 // 147
+	OP_SUB                 = 0x94 // 148
+	OP_SHA256              = 0xa8 // 168
+	OP_HASH160             = 0xa9 // 169
+	OP_CHECKSIG            = 0xac // 172
+	OP_CHECKSIGVERIFY      = 0xad // 173
+	OP_CHECKMULTISIG       = 0xae // 174
+	OP_CHECKMULTISIGVERIFY = 0xaf // 175
+	OP_CHECKLOCKTIMEVERIFY = 0xb1 // 177
 )
 
 var opcodeArray = [256]opcode{
@@ -95,8 +99,17 @@ var opcodeArray = [256]opcode{
 	OP_SUB:            {OP_SUB, "OP_SUB", 1, opcodeSub},
 	OP_HASH160:        {OP_HASH160, "OP_HASH160", 1, opcodeHash160},
 	OP_CHECKSIG:       {OP_CHECKSIG, "OP_CHECKSIG", 1, opcodeCheckSig},
-	OP_CHECKSIGVERIFY: {OP_CHECKSIGVERIFY, "OP_CHECKSIGVERIFY", 1, nil},
+	OP_CHECKSIGVERIFY: {OP_CHECKSIGVERIFY, "OP_CHECKSIGVERIFY", 1, opcodeCheckSigVerify},
 	OP_CHECKMULTISIG:  {OP_CHECKMULTISIG, "OP_CHECKMULTISIG", 1, opcodeCheckMultiSig},
+
+	OP_CHECKMULTISIGVERIFY: {OP_CHECKMULTISIGVERIFY, "OP_CHECKMULTISIGVERIFY", 1, opcodeCheckMultiSigVerify},
+	OP_CHECKLOCKTIMEVERIFY: {OP_CHECKLOCKTIMEVERIFY, "OP_CHECKLOCKTIMEVERIFY", 1, opcodeCheckLockTimeVerify},
+
+	OP_IF:     {OP_IF, "OP_IF", 1, opcodeIf},
+	OP_ELSE:   {OP_ELSE, "OP_ELSE", 1, opcodeElse},
+	OP_ENDIF:  {OP_ENDIF, "OP_ENDIF", 1, opcodeEndif},
+	OP_DROP:   {OP_DROP, "OP_DROP", 1, opcodeDrop},
+	OP_SHA256: {OP_SHA256, "OP_SHA256", 1, opcodeSha256},
 }
 
 func GetOpcodeValueByName(name string) (byte, bool) {
@@ -136,6 +149,23 @@ func (code *parsedOpcode) IsAction() bool {
 		return true
 	case OP_CHECKMULTISIG:
 		return true
+	case OP_CHECKMULTISIGVERIFY:
+		return true
+	case OP_CHECKLOCKTIMEVERIFY:
+		return true
+	case OP_IF, OP_ELSE, OP_ENDIF, OP_DROP, OP_SHA256:
+		return true
+	default:
+		return false
+	}
+}
+
+//Retourne true si l'opcode controle l'execution conditionnelle
+//Ces opcodes sont executés même dans une branche non executée
+func (code *parsedOpcode) isConditional() bool {
+	switch code.opcode.value {
+	case OP_IF, OP_ELSE, OP_ENDIF:
+		return len(code.data) == 1
 	default:
 		return false
 	}
@@ -146,6 +176,87 @@ func opcodePushData(op *parsedOpcode, vm *Engine) error {
 	return nil
 }
 
+// opcodeIf treats the top item on the data stack as a boolean and removes it.
+// The statements up to the matching OP_ELSE or OP_ENDIF are executed only if
+// it evaluates to true.  When the current branch is not executed, nothing is
+// popped and the whole nested block is skipped.
+//
+// Stack transformation: [... bool] -> [...]
+func opcodeIf(op *parsedOpcode, vm *Engine) error {
+	condVal := OpCondFalse
+	if vm.isBranchExecuting() {
+		ok, err := vm.dstack.PopBool()
+		if err != nil {
+			return err
+		}
+		if ok {
+			condVal = OpCondTrue
+		}
+	} else {
+		condVal = OpCondSkip
+	}
+	vm.condStack = append(vm.condStack, condVal)
+	return nil
+}
+
+// opcodeElse inverts conditional execution for other half of if/else/endif.
+//
+// Stack transformation: [...] -> [...]
+func opcodeElse(op *parsedOpcode, vm *Engine) error {
+	if len(vm.condStack) == 0 {
+		return errors.New("encountered opcode OP_ELSE with no matching opcode to begin conditional execution")
+	}
+	idx := len(vm.condStack) - 1
+	switch vm.condStack[idx] {
+	case OpCondTrue:
+		vm.condStack[idx] = OpCondFalse
+	case OpCondFalse:
+		vm.condStack[idx] = OpCondTrue
+	}
+	return nil
+}
+
+// opcodeEndif terminates a conditional block, removing the value from the
+// conditional execution stack.
+//
+// Stack transformation: [...] -> [...]
+func opcodeEndif(op *parsedOpcode, vm *Engine) error {
+	if len(vm.condStack) == 0 {
+		return errors.New("encountered opcode OP_ENDIF with no matching opcode to begin conditional execution")
+	}
+	vm.condStack = vm.condStack[:len(vm.condStack)-1]
+	return nil
+}
+
+// opcodeDrop removes the top item from the data stack.
+//
+// Stack transformation: [... x1 x2 x3] -> [... x1 x2]
+func opcodeDrop(op *parsedOpcode, vm *Engine) error {
+	_, err := vm.dstack.Pop()
+	return err
+}
+
+// opcodeCheckLockTimeVerify compares the top item on the data stack, a block
+// height encoded like the transaction lock time, with the lock time of the
+// transaction being validated.  An error is returned if the transaction lock
+// time is lower.  The top item is not removed.
+//
+// Stack transformation: [... x1] -> [... x1]
+func opcodeCheckLockTimeVerify(op *parsedOpcode, vm *Engine) error {
+	if len(vm.dstack.stk) == 0 {
+		return errors.New("empty stack")
+	}
+	lockTime := util.DecodeInt(vm.dstack.stk[len(vm.dstack.stk)-1])
+	if lockTime < 0 {
+		return errors.New("negative lock time")
+	}
+	txLockTime := util.DecodeInt(vm.tx.LockTime)
+	if txLockTime < lockTime {
+		return fmt.Errorf("locktime requirement not satisfied -- locktime is greater than the transaction locktime: %d > %d", lockTime, txLockTime)
+	}
+	return nil
+}
+
 // opcodeDup duplicates the top item on the data stack.
 //
 // Stack transformation: [... x1 x2 x3] -> [... x1 x2 x3 x3]
@@ -170,6 +281,21 @@ func opcodeEqual(op *parsedOpcode, engine *Engine) error {
 	return nil
 }
 
+// opcodeVerify examines the top item on the data stack as a boolean value and
+// verifies it evaluates to true.  An error is returned if it does not.
+//
+// Stack transformation: [... bool] -> [...]
+func opcodeVerify(op *parsedOpcode, engine *Engine) error {
+	verified, err := engine.dstack.PopBool()
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return errors.New("error from opcodeVerify. Failed " + op.opcode.name)
+	}
+	return nil
+}
+
 // opcodeEqualVerify is a combination of opcodeEqual and opcodeVerify.
 // Specifically, it removes the top 2 items of the data stack, compares them,
 // and pushes the result, encoded as a boolean, back to the stack.  Then, it
@@ -229,6 +355,19 @@ func opcodeSub(op *parsedOpcode, engine *Engine) error {
 	return nil
 }
 
+// opcodeSha256 treats the top item of the data stack as raw bytes and replaces
+// it with sha256(data).
+//
+// Stack transformation: [... x1] -> [... sha256(x1)]
+func opcodeSha256(op *parsedOpcode, vm *Engine) error {
+	buf, err := vm.dstack.Pop()
+	if err != nil {
+		return err
+	}
+	vm.dstack.Push(util.Sha256(buf))
+	return nil
+}
+
 // opcodeHash160 treats the top item of the data stack as raw bytes and replaces
 // it with ripemd160(sha256(data)).
 //
@@ -244,6 +383,34 @@ func opcodeHash160(op *parsedOpcode, vm *Engine) error {
 	return nil
 }
 
+//Retourne le hash signé par les inputs de la transaction executée par l'engine
+func (vm *Engine) sigHash() ([]byte, error) {
+	txid := hex.EncodeToString(vm.tx.Inputs[vm.txIdx].PrevTransactionHash)
+	prevTx, exist := vm.prevTxs[txid]
+	if exist == false || prevTx == nil {
+		return nil, errors.New("previous transaction not found")
+	}
+	return util.SigHash(vm.tx, vm.txIdx, prevTx), nil
+}
+
+//Verifie une signature avec une clé publique sur le hash signé
+//Les signatures valides sont gardées dans le cache des signatures
+func verifySignature(pkBytes, sigBytes, hash []byte) bool {
+	if SigCache.Exists(hash, pkBytes, sigBytes) {
+		return true
+	}
+	pubKey, err := keys.ParsePubKey(pkBytes)
+	if err != nil {
+		return false
+	}
+	//la signature doit être encodée en DER strict avec un S bas
+	if pubKey.Verify(hash, sigBytes) == false {
+		return false
+	}
+	SigCache.Add(hash, pkBytes, sigBytes)
+	return true
+}
+
 // Stack transformation: [... signature pubkey] -> [... bool]
 func opcodeCheckSig(op *parsedOpcode, vm *Engine) error {
 	pkBytes, err := vm.dstack.Pop()
@@ -251,63 +418,58 @@ func opcodeCheckSig(op *parsedOpcode, vm *Engine) error {
 		return err
 	}
 
-	x := big.Int{}
-	y := big.Int{}
-	keyLen := len(pkBytes)
-	x.SetBytes(pkBytes[:(keyLen / 2)])
-	y.SetBytes(pkBytes[(keyLen / 2):])
-
-	fullSigBytes, err := vm.dstack.Pop()
+	sigBytes, err := vm.dstack.Pop()
 	if err != nil {
 		return err
 	}
 
-	r := big.Int{}
-	s := big.Int{}
-	sigLen := len(fullSigBytes)
-	r.SetBytes(fullSigBytes[:(sigLen / 2)])
-	s.SetBytes(fullSigBytes[(sigLen / 2):])
-
-	txid := hex.EncodeToString(vm.tx.Inputs[vm.txIdx].PrevTransactionHash)
-
-	curve := elliptic.P256()
-
-	rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
-	var valid bool
-	if ecdsa.Verify(&rawPubKey, vm.prevTxs[txid].Serialize(), &r, &s) == false {
-		valid = false
-	} else {
-		valid = true
+	hash, err := vm.sigHash()
+	if err != nil {
+		return err
 	}
-	vm.dstack.PushBool(valid)
+	vm.dstack.PushBool(verifySignature(pkBytes, sigBytes, hash))
 	return nil
 }
 
-// parsedSigInfo houses a raw signature along with its parsed form and a flag
-// for whether or not it has already been parsed.  It is used to prevent parsing
-// the same signature multiple times when verifying a multisig.
-type parsedSigInfo struct {
-	signature []byte
-	parsed    bool
+// opcodeCheckSigVerify is a combination of opcodeCheckSig and opcodeVerify.
+//
+// Stack transformation: [... signature pubkey] -> [... bool] -> [...]
+func opcodeCheckSigVerify(op *parsedOpcode, vm *Engine) error {
+	if err := opcodeCheckSig(op, vm); err != nil {
+		return err
+	}
+	return opcodeVerify(op, vm)
 }
 
+// opcodeCheckMultiSig treats the top item on the stack as an integer number of
+// public keys, followed by that many entries as raw data representing the public
+// keys, followed by the integer number of signatures, followed by that many
+// entries as raw data representing the signatures.
+//
+// Signatures must be given in the same order as the public keys they match.
+// A public key which does not match the current signature is skipped and can
+// not be used again, so the script fails as soon as there are more signatures
+// left than public keys.
+//
+// Stack transformation:
+// [... sig1 ... sigM <numsigs> pubkey1 ... pubkeyN <numpubkeys>] -> [... bool]
 func opcodeCheckMultiSig(op *parsedOpcode, vm *Engine) error {
 	nPubk, err := vm.dstack.PopInt()
 	if err != nil {
 		return err
 	}
-
 	if nPubk < 0 {
-		return errors.New("less than 0 pubk")
+		return fmt.Errorf("number of pubkeys '%d' is less than 0", nPubk)
 	}
 
-	pubKeys := make([][]byte, 0, nPubk)
-	for i := 0; i < nPubk; i++ {
+	//les clés publiques sont dépilées en ordre inverse de leur position dans le script
+	pubKeys := make([][]byte, nPubk)
+	for i := nPubk - 1; i >= 0; i-- {
 		pubKey, err := vm.dstack.Pop()
 		if err != nil {
 			return err
 		}
-		pubKeys = append(pubKeys, pubKey)
+		pubKeys[i] = pubKey
 	}
 
 	nSigs, err := vm.dstack.PopInt()
@@ -323,59 +485,46 @@ func opcodeCheckMultiSig(op *parsedOpcode, vm *Engine) error {
 			nSigs, nPubk)
 	}
 
-	var signatures []*parsedSigInfo
-	for len(vm.dstack.stk) > 0 {
+	signatures := make([][]byte, nSigs)
+	for i := nSigs - 1; i >= 0; i-- {
 		signature, err := vm.dstack.Pop()
 		if err != nil {
 			return err
 		}
-		sigInfo := &parsedSigInfo{signature: signature}
-		signatures = append(signatures, sigInfo)
+		signatures[i] = signature
 	}
 
-	fmt.Println(" signatures:")
-	for idx, sig := range signatures {
-		fmt.Printf("[%d] %s \n", idx, hex.EncodeToString(sig.signature))
-	}
-	fmt.Println("\n pubkeys")
-	for idx, pubk := range pubKeys {
-		fmt.Printf("[%d] %s \n", idx, hex.EncodeToString(pubk))
+	hash, err := vm.sigHash()
+	if err != nil {
+		return err
 	}
 
-	curve := elliptic.P256()
-	txid := hex.EncodeToString(vm.tx.Inputs[vm.txIdx].PrevTransactionHash)
-	success := 0
-	for i := 0; i < len(signatures); i++ {
-
-		pubk := pubKeys[i]
-		sigBytes := signatures[i].signature
-
-		if len(sigBytes) != config.SigLength {
-			continue
+	success := true
+	sigIdx, pubKeyIdx := 0, 0
+	for sigIdx < nSigs {
+		//il reste plus de signatures que de clés publiques
+		if nSigs-sigIdx > nPubk-pubKeyIdx {
+			success = false
+			break
 		}
-
-		x := big.Int{}
-		y := big.Int{}
-		keyLen := len(pubk)
-		x.SetBytes(pubk[:(keyLen / 2)])
-		y.SetBytes(pubk[(keyLen / 2):])
-
-		r := big.Int{}
-		s := big.Int{}
-		sigLen := len(sigBytes)
-		r.SetBytes(sigBytes[:(sigLen / 2)])
-		s.SetBytes(sigBytes[(sigLen / 2):])
-
-		rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
-
-		if ecdsa.Verify(&rawPubKey, vm.prevTxs[txid].Serialize(), &r, &s) == true {
-			success++
+		if verifySignature(pubKeys[pubKeyIdx], signatures[sigIdx], hash) {
+			sigIdx++
 		}
-		fmt.Println(success)
+		pubKeyIdx++
 	}
 
-	if success >= nSigs {
-		vm.dstack.PushBool(true)
-	}
+	vm.dstack.PushBool(success)
 	return nil
 }
+
+// opcodeCheckMultiSigVerify is a combination of opcodeCheckMultiSig and
+// opcodeVerify.
+//
+// Stack transformation:
+// [... sig1 ... sigM <numsigs> pubkey1 ... pubkeyN <numpubkeys>] -> [...]
+func opcodeCheckMultiSigVerify(op *parsedOpcode, vm *Engine) error {
+	if err := opcodeCheckMultiSig(op, vm); err != nil {
+		return err
+	}
+	return opcodeVerify(op, vm)
+}
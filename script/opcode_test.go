@@ -0,0 +1,242 @@
+package script
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"testing"
+	"tway/keys"
+	"tway/util"
+)
+
+//Transaction dépensant l'output d'une transaction précédente et hash signé par son input
+func multiSigTestTx(t *testing.T) (*util.Transaction, map[string]*util.Transaction, []byte) {
+	prevHash := util.Sha256([]byte("prev"))
+	prevTx := &util.Transaction{Version: []byte{1}, Outputs: []util.Output{{Value: []byte{5}}}}
+	tx := &util.Transaction{Version: []byte{1}, Inputs: []util.Input{{PrevTransactionHash: prevHash, Vout: []byte{0}}}}
+	prevTxs := map[string]*util.Transaction{hex.EncodeToString(prevHash): prevTx}
+	return tx, prevTxs, util.SigHash(tx, 0, prevTx)
+}
+
+func newTestKeys(t *testing.T, n int) ([]*ecdsa.PrivateKey, [][]byte) {
+	var privs []*ecdsa.PrivateKey
+	var pubKeys [][]byte
+	for i := 0; i < n; i++ {
+		priv, err := keys.NewPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		privs = append(privs, priv)
+		pubKeys = append(pubKeys, keys.PubKeyFromPrivate(priv).SerializeCompressed())
+	}
+	return privs, pubKeys
+}
+
+//Execute <signatures> <scriptPubKey multisig> et retourne le résultat
+//laissé sur la stack et l'erreur de l'engine
+func runMultiSig(t *testing.T, nSig int, pubKeys [][]byte, signers []*ecdsa.PrivateKey, verify bool) (bool, error) {
+	tx, prevTxs, hash := multiSigTestTx(t)
+	var signatures [][]byte
+	for _, priv := range signers {
+		sig, err := util.Sign(priv, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signatures = append(signatures, sig)
+	}
+	scriptPubKey := Script.MultisigScriptPubKey(pubKeys, nSig)
+	if verify {
+		//OP_CHECKMULTISIGVERIFY ne laisse rien sur la stack, on y ajoute true
+		scriptPubKey[len(scriptPubKey)-1] = []byte{OP_CHECKMULTISIGVERIFY}
+		scriptPubKey = append(scriptPubKey, []byte{OP_DATA_1})
+	}
+	srpt := append(Script.MultiSigUnlockingScript(signatures), scriptPubKey...)
+	engine := NewEngine(prevTxs, tx, 0)
+	if err := engine.Run(srpt); err != nil {
+		return false, err
+	}
+	return engine.IsScriptSucceed(), nil
+}
+
+func TestCheckMultiSig(t *testing.T) {
+	privs, pubKeys := newTestKeys(t, 3)
+	tests := []struct {
+		name    string
+		nSig    int
+		pubKeys [][]byte
+		signers []*ecdsa.PrivateKey
+		//l'engine doit terminer sans erreur et laisser false sur la stack
+		pushFalse bool
+		valid     bool
+	}{
+		{"1-of-1", 1, pubKeys[:1], privs[:1], false, true},
+		{"1-of-1 wrong key", 1, pubKeys[:1], privs[1:2], true, false},
+		{"2-of-3 first and second", 2, pubKeys, privs[:2], false, true},
+		{"2-of-3 first and third", 2, pubKeys, []*ecdsa.PrivateKey{privs[0], privs[2]}, false, true},
+		{"2-of-3 second and third", 2, pubKeys, privs[1:], false, true},
+		{"2-of-3 out of order", 2, pubKeys, []*ecdsa.PrivateKey{privs[1], privs[0]}, true, false},
+		{"2-of-3 third then first", 2, pubKeys, []*ecdsa.PrivateKey{privs[2], privs[0]}, true, false},
+		{"2-of-3 reused key", 2, pubKeys, []*ecdsa.PrivateKey{privs[0], privs[0]}, true, false},
+		{"3-of-3", 3, pubKeys, privs, false, true},
+		{"3-of-3 reversed", 3, pubKeys, []*ecdsa.PrivateKey{privs[2], privs[1], privs[0]}, true, false},
+	}
+	for _, test := range tests {
+		ok, err := runMultiSig(t, test.nSig, test.pubKeys, test.signers, false)
+		if test.pushFalse && err != nil {
+			t.Fatalf("%s: engine failed instead of pushing false: %v", test.name, err)
+		}
+		if ok != test.valid {
+			t.Fatalf("%s: result %v, want %v (err %v)", test.name, ok, test.valid, err)
+		}
+	}
+}
+
+//Il manque des signatures sur la stack : le script échoue
+func TestCheckMultiSigTooFewSignatures(t *testing.T) {
+	privs, pubKeys := newTestKeys(t, 3)
+	if ok, err := runMultiSig(t, 2, pubKeys, privs[:1], false); ok || err == nil {
+		t.Fatalf("2-of-3 with one signature: result %v, err %v", ok, err)
+	}
+	if ok, err := runMultiSig(t, 2, pubKeys, nil, false); ok || err == nil {
+		t.Fatalf("2-of-3 without signature: result %v, err %v", ok, err)
+	}
+}
+
+func TestCheckMultiSigVerify(t *testing.T) {
+	privs, pubKeys := newTestKeys(t, 3)
+	if ok, err := runMultiSig(t, 2, pubKeys, privs[:2], true); err != nil || ok == false {
+		t.Fatalf("valid signatures: result %v, err %v", ok, err)
+	}
+	//une signature invalide arrête le script avec une erreur
+	if _, err := runMultiSig(t, 2, pubKeys, []*ecdsa.PrivateKey{privs[1], privs[0]}, true); err == nil {
+		t.Fatal("out of order signatures verified")
+	}
+	if _, err := runMultiSig(t, 2, pubKeys, []*ecdsa.PrivateKey{privs[0], privs[0]}, true); err == nil {
+		t.Fatal("reused key verified")
+	}
+}
+
+//Execute un script pour l'input 0 de tx et retourne le résultat laissé sur la stack
+func runScript(tx *util.Transaction, prevTxs map[string]*util.Transaction, srpt [][]byte) (bool, error) {
+	engine := NewEngine(prevTxs, tx, 0)
+	if err := engine.Run(srpt); err != nil {
+		return false, err
+	}
+	return engine.IsScriptSucceed(), nil
+}
+
+func ops(values ...byte) [][]byte {
+	var srpt [][]byte
+	for _, v := range values {
+		srpt = append(srpt, []byte{v})
+	}
+	return srpt
+}
+
+func TestConditional(t *testing.T) {
+	tests := []struct {
+		name  string
+		srpt  [][]byte
+		valid bool
+	}{
+		{"if true", ops(OP_DATA_1, OP_IF, OP_DATA_1, OP_ELSE, OP_0, OP_ENDIF), true},
+		{"if false", ops(OP_0, OP_IF, OP_DATA_1, OP_ELSE, OP_0, OP_ENDIF), false},
+		{"else branch", ops(OP_0, OP_IF, OP_0, OP_ELSE, OP_DATA_1, OP_ENDIF), true},
+		{"nested", ops(OP_DATA_1, OP_IF, OP_0, OP_IF, OP_0, OP_ELSE, OP_DATA_1, OP_ENDIF, OP_ELSE, OP_0, OP_ENDIF), true},
+		{"nested false", ops(OP_DATA_1, OP_IF, OP_DATA_1, OP_IF, OP_0, OP_ELSE, OP_DATA_1, OP_ENDIF, OP_ELSE, OP_DATA_1, OP_ENDIF), false},
+		//l'OP_IF d'une branche ignorée ne consomme rien sur la stack
+		{"nested in skipped branch", ops(OP_0, OP_IF, OP_DATA_1, OP_IF, OP_0, OP_ENDIF, OP_0, OP_ELSE, OP_DATA_1, OP_ENDIF), true},
+		{"skipped else", ops(OP_0, OP_IF, OP_DATA_1, OP_IF, OP_0, OP_ELSE, OP_0, OP_ENDIF, OP_ELSE, OP_DATA_1, OP_ENDIF), true},
+	}
+	tx, prevTxs, _ := multiSigTestTx(t)
+	for _, test := range tests {
+		ok, err := runScript(tx, prevTxs, test.srpt)
+		if err != nil || ok != test.valid {
+			t.Fatalf("%s: result %v, err %v", test.name, ok, err)
+		}
+	}
+}
+
+//Un OP_IF sans OP_ENDIF, ou un OP_ELSE/OP_ENDIF sans OP_IF, arrête le script avec une erreur
+func TestConditionalUnbalanced(t *testing.T) {
+	tests := []struct {
+		name string
+		srpt [][]byte
+	}{
+		{"missing endif", ops(OP_DATA_1, OP_IF, OP_DATA_1)},
+		{"missing nested endif", ops(OP_DATA_1, OP_IF, OP_DATA_1, OP_IF, OP_DATA_1, OP_ENDIF)},
+		{"missing endif in skipped branch", ops(OP_0, OP_IF, OP_DATA_1, OP_IF, OP_ENDIF, OP_ELSE, OP_DATA_1)},
+		{"endif without if", ops(OP_DATA_1, OP_ENDIF)},
+		{"else without if", ops(OP_DATA_1, OP_ELSE, OP_DATA_1, OP_ENDIF)},
+		{"extra endif", ops(OP_DATA_1, OP_IF, OP_DATA_1, OP_ENDIF, OP_ENDIF)},
+		{"if on empty stack", ops(OP_IF, OP_DATA_1, OP_ENDIF)},
+	}
+	tx, prevTxs, _ := multiSigTestTx(t)
+	for _, test := range tests {
+		if ok, err := runScript(tx, prevTxs, test.srpt); err == nil {
+			t.Fatalf("%s: script ended without error, result %v", test.name, ok)
+		}
+	}
+}
+
+func TestCheckLockTimeVerify(t *testing.T) {
+	srpt := [][]byte{util.EncodeInt(100), {OP_CHECKLOCKTIMEVERIFY}, {OP_DROP}, {OP_DATA_1}}
+	tests := []struct {
+		lockTime int
+		valid    bool
+	}{
+		{0, false},
+		{99, false},
+		{100, true},
+		{150, true},
+	}
+	tx, prevTxs, _ := multiSigTestTx(t)
+	for _, test := range tests {
+		tx.LockTime = util.EncodeInt(test.lockTime)
+		ok, err := runScript(tx, prevTxs, srpt)
+		if (err == nil && ok) != test.valid {
+			t.Fatalf("locktime %d: result %v, err %v", test.lockTime, ok, err)
+		}
+	}
+	if _, err := runScript(tx, prevTxs, ops(OP_CHECKLOCKTIMEVERIFY)); err == nil {
+		t.Fatal("OP_CHECKLOCKTIMEVERIFY on an empty stack")
+	}
+}
+
+func TestSha256Preimage(t *testing.T) {
+	preimage := util.Sha256([]byte("secret"))
+	srpt := [][]byte{{OP_SHA256}, util.Sha256(preimage), {OP_EQUALVERIFY}, {OP_DATA_1}}
+	tx, prevTxs, _ := multiSigTestTx(t)
+	if ok, err := runScript(tx, prevTxs, append([][]byte{preimage}, srpt...)); err != nil || ok == false {
+		t.Fatalf("preimage rejected: result %v, err %v", ok, err)
+	}
+	wrong := append([]byte{}, preimage...)
+	wrong[0] ^= 0x01
+	if _, err := runScript(tx, prevTxs, append([][]byte{wrong}, srpt...)); err == nil {
+		t.Fatal("wrong preimage accepted")
+	}
+}
+
+//Les outputs P2PKH et P2PK des clés X||Y des premières versions restent dépensables
+func TestCheckSigLegacyPubKey(t *testing.T) {
+	privs, _ := newTestKeys(t, 1)
+	pk := keys.PubKeyFromPrivate(privs[0])
+	legacy := pk.SerializeLegacy()
+	tx, prevTxs, hash := multiSigTestTx(t)
+	sig, err := util.Sign(privs[0], hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2pkh := append(Script.UnlockingScript(sig, legacy), Script.LockingScript([][]byte{util.Ripemd160(util.Sha256(legacy))}, 0)...)
+	if ok, err := runScript(tx, prevTxs, p2pkh); err != nil || ok == false {
+		t.Fatalf("legacy P2PKH: result %v, err %v", ok, err)
+	}
+	p2pk := append(Script.CoinbaseUnlockingScript(sig), Script.CoinbaseLockingScript(legacy)...)
+	if ok, err := runScript(tx, prevTxs, p2pk); err != nil || ok == false {
+		t.Fatalf("legacy P2PK: result %v, err %v", ok, err)
+	}
+	//le hash de la clé compressée ne correspond pas à la clé X||Y
+	wrongHash := append(Script.UnlockingScript(sig, legacy), Script.LockingScript([][]byte{pk.Hash160()}, 0)...)
+	if ok, err := runScript(tx, prevTxs, wrongHash); err == nil && ok {
+		t.Fatal("legacy key spent an output locked to its compressed key")
+	}
+}
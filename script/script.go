@@ -6,6 +6,7 @@ import (
 	"errors"
 	"log"
 	"tway/config"
+	"tway/keys"
 	"tway/util"
 )
 
@@ -150,8 +151,8 @@ func (s *script) IsPayToPubKeyHash(scriptBytes [][]byte) bool {
 	engine.ParseScript(scriptBytes)
 
 	if len(scriptBytes) == config.P2PKHSize {
-		sigSize := len(scriptBytes[0]) == 64
-		pubKeySize := len(scriptBytes[1]) == 64
+		sigSize := len(scriptBytes[0]) <= config.SigLength
+		pubKeySize := keys.IsPubKey(scriptBytes[1])
 		opDup := engine.scripts[0][2].opcode.value == OP_DUP
 		opHash160 := engine.scripts[0][3].opcode.value == OP_HASH160
 		pubKeyHashSize := len(scriptBytes[4]) == 20
@@ -185,9 +186,24 @@ func (s *script) GetPubKeys(p2HScript [][]byte) ([][]byte, error) {
 	}
 	var pubkeys [][]byte
 	for _, op := range p2HScript {
-		if len(op) == config.PubKeyLength {
+		if keys.IsPubKey(op) {
 			pubkeys = append(pubkeys, op)
 		}
 	}
 	return pubkeys, nil
 }
+
+//Récupère le nombre de signatures requises et la liste ordonnée
+//des clés publiques d'un script multisig
+func (s *script) GetMultiSigInfo(srpt [][]byte) (int, [][]byte, error) {
+	if isMultiSigScript(srpt) == false {
+		return 0, nil, errors.New("not a multisig script")
+	}
+	return smallInt(srpt[0]), srpt[1 : len(srpt)-2], nil
+}
+
+//Generation du script d'input d'un output multisig (ScriptSig)
+//<signature>... dans l'ordre des clés publiques du script
+func (s *script) MultiSigUnlockingScript(signatures [][]byte) [][]byte {
+	return util.DupByteDoubleArray(signatures...)
+}
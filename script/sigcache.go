@@ -0,0 +1,67 @@
+package script
+
+import (
+	"sync"
+	"tway/config"
+	"tway/util"
+)
+
+//Cache des signatures déjà vérifiées, partagé par le mempool
+//et la validation des blocks. Une transaction vérifiée lors de son
+//ajout dans le mempool n'a plus besoin d'être re-vérifiée lorsqu'elle
+//est incluse dans un block.
+var SigCache = newSigCache(config.MaxSigCacheEntries)
+
+type sigCache struct {
+	mu         sync.RWMutex
+	valid      map[[32]byte]struct{}
+	maxEntries int
+}
+
+func newSigCache(maxEntries int) *sigCache {
+	return &sigCache{
+		valid:      make(map[[32]byte]struct{}, maxEntries),
+		maxEntries: maxEntries,
+	}
+}
+
+//Clé du cache : sha256(sigHash || pubKey || signature)
+func sigCacheKey(sigHash, pubKey, signature []byte) [32]byte {
+	var key [32]byte
+	data := make([]byte, 0, len(sigHash)+len(pubKey)+len(signature))
+	data = append(data, sigHash...)
+	data = append(data, pubKey...)
+	data = append(data, signature...)
+	copy(key[:], util.Sha256(data))
+	return key
+}
+
+//Retourne true si la signature a déjà été vérifiée comme valide
+func (c *sigCache) Exists(sigHash, pubKey, signature []byte) bool {
+	key := sigCacheKey(sigHash, pubKey, signature)
+	c.mu.RLock()
+	_, exist := c.valid[key]
+	c.mu.RUnlock()
+	return exist
+}
+
+//Ajoute une signature valide au cache
+//Si le cache est plein, une entrée aléatoire est supprimée
+func (c *sigCache) Add(sigHash, pubKey, signature []byte) {
+	if c.maxEntries <= 0 {
+		return
+	}
+	key := sigCacheKey(sigHash, pubKey, signature)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.valid)+1 > c.maxEntries {
+		//l'ordre de parcours d'une map étant aléatoire,
+		//la première entrée rencontrée est supprimée
+		for k := range c.valid {
+			delete(c.valid, k)
+			break
+		}
+	}
+	c.valid[key] = struct{}{}
+}
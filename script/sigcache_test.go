@@ -0,0 +1,77 @@
+package script
+
+import (
+	"testing"
+	"tway/util"
+)
+
+func sigCacheTestEntry(i int) ([]byte, []byte, []byte) {
+	return util.Sha256([]byte{byte(i)}), []byte{0x02, byte(i)}, []byte{0x30, byte(i)}
+}
+
+func TestSigCacheHitMiss(t *testing.T) {
+	c := newSigCache(10)
+	hash, pubKey, sig := sigCacheTestEntry(1)
+	if c.Exists(hash, pubKey, sig) {
+		t.Fatal("empty cache hit")
+	}
+	c.Add(hash, pubKey, sig)
+	if c.Exists(hash, pubKey, sig) == false {
+		t.Fatal("cached signature missed")
+	}
+	//chaque élément de l'entrée fait partie de la clé du cache
+	otherHash, otherPubKey, otherSig := sigCacheTestEntry(2)
+	for _, entry := range [][3][]byte{{otherHash, pubKey, sig}, {hash, otherPubKey, sig}, {hash, pubKey, otherSig}} {
+		if c.Exists(entry[0], entry[1], entry[2]) {
+			t.Fatalf("cache hit for %x %x %x", entry[0], entry[1], entry[2])
+		}
+	}
+}
+
+func TestSigCacheEviction(t *testing.T) {
+	const maxEntries = 5
+	c := newSigCache(maxEntries)
+	for i := 0; i < 3*maxEntries; i++ {
+		c.Add(sigCacheTestEntry(i))
+		if len(c.valid) > maxEntries {
+			t.Fatalf("%d entries, max %d", len(c.valid), maxEntries)
+		}
+		//la dernière entrée ajoutée n'est jamais supprimée
+		if c.Exists(sigCacheTestEntry(i)) == false {
+			t.Fatalf("entry %d missed after its insertion", i)
+		}
+	}
+	hits := 0
+	for i := 0; i < 3*maxEntries; i++ {
+		if c.Exists(sigCacheTestEntry(i)) {
+			hits++
+		}
+	}
+	if hits != maxEntries {
+		t.Fatalf("%d entries found, want %d", hits, maxEntries)
+	}
+}
+
+func TestSigCacheDisabled(t *testing.T) {
+	c := newSigCache(0)
+	c.Add(sigCacheTestEntry(1))
+	if c.Exists(sigCacheTestEntry(1)) {
+		t.Fatal("disabled cache hit")
+	}
+}
+
+//Une signature vérifiée est ajoutée au cache, une signature invalide ne l'est pas
+func TestVerifySignatureCache(t *testing.T) {
+	privs, pubKeys := newTestKeys(t, 2)
+	hash := util.Sha256([]byte("sigcache"))
+	sig, err := util.Sign(privs[0], hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verifySignature(pubKeys[1], sig, hash) || SigCache.Exists(hash, pubKeys[1], sig) {
+		t.Fatal("invalid signature verified or cached")
+	}
+	if verifySignature(pubKeys[0], sig, hash) == false || SigCache.Exists(hash, pubKeys[0], sig) == false {
+		t.Fatal("valid signature not verified or not cached")
+	}
+}
@@ -0,0 +1,271 @@
+package twayutil
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"tway/keys"
+	"tway/script"
+	"tway/util"
+)
+
+//Input d'une transaction partiellement signée
+//PrevTx est la transaction contenant l'output dépensé : elle est nécessaire
+//aux cosignataires pour signer sans accès à la blockchain.
+type PSBTInput struct {
+	PrevTx     Transaction
+	Vout       int
+	Signatures map[string][]byte //clé publique (hex) -> signature
+}
+
+//Transaction partiellement signée (Partially Signed Transaction)
+//Contient la transaction non signée, les outputs dépensés
+//et les signatures collectées pour chaque input.
+type PSBT struct {
+	Tx     Transaction
+	Inputs []PSBTInput
+}
+
+//Créer une transaction partiellement signée à partir d'une transaction non signée
+//prevTxs contient les transactions précédant les inputs de tx
+func NewPSBT(tx *Transaction, prevTxs map[string]*Transaction) (*PSBT, error) {
+	psbt := &PSBT{Tx: *tx}
+	for idx, in := range tx.Inputs {
+		if len(in.ScriptSig) > 0 {
+			return nil, fmt.Errorf("input %d is already signed", idx)
+		}
+		prevTx, exist := prevTxs[hex.EncodeToString(in.PrevTransactionHash)]
+		if exist == false {
+			return nil, fmt.Errorf("previous transaction of input %d not found", idx)
+		}
+		vout := util.DecodeInt(in.Vout)
+		if vout < 0 || vout >= len(prevTx.Outputs) {
+			return nil, fmt.Errorf("output %d of input %d not found", vout, idx)
+		}
+		psbt.Inputs = append(psbt.Inputs, PSBTInput{*prevTx, vout, make(map[string][]byte)})
+	}
+	return psbt, nil
+}
+
+//PSBT -> []byte
+func (psbt *PSBT) Serialize() []byte {
+	b, err := json.Marshal(psbt)
+	if err != nil {
+		log.Panic(err)
+	}
+	bu := new(bytes.Buffer)
+	enc := gob.NewEncoder(bu)
+	err = enc.Encode(b)
+	if err != nil {
+		log.Panic(err)
+	}
+	return bu.Bytes()
+}
+
+//[]byte -> PSBT
+//Les données pouvant provenir d'un autre noeud, une erreur est retournée
+//si elles sont mal formées
+func DeserializePSBT(data []byte) (*PSBT, error) {
+	var psbt PSBT
+	var dataByte []byte
+
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&dataByte); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(dataByte, &psbt); err != nil {
+		return nil, err
+	}
+	if len(psbt.Inputs) != len(psbt.Tx.Inputs) {
+		return nil, errors.New("number of inputs doesn't match with the transaction")
+	}
+	for idx := range psbt.Inputs {
+		in := &psbt.Inputs[idx]
+		if bytes.Compare(in.PrevTx.GetHash(), psbt.Tx.Inputs[idx].PrevTransactionHash) != 0 || in.Vout != util.DecodeInt(psbt.Tx.Inputs[idx].Vout) {
+			return nil, fmt.Errorf("previous output of input %d doesn't match with the transaction", idx)
+		}
+		if in.Vout < 0 || in.Vout >= len(in.PrevTx.Outputs) {
+			return nil, fmt.Errorf("output %d of input %d not found", in.Vout, idx)
+		}
+		if in.Signatures == nil {
+			in.Signatures = make(map[string][]byte)
+		}
+	}
+	return &psbt, nil
+}
+
+//Retourne le scriptPubKey de l'output dépensé par l'input
+func (in *PSBTInput) ScriptPubKey() [][]byte {
+	return in.PrevTx.Outputs[in.Vout].ScriptPubKey
+}
+
+//Retourne le montant de l'output dépensé par l'input
+func (in *PSBTInput) Amount() int {
+	return util.DecodeInt(in.PrevTx.Outputs[in.Vout].Value)
+}
+
+//Hash signé par les signatures de l'input à l'index idx
+func (psbt *PSBT) sigHash(idx int) []byte {
+	return util.SigHash(psbt.Tx.ToTxUtil(), idx, psbt.Inputs[idx].PrevTx.ToTxUtil())
+}
+
+//Retourne la liste des clés publiques pouvant signer l'input
+//ainsi que le nombre de signatures requises
+func (in *PSBTInput) signers() ([][]byte, int) {
+	srpt := in.ScriptPubKey()
+	if nSig, pubKeys, err := script.Script.GetMultiSigInfo(srpt); err == nil {
+		return pubKeys, nSig
+	}
+	switch script.Script.GetScriptClass(srpt) {
+	case script.PubKeyTy:
+		return [][]byte{srpt[0]}, 1
+	case script.PubKeyHashTy:
+		//la clé publique n'est connue qu'une fois l'input signé
+		for pubKeyHex := range in.Signatures {
+			pubKey, _ := hex.DecodeString(pubKeyHex)
+			return [][]byte{pubKey}, 1
+		}
+		return nil, 1
+	}
+	return nil, 0
+}
+
+//Retourne true si la clé publique peut signer l'input
+func (in *PSBTInput) canSign(pubKey []byte) bool {
+	srpt := in.ScriptPubKey()
+	if script.Script.GetScriptClass(srpt) == script.PubKeyHashTy {
+		return bytes.Compare(srpt[2], util.Ripemd160(util.Sha256(pubKey))) == 0
+	}
+	pubKeys, _ := in.signers()
+	for _, pk := range pubKeys {
+		if bytes.Compare(pk, pubKey) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+//Ajoute une signature à l'input à l'index idx après l'avoir vérifiée
+func (psbt *PSBT) addSignature(idx int, pubKey, signature []byte) error {
+	in := &psbt.Inputs[idx]
+	if in.canSign(pubKey) == false {
+		return errors.New("public key can't sign this input")
+	}
+	pk, err := keys.ParsePubKey(pubKey)
+	if err != nil {
+		return err
+	}
+	if pk.Verify(psbt.sigHash(idx), signature) == false {
+		return errors.New("invalid signature")
+	}
+	in.Signatures[hex.EncodeToString(pubKey)] = signature
+	return nil
+}
+
+//Retourne le nombre de signatures collectées et le nombre requis pour l'input
+func (in *PSBTInput) SignatureCount() (int, int) {
+	_, nSig := in.signers()
+	return len(in.Signatures), nSig
+}
+
+//Signe chaque input pouvant être dépensé par la clé passée en paramètre
+//Retourne le nombre d'inputs signés
+func (psbt *PSBT) Sign(privKey *ecdsa.PrivateKey, pubKey []byte) (int, error) {
+	signed := 0
+	for idx := range psbt.Inputs {
+		in := &psbt.Inputs[idx]
+		if in.canSign(pubKey) == false {
+			continue
+		}
+		signature, err := util.Sign(privKey, psbt.sigHash(idx))
+		if err != nil {
+			return signed, err
+		}
+		if err := psbt.addSignature(idx, pubKey, signature); err != nil {
+			return signed, err
+		}
+		signed++
+	}
+	return signed, nil
+}
+
+//Ajoute les signatures d'une autre transaction partiellement signée
+//Les deux doivent porter sur la même transaction non signée
+func (psbt *PSBT) Combine(other *PSBT) error {
+	if bytes.Compare(psbt.Tx.GetHash(), other.Tx.GetHash()) != 0 {
+		return errors.New("partially signed transactions don't spend the same transaction")
+	}
+	for idx, in := range other.Inputs {
+		for pubKeyHex, signature := range in.Signatures {
+			pubKey, err := hex.DecodeString(pubKeyHex)
+			if err != nil {
+				return err
+			}
+			if err := psbt.addSignature(idx, pubKey, signature); err != nil {
+				return fmt.Errorf("input %d: %s", idx, err)
+			}
+		}
+	}
+	return nil
+}
+
+//Retourne true si chaque input possède assez de signatures
+func (psbt *PSBT) IsComplete() bool {
+	for _, in := range psbt.Inputs {
+		got, required := in.SignatureCount()
+		if required == 0 || got < required {
+			return false
+		}
+	}
+	return true
+}
+
+//Génère le scriptSig de chaque input et retourne la transaction signée
+func (psbt *PSBT) Finalize() (*Transaction, error) {
+	tx := psbt.Tx
+	tx.Inputs = make([]Input, len(psbt.Tx.Inputs))
+
+	for idx, in := range psbt.Inputs {
+		srpt := in.ScriptPubKey()
+		var scriptSig [][]byte
+
+		switch script.Script.GetScriptClass(srpt) {
+		case script.MultiSigTy:
+			nSig, pubKeys, _ := script.Script.GetMultiSigInfo(srpt)
+			var signatures [][]byte
+			//les signatures doivent être dans l'ordre des clés publiques du script
+			for _, pubKey := range pubKeys {
+				if sig, exist := in.Signatures[hex.EncodeToString(pubKey)]; exist && len(signatures) < nSig {
+					signatures = append(signatures, sig)
+				}
+			}
+			if len(signatures) < nSig {
+				return nil, fmt.Errorf("input %d: %d signatures on %d required", idx, len(signatures), nSig)
+			}
+			scriptSig = script.Script.MultiSigUnlockingScript(signatures)
+		case script.PubKeyHashTy:
+			for pubKeyHex, sig := range in.Signatures {
+				pubKey, _ := hex.DecodeString(pubKeyHex)
+				scriptSig = script.Script.UnlockingScript(sig, pubKey)
+			}
+		case script.PubKeyTy:
+			if sig, exist := in.Signatures[hex.EncodeToString(srpt[0])]; exist {
+				scriptSig = script.Script.CoinbaseUnlockingScript(sig)
+			}
+		default:
+			return nil, fmt.Errorf("input %d: unsupported script", idx)
+		}
+		if len(scriptSig) == 0 {
+			return nil, fmt.Errorf("input %d is not signed", idx)
+		}
+		prev := psbt.Tx.Inputs[idx]
+		tx.Inputs[idx] = NewTxInput(prev.PrevTransactionHash, prev.Vout, scriptSig)
+		tx.Inputs[idx].Sequence = prev.Sequence
+	}
+	return &tx, nil
+}
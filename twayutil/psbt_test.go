@@ -0,0 +1,38 @@
+package twayutil
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"tway/keys"
+	"tway/script"
+	"tway/util"
+)
+
+//La séquence des inputs est conservée : une transaction remplaçable le reste une fois finalisée
+func TestPSBTFinalizeKeepsSequence(t *testing.T) {
+	priv, err := keys.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey := keys.PubKeyFromPrivate(priv).SerializeCompressed()
+	prevTx := &Transaction{Version: []byte{1}, Outputs: []Output{NewTxOutput(script.Script.CoinbaseLockingScript(pubKey), 1000)}}
+	in := NewTxInput(prevTx.GetHash(), util.EncodeInt(0), nil)
+	in.Sequence = util.EncodeInt(MaxRBFSequence)
+	tx := &Transaction{Version: []byte{1}, Inputs: []Input{in}, Outputs: []Output{NewTxOutput(nil, 900)}}
+
+	psbt, err := NewPSBT(tx, map[string]*Transaction{hex.EncodeToString(prevTx.GetHash()): prevTx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := psbt.Sign(priv, pubKey); err != nil || n != 1 {
+		t.Fatalf("%d inputs signed, err %v", n, err)
+	}
+	signed, err := psbt.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(signed.Inputs[0].Sequence, in.Sequence) != 0 || signed.SignalsReplacement() == false {
+		t.Fatalf("sequence %x, want %x", signed.Inputs[0].Sequence, in.Sequence)
+	}
+}
@@ -3,7 +3,6 @@ package twayutil
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/rand"
 	"encoding/gob"
 	"encoding/hex"
 	"encoding/json"
@@ -23,8 +22,16 @@ type Input struct {
 	Vout                []byte //[4]
 	TxInScriptLen       []byte //[1-9]
 	ScriptSig           [][]byte
+	//vide pour un input final, n'est pas sérialisé afin de conserver
+	//le hash des transactions existantes
+	Sequence []byte `json:",omitempty"` //[4]
 }
 
+//Un input dont la séquence est inférieure ou égale à MaxRBFSequence signale
+//que la transaction peut être remplacée dans la mempool par une transaction
+//dépensant les mêmes outputs avec plus de frais (replace-by-fee)
+const MaxRBFSequence = 0xfffffffd
+
 //Retourne un nouvel input de tx
 func NewTxInput(prevTransactionHash []byte, vout []byte, scriptSig [][]byte) Input {
 	in := Input{
@@ -36,8 +43,9 @@ func NewTxInput(prevTransactionHash []byte, vout []byte, scriptSig [][]byte) Inp
 	return in
 }
 
+//Retourne la taille de l'input (outpoint, longueur et contenu du scriptSig)
 func (in *Input) GetSize() uint64 {
-	return 0
+	return uint64(32 + 4 + 1 + util.LenDoubleSliceByte(in.ScriptSig))
 }
 
 //Transaction -> []byte
@@ -74,6 +82,7 @@ func (in *Input) ToInputUtil() *util.Input {
 		ScriptSig:           in.ScriptSig,
 		TxInScriptLen:       in.TxInScriptLen,
 		Vout:                in.Vout,
+		Sequence:            in.Sequence,
 	}
 }
 
@@ -143,8 +152,9 @@ func DeserializeTxOutputs(d []byte) *TxOutputs {
 	return &outs
 }
 
+//Retourne la taille de l'output (valeur, longueur et contenu du scriptPubKey)
 func (out *Output) GetSize() uint64 {
-	return 0
+	return uint64(8 + 1 + util.LenDoubleSliceByte(out.ScriptPubKey))
 }
 
 type Transaction struct {
@@ -215,13 +225,44 @@ func (tx *Transaction) GetValue() int {
 	return val
 }
 
+//Retourne true si les signatures de la transaction engagent ses inputs,
+//leurs séquences et ses outputs (voir util.SigHash)
+func (tx *Transaction) CommitsToOutputs() bool {
+	return len(tx.Version) == 1 && tx.Version[0] >= util.SigHashTxVersion
+}
+
+//Retourne true si un des inputs de la transaction signale qu'elle est remplaçable
+//Seule une transaction dont les signatures engagent ses outputs et ses séquences
+//peut le signaler : sinon n'importe qui pourrait reprendre ses scriptSigs dans une
+//transaction payant d'autres outputs, ou modifier la séquence de ses inputs
+func (tx *Transaction) SignalsReplacement() bool {
+	if tx.CommitsToOutputs() == false {
+		return false
+	}
+	for _, in := range tx.Inputs {
+		if len(in.Sequence) > 0 && util.DecodeInt(in.Sequence) <= MaxRBFSequence {
+			return true
+		}
+	}
+	return false
+}
+
 //Retourne true si la tx est coinbase
 func (tx *Transaction) IsCoinbase() bool {
 	return len(tx.Inputs) == 1 && len(tx.Inputs[0].PrevTransactionHash) == 0 && bytes.Compare(tx.Inputs[0].Vout, util.EncodeInt(-1)) == 0
 }
 
+//Retourne la taille de la transaction, utilisée pour calculer son taux de frais
+//(version, compteurs d'inputs et d'outputs, locktime)
 func (tx *Transaction) GetSize() uint64 {
-	return 0
+	size := uint64(4 + 1 + 1 + 4)
+	for i := range tx.Inputs {
+		size += tx.Inputs[i].GetSize()
+	}
+	for i := range tx.Outputs {
+		size += tx.Outputs[i].GetSize()
+	}
+	return size
 }
 
 //Signe une transaction avec le clé privé
@@ -232,18 +273,26 @@ func (tx *Transaction) Sign(prevTxs map[string]*util.Transaction, inputsPrivKey
 	}
 	for idx, in := range tx.Inputs {
 		//on signe les données
-		prevTxid := hex.EncodeToString(in.PrevTransactionHash)
-		r, s, err := ecdsa.Sign(rand.Reader, &inputsPrivKey[idx], prevTxs[prevTxid].Serialize())
+		signature, err := tx.SignInput(prevTxs, idx, &inputsPrivKey[idx])
 		if err != nil {
 			fmt.Println(err)
 			log.Panic(err)
 		}
-
-		signature := append(r.Bytes(), s.Bytes()...)
 		//on update l'input avec un nouvel input identique
 		//mais comprenant le bon scriptSig
 		tx.Inputs[idx] = NewTxInput(in.PrevTransactionHash, in.Vout, script.Script.UnlockingScript(signature, inputsPubKey[idx]))
+		tx.Inputs[idx].Sequence = in.Sequence
+	}
+}
+
+//Retourne la signature de l'input à l'index idx avec la clé privée
+func (tx *Transaction) SignInput(prevTxs map[string]*util.Transaction, idx int, privKey *ecdsa.PrivateKey) ([]byte, error) {
+	prevTxid := hex.EncodeToString(tx.Inputs[idx].PrevTransactionHash)
+	prevTx, exist := prevTxs[prevTxid]
+	if exist == false {
+		return nil, fmt.Errorf("previous transaction %s not found", prevTxid)
 	}
+	return util.Sign(privKey, util.SigHash(tx.ToTxUtil(), idx, prevTx))
 }
 
 //[]Transaction -> [][]byte
@@ -23,7 +23,8 @@ func Base58Encode(input []byte) []byte {
 	}
 
 	ReverseBytes(result)
-	for b := range input {
+	//chaque octet nul en tête est encodé par le premier caractère de l'alphabet
+	for _, b := range input {
 		if b == 0x00 {
 			result = append([]byte{b58Alphabet[0]}, result...)
 		} else {
@@ -40,10 +41,12 @@ func Base58Decode(input []byte) []byte {
 	result := big.NewInt(0)
 	zeroBytes := 0
 
-	for b := range input {
-		if b == 0x00 {
-			zeroBytes++
+	//chaque premier caractère de l'alphabet en tête est décodé par un octet nul
+	for _, b := range input {
+		if b != b58Alphabet[0] {
+			break
 		}
+		zeroBytes++
 	}
 
 	payload := input[zeroBytes:]
@@ -59,6 +62,16 @@ func Base58Decode(input []byte) []byte {
 	return decoded
 }
 
+//Retourne true si tous les caractères de l'input appartiennent à l'alphabet base58
+func IsBase58(input []byte) bool {
+	for _, b := range input {
+		if bytes.IndexByte(b58Alphabet, b) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // ReverseBytes reverses a byte array
 func ReverseBytes(data []byte) {
 	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
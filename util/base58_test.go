@@ -0,0 +1,30 @@
+package util
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBase58LeadingZeros(t *testing.T) {
+	tests := []struct {
+		data    []byte
+		encoded string
+	}{
+		{[]byte{}, ""},
+		{[]byte{0x00}, "1"},
+		{[]byte{0x00, 0x00, 0x00}, "111"},
+		{[]byte{0x00, 0x01}, "12"},
+		{[]byte{0x00, 0x00, 0x39}, "11z"},
+		{[]byte{0x80, 0x00}, "Ajy"},
+		{[]byte{0x61}, "2g"},
+	}
+	for _, test := range tests {
+		encoded := string(Base58Encode(test.data))
+		if encoded != test.encoded {
+			t.Fatalf("encode %x: got %s, want %s", test.data, encoded, test.encoded)
+		}
+		if decoded := Base58Decode([]byte(encoded)); bytes.Compare(decoded, test.data) != 0 {
+			t.Fatalf("decode %s: got %x, want %x", encoded, decoded, test.data)
+		}
+	}
+}
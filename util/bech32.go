@@ -0,0 +1,136 @@
+package util
+
+import (
+	"errors"
+	"strings"
+)
+
+//Encodage bech32 (processus utilisé par le BTC, BIP 173) :
+//préfixe lisible, séparateur '1', données en base32 et checksum de 6 caractères
+//détectant jusqu'à 4 erreurs de saisie
+
+const (
+	bech32Charset     = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	bech32Separator   = '1'
+	bech32ChecksumLen = 6
+	bech32MaxLen      = 90
+)
+
+var bech32Generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+var ErrInvalidBech32 = errors.New("invalid bech32 string")
+
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= bech32Generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+//Développe le préfixe pour le calcul du checksum
+func bech32HRPExpand(hrp string) []byte {
+	ret := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		ret = append(ret, hrp[i]>>5)
+	}
+	ret = append(ret, 0)
+	for i := 0; i < len(hrp); i++ {
+		ret = append(ret, hrp[i]&31)
+	}
+	return ret
+}
+
+func bech32Checksum(hrp string, data []byte) []byte {
+	values := append(bech32HRPExpand(hrp), data...)
+	values = append(values, make([]byte, bech32ChecksumLen)...)
+	polymod := bech32Polymod(values) ^ 1
+	checksum := make([]byte, bech32ChecksumLen)
+	for i := range checksum {
+		checksum[i] = byte((polymod >> uint(5*(5-i))) & 31)
+	}
+	return checksum
+}
+
+//Encode des données de 5 bits avec le préfixe hrp
+func Bech32Encode(hrp string, data []byte) (string, error) {
+	hrp = strings.ToLower(hrp)
+	if len(hrp)+1+len(data)+bech32ChecksumLen > bech32MaxLen {
+		return "", ErrInvalidBech32
+	}
+	var result strings.Builder
+	result.WriteString(hrp)
+	result.WriteByte(bech32Separator)
+	for _, v := range append(data, bech32Checksum(hrp, data)...) {
+		if v > 31 {
+			return "", ErrInvalidBech32
+		}
+		result.WriteByte(bech32Charset[v])
+	}
+	return result.String(), nil
+}
+
+//Decode une chaîne bech32, retourne le préfixe et les données de 5 bits
+//Une chaîne mélangeant majuscules et minuscules est refusée
+func Bech32Decode(s string) (string, []byte, error) {
+	if len(s) > bech32MaxLen || strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, ErrInvalidBech32
+	}
+	s = strings.ToLower(s)
+	pos := strings.LastIndexByte(s, bech32Separator)
+	if pos < 1 || pos+bech32ChecksumLen+1 > len(s) {
+		return "", nil, ErrInvalidBech32
+	}
+	hrp := s[:pos]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, ErrInvalidBech32
+		}
+	}
+	data := make([]byte, 0, len(s)-pos-1)
+	for i := pos + 1; i < len(s); i++ {
+		v := strings.IndexByte(bech32Charset, s[i])
+		if v < 0 {
+			return "", nil, ErrInvalidBech32
+		}
+		data = append(data, byte(v))
+	}
+	if bech32Polymod(append(bech32HRPExpand(hrp), data...)) != 1 {
+		return "", nil, errors.New("bech32 checksum doesn't match")
+	}
+	return hrp, data[:len(data)-bech32ChecksumLen], nil
+}
+
+//Convertit des groupes de fromBits bits en groupes de toBits bits
+//pad complète le dernier groupe avec des zéros lors de l'encodage
+func ConvertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var acc uint32
+	var bits uint
+	var ret []byte
+	maxv := uint32(1)<<toBits - 1
+	for _, v := range data {
+		if uint32(v)>>fromBits != 0 {
+			return nil, ErrInvalidBech32
+		}
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			ret = append(ret, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			ret = append(ret, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, ErrInvalidBech32
+	}
+	return ret, nil
+}
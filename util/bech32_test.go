@@ -0,0 +1,109 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+//Vecteurs de test du BIP 173
+var bech32ValidTests = []string{
+	"A12UEL5L",
+	"a12uel5l",
+	"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs",
+	"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+	"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j",
+	"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+	"?1ezyfcl",
+}
+
+func TestBech32Valid(t *testing.T) {
+	for _, test := range bech32ValidTests {
+		hrp, data, err := Bech32Decode(test)
+		if err != nil {
+			t.Fatalf("decode %s: %v", test, err)
+		}
+		encoded, err := Bech32Encode(hrp, data)
+		if err != nil {
+			t.Fatalf("encode %s: %v", test, err)
+		}
+		if encoded != strings.ToLower(test) {
+			t.Fatalf("encode %s: got %s", test, encoded)
+		}
+	}
+}
+
+func TestBech32Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+	}{
+		{"hrp character out of range", "\x201nwldj5"},
+		{"hrp character out of range", "\x7f1axkwrx"},
+		{"hrp character out of range", "\x801eym55h"},
+		{"overall max length exceeded", "an84characterslonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1569pvx"},
+		{"no separator", "pzry9x0s0muk"},
+		{"empty hrp", "1pzry9x0s0muk"},
+		{"invalid data character", "x1b4n0q5v"},
+		{"too short checksum", "li1dgmt3"},
+		{"invalid character in checksum", "de1lg7wt\xff"},
+		{"checksum calculated with uppercase hrp", "A1G7SGD8"},
+		{"empty hrp", "10a06t8"},
+		{"empty hrp", "1qzzfhee"},
+	}
+	for _, test := range tests {
+		if hrp, data, err := Bech32Decode(test.s); err == nil {
+			t.Fatalf("%s: %q decoded as %s %x", test.name, test.s, hrp, data)
+		}
+	}
+}
+
+//Toute substitution d'un caractère des données ou du checksum est détectée
+func TestBech32Mutation(t *testing.T) {
+	for _, test := range bech32ValidTests {
+		s := strings.ToLower(test)
+		pos := strings.LastIndexByte(s, bech32Separator)
+		for i := pos + 1; i < len(s); i++ {
+			for j := 0; j < len(bech32Charset); j++ {
+				if bech32Charset[j] == s[i] {
+					continue
+				}
+				mutated := s[:i] + string(bech32Charset[j]) + s[i+1:]
+				if _, _, err := Bech32Decode(mutated); err == nil {
+					t.Fatalf("%s: mutation %s accepted", test, mutated)
+				}
+			}
+		}
+	}
+}
+
+func TestBech32Checksum(t *testing.T) {
+	s, err := Bech32Encode("tway", []byte{0, 1, 2, 3, 31})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//échange de deux caractères adjacents
+	pos := strings.LastIndexByte(s, bech32Separator)
+	swapped := s[:pos+1] + string(s[pos+2]) + string(s[pos+1]) + s[pos+3:]
+	if _, _, err := Bech32Decode(swapped); err == nil {
+		t.Fatalf("swapped characters %s accepted", swapped)
+	}
+	//préfixe modifié
+	if _, _, err := Bech32Decode("twax" + s[4:]); err == nil {
+		t.Fatal("modified hrp accepted")
+	}
+	//checksum tronqué
+	if _, _, err := Bech32Decode(s[:len(s)-1]); err == nil {
+		t.Fatal("truncated checksum accepted")
+	}
+}
+
+func TestBech32MixedCase(t *testing.T) {
+	for _, s := range []string{"A12uel5l", "a12UEL5L", "Abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw"} {
+		if _, _, err := Bech32Decode(s); err == nil {
+			t.Fatalf("mixed case %s accepted", s)
+		}
+	}
+	if hrp, _, err := Bech32Decode("ABCDEF1QPZRY9X8GF2TVDW0S3JN54KHCE6MUA7LMQQQXW"); err != nil || hrp != "abcdef" {
+		t.Fatalf("uppercase string rejected: %s %v", hrp, err)
+	}
+}
@@ -1,9 +1,24 @@
 package util
 
+import "bytes"
+
 func LenDoubleSliceByte(slice [][]byte) int {
 	var size = 0
 	for _, b := range slice {
 		size += len(b)
 	}
 	return size
-}
\ No newline at end of file
+}
+
+//Retourne true si les deux slices contiennent les mêmes elements
+func EqualDoubleSliceByte(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if bytes.Compare(a[i], b[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,229 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+//Taille maximale d'une signature encodée en DER
+//0x30 <len> 0x02 <len> <r: 33 octets max> 0x02 <len> <s: 33 octets max>
+const maxDERSignatureLength = 72
+
+//Signe un hash de manière deterministe (RFC 6979).
+//La signature retournée est encodée en DER avec un S bas (S <= N/2).
+func Sign(priv *ecdsa.PrivateKey, hash []byte) ([]byte, error) {
+	curve := priv.Curve
+	N := curve.Params().N
+	if priv.D == nil || priv.D.Sign() <= 0 || priv.D.Cmp(N) >= 0 {
+		return nil, errors.New("invalid private key")
+	}
+	e := hashToInt(hash, curve)
+	nextK := nonceRFC6979(priv.D, hash, curve)
+
+	for {
+		k := nextK()
+		kInv := new(big.Int).ModInverse(k, N)
+
+		r, _ := curve.ScalarBaseMult(k.Bytes())
+		r.Mod(r, N)
+		if r.Sign() == 0 {
+			continue
+		}
+		//s = k^-1 * (e + r * d) mod N
+		s := new(big.Int).Mul(priv.D, r)
+		s.Add(s, e)
+		s.Mul(s, kInv)
+		s.Mod(s, N)
+		if s.Sign() == 0 {
+			continue
+		}
+		//normalisation low-S : s et N - s sont toutes deux valides,
+		//seule la plus petite est acceptée
+		if s.Cmp(halfOrder(curve)) > 0 {
+			s.Sub(N, s)
+		}
+		return encodeDER(r, s), nil
+	}
+}
+
+//Verifie une signature DER sur un hash.
+//Retourne false si la signature n'est pas encodée de manière canonique.
+func VerifySignature(pub *ecdsa.PublicKey, hash, sig []byte) bool {
+	r, s, err := ParseDERSignature(sig, pub.Curve)
+	if err != nil {
+		return false
+	}
+	return ecdsa.Verify(pub, hash, r, s)
+}
+
+//Decode une signature DER stricte (BIP 66) avec un S bas
+func ParseDERSignature(sig []byte, curve elliptic.Curve) (*big.Int, *big.Int, error) {
+	if len(sig) < 8 || len(sig) > maxDERSignatureLength {
+		return nil, nil, errors.New("malformed signature: wrong length")
+	}
+	if sig[0] != 0x30 {
+		return nil, nil, errors.New("malformed signature: no header magic")
+	}
+	if int(sig[1]) != len(sig)-2 {
+		return nil, nil, errors.New("malformed signature: bad length")
+	}
+	r, rest, err := parseDERInt(sig[2:])
+	if err != nil {
+		return nil, nil, err
+	}
+	s, rest, err := parseDERInt(rest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rest) != 0 {
+		return nil, nil, errors.New("malformed signature: trailing data")
+	}
+
+	N := curve.Params().N
+	if r.Sign() <= 0 || r.Cmp(N) >= 0 {
+		return nil, nil, errors.New("signature R is out of range")
+	}
+	if s.Sign() <= 0 || s.Cmp(N) >= 0 {
+		return nil, nil, errors.New("signature S is out of range")
+	}
+	if s.Cmp(halfOrder(curve)) > 0 {
+		return nil, nil, errors.New("signature S is not low")
+	}
+	return r, s, nil
+}
+
+//Decode un entier DER (0x02 <len> <valeur>) encodé de manière minimale
+func parseDERInt(data []byte) (*big.Int, []byte, error) {
+	if len(data) < 3 || data[0] != 0x02 {
+		return nil, nil, errors.New("malformed signature: no integer marker")
+	}
+	l := int(data[1])
+	if l == 0 || 2+l > len(data) {
+		return nil, nil, errors.New("malformed signature: bad integer length")
+	}
+	value := data[2 : 2+l]
+	if value[0]&0x80 != 0 {
+		return nil, nil, errors.New("malformed signature: negative integer")
+	}
+	if l > 1 && value[0] == 0x00 && value[1]&0x80 == 0 {
+		return nil, nil, errors.New("malformed signature: integer has excessive padding")
+	}
+	return new(big.Int).SetBytes(value), data[2+l:], nil
+}
+
+//Encode R et S au format DER
+func encodeDER(r, s *big.Int) []byte {
+	rb := canonicalizeInt(r)
+	sb := canonicalizeInt(s)
+
+	length := 6 + len(rb) + len(sb)
+	b := make([]byte, 0, length)
+	b = append(b, 0x30, byte(length-2))
+	b = append(b, 0x02, byte(len(rb)))
+	b = append(b, rb...)
+	b = append(b, 0x02, byte(len(sb)))
+	b = append(b, sb...)
+	return b
+}
+
+//Retourne l'encodage big endian minimal d'un entier positif,
+//précédé de 0x00 si le bit de poids fort est a 1
+func canonicalizeInt(n *big.Int) []byte {
+	b := n.Bytes()
+	if len(b) == 0 {
+		b = []byte{0x00}
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0x00}, b...)
+	}
+	return b
+}
+
+func halfOrder(curve elliptic.Curve) *big.Int {
+	return new(big.Int).Rsh(curve.Params().N, 1)
+}
+
+//bits2int de la RFC 6979
+func hashToInt(hash []byte, curve elliptic.Curve) *big.Int {
+	orderBits := curve.Params().N.BitLen()
+	orderBytes := (orderBits + 7) / 8
+	if len(hash) > orderBytes {
+		hash = hash[:orderBytes]
+	}
+	ret := new(big.Int).SetBytes(hash)
+	excess := len(hash)*8 - orderBits
+	if excess > 0 {
+		ret.Rsh(ret, uint(excess))
+	}
+	return ret
+}
+
+//int2octets de la RFC 6979
+func intToOctets(v *big.Int, rolen int) []byte {
+	out := v.Bytes()
+	if len(out) < rolen {
+		out = append(make([]byte, rolen-len(out)), out...)
+	}
+	if len(out) > rolen {
+		out = out[len(out)-rolen:]
+	}
+	return out
+}
+
+//Retourne un générateur de nonces deterministes (RFC 6979 section 3.2)
+//à partir de la clé privée et du hash signé, avec HMAC-SHA256.
+func nonceRFC6979(privKey *big.Int, hash []byte, curve elliptic.Curve) func() *big.Int {
+	N := curve.Params().N
+	rolen := (N.BitLen() + 7) / 8
+
+	x := intToOctets(privKey, rolen)
+	h1 := hashToInt(hash, curve)
+	if h1.Cmp(N) >= 0 {
+		h1.Sub(h1, N)
+	}
+	bh := intToOctets(h1, rolen)
+
+	mac := func(key []byte, data ...[]byte) []byte {
+		m := hmac.New(sha256.New, key)
+		for _, d := range data {
+			m.Write(d)
+		}
+		return m.Sum(nil)
+	}
+
+	v := make([]byte, sha256.Size)
+	for i := range v {
+		v[i] = 0x01
+	}
+	k := make([]byte, sha256.Size)
+
+	k = mac(k, v, []byte{0x00}, x, bh)
+	v = mac(k, v)
+	k = mac(k, v, []byte{0x01}, x, bh)
+	v = mac(k, v)
+
+	first := true
+	return func() *big.Int {
+		for {
+			if first == false {
+				k = mac(k, v, []byte{0x00})
+				v = mac(k, v)
+			}
+			first = false
+
+			var t []byte
+			for len(t) < rolen {
+				v = mac(k, v)
+				t = append(t, v...)
+			}
+			secret := hashToInt(t, curve)
+			if secret.Sign() > 0 && secret.Cmp(N) < 0 {
+				return secret
+			}
+		}
+	}
+}
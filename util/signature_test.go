@@ -0,0 +1,130 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func hexInt(t *testing.T, s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 16)
+	if ok == false {
+		t.Fatalf("invalid hex integer %s", s)
+	}
+	return n
+}
+
+//Clé de l'annexe A.2.5 de la RFC 6979 (P-256)
+func rfc6979Key(t *testing.T) *ecdsa.PrivateKey {
+	priv := new(ecdsa.PrivateKey)
+	priv.Curve = elliptic.P256()
+	priv.D = hexInt(t, "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721")
+	priv.X, priv.Y = priv.Curve.ScalarBaseMult(priv.D.Bytes())
+	return priv
+}
+
+//Vecteurs de la RFC 6979 annexe A.2.5, P-256 avec SHA-256
+func TestSignRFC6979(t *testing.T) {
+	priv := rfc6979Key(t)
+	if priv.X.Cmp(hexInt(t, "60FED4BA255A9D31C961EB74C6356D68C049B8923B61FA6CE669622E60F29FB6")) != 0 {
+		t.Fatal("wrong public key")
+	}
+	tests := []struct {
+		message string
+		k, r, s string
+	}{
+		{
+			"sample",
+			"A6E3C57DD01ABE90086538398355DD4C3B17AA873382B0F24D6129493D8AAD60",
+			"EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716",
+			"F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8",
+		},
+		{
+			"test",
+			"D16B6AE827F17175E040871A1C7EC3500192C4C92677336EC2537ACAEE0008E0",
+			"F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367",
+			"019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083",
+		},
+	}
+	N := priv.Curve.Params().N
+	for _, test := range tests {
+		hash := Sha256([]byte(test.message))
+		if k := nonceRFC6979(priv.D, hash, priv.Curve)(); k.Cmp(hexInt(t, test.k)) != 0 {
+			t.Fatalf("%s: nonce %x, want %s", test.message, k, test.k)
+		}
+		sig, err := Sign(priv, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, s, err := ParseDERSignature(sig, priv.Curve)
+		if err != nil {
+			t.Fatalf("%s: %v", test.message, err)
+		}
+		//la RFC donne S sans normalisation, Sign retourne le S bas
+		expectedS := hexInt(t, test.s)
+		if expectedS.Cmp(halfOrder(priv.Curve)) > 0 {
+			expectedS.Sub(N, expectedS)
+		}
+		if r.Cmp(hexInt(t, test.r)) != 0 || s.Cmp(expectedS) != 0 {
+			t.Fatalf("%s: signature (%x, %x), want (%s, %x)", test.message, r, s, test.r, expectedS)
+		}
+		if VerifySignature(&priv.PublicKey, hash, sig) == false {
+			t.Fatalf("%s: signature doesn't verify", test.message)
+		}
+		//la signature est deterministe
+		again, _ := Sign(priv, hash)
+		if hex.EncodeToString(again) != hex.EncodeToString(sig) {
+			t.Fatalf("%s: signature is not deterministic", test.message)
+		}
+	}
+}
+
+func TestParseDERSignatureStrict(t *testing.T) {
+	priv := rfc6979Key(t)
+	hash := Sha256([]byte("sample"))
+	sig, _ := Sign(priv, hash)
+	r, s, _ := ParseDERSignature(sig, priv.Curve)
+	N := priv.Curve.Params().N
+
+	highS := encodeDER(r, new(big.Int).Sub(N, s))
+	//0x30 <len> 0x02 0x01 <r> 0x02 0x01 <s>
+	tiny := func(r, s byte) []byte {
+		return []byte{0x30, 0x06, 0x02, 0x01, r, 0x02, 0x01, s}
+	}
+	withPadding := append([]byte{0x30, byte(len(sig) - 1), 0x02, sig[3] + 1, 0x00}, sig[4:]...)
+	trailingInside := append(append([]byte{0x30, sig[1] + 1}, sig[2:]...), 0x01)
+	wrongRLength := append([]byte{}, sig...)
+	wrongRLength[3]++
+
+	tests := []struct {
+		name string
+		sig  []byte
+	}{
+		{"high S", highS},
+		{"negative r", tiny(0x80, 0x01)},
+		{"negative s", tiny(0x01, 0x81)},
+		{"zero r", tiny(0x00, 0x01)},
+		{"excess padding", withPadding},
+		{"wrong total length", append([]byte{0x30, sig[1] - 1}, sig[2:]...)},
+		{"wrong integer length", wrongRLength},
+		{"trailing garbage", append(append([]byte{}, sig...), 0x00)},
+		{"trailing data inside sequence", trailingInside},
+		{"no header", append([]byte{0x31}, sig[1:]...)},
+		{"too short", sig[:7]},
+	}
+	for _, test := range tests {
+		if _, _, err := ParseDERSignature(test.sig, priv.Curve); err == nil {
+			t.Fatalf("%s: signature %x accepted", test.name, test.sig)
+		}
+		if VerifySignature(&priv.PublicKey, hash, test.sig) {
+			t.Fatalf("%s: signature %x verified", test.name, test.sig)
+		}
+	}
+
+	//une signature minimale bien formée reste acceptée
+	if _, _, err := ParseDERSignature(tiny(0x01, 0x01), priv.Curve); err != nil {
+		t.Fatalf("minimal signature rejected: %v", err)
+	}
+}
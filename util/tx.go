@@ -12,6 +12,8 @@ type Input struct {
 	Vout []byte //[4]
 	TxInScriptLen []byte //[1-9]
 	ScriptSig [][]byte 
+	//vide pour un input final, n'est pas sérialisé (voir twayutil.Input)
+	Sequence []byte `json:",omitempty"` //[4]
 }
 
 type Output struct {
@@ -56,4 +58,32 @@ func DeserializeTransaction(data []byte) Transaction {
 	}
 	json.Unmarshal(dataByte, &tx)
 	return tx
-}
\ No newline at end of file
+}
+
+//Version des transactions dont les signatures engagent la transaction elle-même
+const SigHashTxVersion = byte(0x01)
+
+//Retourne true si les signatures de la transaction engagent ses inputs,
+//leurs séquences et ses outputs
+func (tx *Transaction) CommitsToOutputs() bool {
+	return len(tx.Version) == 1 && tx.Version[0] >= SigHashTxVersion
+}
+
+//Retourne le hash signé par l'input à l'index idx de la transaction,
+//prevTx est la transaction contenant l'output dépensé.
+//Avant SigHashTxVersion seule la transaction précédente est signée : les outputs
+//et les séquences peuvent être modifiés sans invalider les signatures.
+//À partir de SigHashTxVersion la signature engage aussi les inputs sans leur scriptSig,
+//leurs séquences, les outputs, le locktime et l'index de l'input signé
+func SigHash(tx *Transaction, idx int, prevTx *Transaction) []byte {
+	if tx.CommitsToOutputs() == false {
+		return Sha256(prevTx.Serialize())
+	}
+	unsigned := *tx
+	unsigned.Inputs = make([]Input, len(tx.Inputs))
+	for i, in := range tx.Inputs {
+		unsigned.Inputs[i] = Input{PrevTransactionHash: in.PrevTransactionHash, Vout: in.Vout, Sequence: in.Sequence}
+	}
+	data := append(prevTx.Serialize(), unsigned.Serialize()...)
+	return Sha256(append(data, EncodeInt(idx)...))
+}
@@ -0,0 +1,70 @@
+package util
+
+import (
+	"bytes"
+	"testing"
+)
+
+func sigHashTestTx(version byte) (*Transaction, *Transaction) {
+	prevTx := &Transaction{Version: []byte{version}, Outputs: []Output{{Value: []byte("64")}, {Value: []byte("32")}}}
+	prevHash := Sha256(prevTx.Serialize())
+	tx := &Transaction{
+		Version: []byte{version},
+		Inputs: []Input{
+			{PrevTransactionHash: prevHash, Vout: EncodeInt(0), Sequence: EncodeInt(0xfffffffd)},
+			{PrevTransactionHash: prevHash, Vout: EncodeInt(1)},
+		},
+		Outputs: []Output{{Value: []byte("60")}},
+	}
+	return tx, prevTx
+}
+
+func TestSigHashCommitsToTx(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(tx *Transaction)
+	}{
+		{"output value", func(tx *Transaction) { tx.Outputs[0].Value = []byte("5f") }},
+		{"output script", func(tx *Transaction) { tx.Outputs[0].ScriptPubKey = [][]byte{{0x01}} }},
+		{"added output", func(tx *Transaction) { tx.Outputs = append(tx.Outputs, Output{Value: []byte("01")}) }},
+		{"sequence", func(tx *Transaction) { tx.Inputs[0].Sequence = nil }},
+		{"other input sequence", func(tx *Transaction) { tx.Inputs[1].Sequence = EncodeInt(1) }},
+		{"locktime", func(tx *Transaction) { tx.LockTime = EncodeInt(100) }},
+	}
+	for _, test := range tests {
+		tx, prevTx := sigHashTestTx(SigHashTxVersion)
+		hash := SigHash(tx, 0, prevTx)
+		test.change(tx)
+		if bytes.Compare(SigHash(tx, 0, prevTx), hash) == 0 {
+			t.Fatalf("%s: signature hash unchanged", test.name)
+		}
+	}
+
+	tx, prevTx := sigHashTestTx(SigHashTxVersion)
+	if bytes.Compare(SigHash(tx, 0, prevTx), SigHash(tx, 1, prevTx)) == 0 {
+		t.Fatal("inputs sign the same hash")
+	}
+	//les scriptSigs ne sont pas signés
+	hash := SigHash(tx, 0, prevTx)
+	tx.Inputs[1].ScriptSig = [][]byte{{0x30}, {0x02}}
+	if bytes.Compare(SigHash(tx, 0, prevTx), hash) != 0 {
+		t.Fatal("signature hash depends on scriptSigs")
+	}
+}
+
+//Les transactions de version précédente ne signent que la transaction précédente
+func TestSigHashLegacy(t *testing.T) {
+	tx, prevTx := sigHashTestTx(0x00)
+	if tx.CommitsToOutputs() {
+		t.Fatal("legacy transaction commits to its outputs")
+	}
+	hash := SigHash(tx, 0, prevTx)
+	if bytes.Compare(hash, Sha256(prevTx.Serialize())) != 0 {
+		t.Fatal("legacy signature hash changed")
+	}
+	tx.Outputs[0].Value = []byte("5f")
+	tx.Inputs[0].Sequence = nil
+	if bytes.Compare(SigHash(tx, 1, prevTx), hash) != 0 {
+		t.Fatal("legacy signature hash depends on the spending transaction")
+	}
+}
@@ -0,0 +1,110 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	conf "tway/config"
+	"tway/util"
+)
+
+//Type d'une adresse, premier caractère des données d'une adresse bech32
+type AddressType byte
+
+const (
+	PubKeyHashAddress AddressType = 0
+	ScriptHashAddress AddressType = 1
+)
+
+var ErrInvalidAddress = errors.New("invalid address")
+
+//Adresse décodée : pubKeyHash ou hash du script selon son type
+type Address struct {
+	Type AddressType
+	Hash []byte
+}
+
+//Retourne la version base58 d'un type d'adresse sur le réseau actif
+func addressVersion(t AddressType) byte {
+	if t == ScriptHashAddress {
+		return conf.ActiveNet.ScriptHashAddrID
+	}
+	return conf.ActiveNet.PubKeyHashAddrID
+}
+
+//Encode un hash en adresse base58 avec la version du réseau actif et un checksum
+//(processus utilisé par le BTC)
+func encodeBase58Address(t AddressType, hash []byte) []byte {
+	versionedPayload := append([]byte{addressVersion(t)}, hash...)
+	fullPayload := append(versionedPayload, checksum(versionedPayload)...)
+	return util.Base58Encode(fullPayload)
+}
+
+//Encode un hash en adresse bech32 avec le préfixe du réseau actif
+//Le type d'adresse est placé devant le hash
+func EncodeBech32Address(t AddressType, hash []byte) (string, error) {
+	data, err := util.ConvertBits(hash, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	return util.Bech32Encode(conf.ActiveNet.Bech32HRP, append([]byte{byte(t)}, data...))
+}
+
+//Decode une adresse base58 ou bech32 du réseau actif
+func DecodeAddress(addr string) (*Address, error) {
+	if strings.HasPrefix(strings.ToLower(addr), conf.ActiveNet.Bech32HRP+"1") {
+		return decodeBech32Address(addr)
+	}
+	if util.IsBase58([]byte(addr)) == false {
+		return nil, ErrInvalidAddress
+	}
+	decoded := util.Base58Decode([]byte(addr))
+	if len(decoded) != 1+conf.PubKeyHLength+AddressChecksumLen {
+		return nil, ErrInvalidAddress
+	}
+	payload := decoded[:len(decoded)-AddressChecksumLen]
+	if bytes.Compare(checksum(payload), decoded[len(payload):]) != 0 {
+		return nil, errors.New("address checksum doesn't match")
+	}
+	switch payload[0] {
+	case conf.ActiveNet.PubKeyHashAddrID:
+		return &Address{PubKeyHashAddress, payload[1:]}, nil
+	case conf.ActiveNet.ScriptHashAddrID:
+		return &Address{ScriptHashAddress, payload[1:]}, nil
+	}
+	return nil, fmt.Errorf("address doesn't belong to the %s network", conf.ActiveNet.Name)
+}
+
+func decodeBech32Address(addr string) (*Address, error) {
+	hrp, data, err := util.Bech32Decode(addr)
+	if err != nil {
+		return nil, err
+	}
+	if hrp != conf.ActiveNet.Bech32HRP {
+		return nil, fmt.Errorf("address doesn't belong to the %s network", conf.ActiveNet.Name)
+	}
+	if len(data) == 0 || (AddressType(data[0]) != PubKeyHashAddress && AddressType(data[0]) != ScriptHashAddress) {
+		return nil, ErrInvalidAddress
+	}
+	hash, err := util.ConvertBits(data[1:], 5, 8, false)
+	if err != nil || len(hash) != conf.PubKeyHLength {
+		return nil, ErrInvalidAddress
+	}
+	return &Address{AddressType(data[0]), hash}, nil
+}
+
+//Retourne l'adresse au format base58, utilisé pour indexer les wallets locaux
+func (a *Address) String() string {
+	return string(encodeBase58Address(a.Type, a.Hash))
+}
+
+//Retourne l'adresse au format base58 si elle est valide, inchangée sinon
+//Une adresse bech32 désigne ainsi les mêmes wallets locaux que son équivalent base58
+func NormalizeAddress(addr string) string {
+	decoded, err := DecodeAddress(addr)
+	if err != nil {
+		return addr
+	}
+	return decoded.String()
+}
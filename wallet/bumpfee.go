@@ -0,0 +1,121 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	b "tway/blockchain"
+	"tway/script"
+	"tway/twayutil"
+	"tway/util"
+)
+
+//Récupère une transaction non confirmée de l'historique pouvant être accélérée
+func getPendingWalletTx(txID string) (*WalletTx, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	wtx, exist := History[txID]
+	if exist == false {
+		return nil, errors.New("transaction not found in wallet history")
+	}
+	if wtx.Height > -1 {
+		return nil, errors.New("transaction is already confirmed")
+	}
+	if wtx.Tx == nil {
+		return nil, errors.New("transaction content is unknown")
+	}
+	if wtx.ReplacedBy != "" {
+		return nil, fmt.Errorf("transaction is already replaced by %s", wtx.ReplacedBy)
+	}
+	return wtx, nil
+}
+
+//Retourne les frais d'une transaction non confirmée
+func pendingTxFee(tx *twayutil.Transaction) (int, error) {
+	historyMu.Lock()
+	prevTxs := selectedWallet().findPrevTxs(tx)
+	historyMu.Unlock()
+	if len(prevTxs) != len(uniquePrevTxs(tx)) {
+		return 0, errors.New("fees of the transaction are unknown")
+	}
+	return tx.GetFees(prevTxs), nil
+}
+
+func uniquePrevTxs(tx *twayutil.Transaction) map[string]bool {
+	list := make(map[string]bool)
+	for _, in := range tx.Inputs {
+		list[hex.EncodeToString(in.PrevTransactionHash)] = true
+	}
+	return list
+}
+
+//Retourne true si l'output est envoyé vers une adresse de rendu du wallet
+func isChangeOutput(out twayutil.Output) bool {
+	if script.Script.GetScriptClass(out.ScriptPubKey) != script.PubKeyHashTy {
+		return false
+	}
+	w := GetWalletByPubKeyHash(out.ScriptPubKey[2])
+	return w != nil && w.Change
+}
+
+//Créer une transaction remplaçant une transaction non confirmée du wallet
+//avec un taux de frais supérieur (replace-by-fee).
+//La transaction dépense les mêmes inputs et paie les mêmes destinataires,
+//les frais supplémentaires sont prélevés sur le rendu.
+//Retourne la transaction et la sélection d'UTXOs contenant les nouveaux frais.
+func BumpFee(txID string, feeRate int) (*twayutil.Transaction, *CoinSelection, error) {
+	wtx, err := getPendingWalletTx(txID)
+	if err != nil {
+		return nil, nil, err
+	}
+	tx := wtx.Tx
+	if wtx.Direction == TxReceived {
+		return nil, nil, errors.New("transaction is not sent by the wallet")
+	}
+	if tx.SignalsReplacement() == false {
+		return nil, nil, errors.New("transaction doesn't signal replaceability")
+	}
+	oldFee, err := pendingTxFee(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	oldSize := int(tx.GetSize())
+	//le nouveau taux de frais doit être strictement supérieur
+	if oldRate := oldFee / oldSize; feeRate <= oldRate {
+		feeRate = oldRate + 1
+	}
+
+	//les inputs de la transaction remplacée doivent tous être dépensés
+	//par la nouvelle transaction afin d'entrer en conflit avec elle
+	var inputs []LocalUnspentOutput
+	for _, in := range tx.Inputs {
+		vout := util.DecodeInt(in.Vout)
+		us := b.UTXO.GetUnSpentOutputByVoutAndTxHash(vout, in.PrevTransactionHash)
+		if us == nil {
+			return nil, nil, fmt.Errorf("input %s is not a confirmed unspent output", outpoint(in.PrevTransactionHash, vout))
+		}
+		w := spendingWallet(us.Output.ScriptPubKey)
+		if w == nil {
+			return nil, nil, fmt.Errorf("input %s can't be spent by a local wallet", outpoint(in.PrevTransactionHash, vout))
+		}
+		inputs = append(inputs, LocalUnspentOutput{us.TxID, us.Idx, util.DecodeInt(us.Output.Value), w, 0})
+	}
+	var outputs []twayutil.Output
+	for _, out := range tx.Outputs {
+		if isChangeOutput(out) == false {
+			outputs = append(outputs, out)
+		}
+	}
+
+	newTx, selection, err := FundTransaction(outputs, FundOptions{FeeRate: feeRate, UTXOs: inputs, Replaceable: true})
+	if err == ErrInsufficientFunds {
+		return nil, nil, errors.New("change of the transaction can't pay the new fees")
+	} else if err != nil {
+		return nil, nil, err
+	}
+	//règles de remplacement de la mempool
+	if selection.Fee <= oldFee || selection.Fee*oldSize <= oldFee*int(newTx.GetSize()) {
+		return nil, nil, errors.New("new fees are not higher than fees of the transaction")
+	}
+	return newTx, selection, nil
+}
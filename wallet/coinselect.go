@@ -0,0 +1,268 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	mathr "math/rand"
+	"sort"
+	"tway/util"
+)
+
+//Tailles estimées (en octets) utilisées pour calculer les frais d'une transaction,
+//voir Transaction.GetSize
+const (
+	//version, compteurs d'inputs et d'outputs, locktime
+	TxBaseSize = 10
+	//outpoint (32 + 4), longueur du script, signature DER et clé publique compressée
+	//les UTXOs des wallets locaux sont dépensés avec une signature et une clé publique
+	InputSize = 32 + 4 + 1 + 72 + 33
+	//valeur, longueur du script et script P2PKH (4 opcodes et un pubKeyHash de 20 octets)
+	ChangeOutputSize = 8 + 1 + 24
+	//Nombre maximum de combinaisons testées par le branch and bound
+	bnbMaxTries = 100000
+	//Montant minimum d'un rendu, un rendu inférieur est ajouté aux frais
+	DustThreshold = 546
+)
+
+//Stratégie utilisée si aucune n'est précisée
+const DefaultCoinSelector = "bnb"
+
+var ErrInsufficientFunds = errors.New("You don't have enough coin to perform this transaction.")
+
+//Modèle de coût d'une transaction : les frais sont composés d'un montant
+//fixe et d'un montant proportionnel à la taille estimée de la transaction
+type CostModel struct {
+	Amount      int //montant total des outputs, hors rendu
+	Fees        int //frais fixes
+	FeeRate     int //frais par octet
+	OutputsSize int //taille des outputs, hors rendu
+}
+
+//Créer un modèle de coût pour une liste de scriptPubKey d'outputs
+func NewCostModel(amount, fees, feeRate int, outputScripts [][][]byte) CostModel {
+	m := CostModel{Amount: amount, Fees: fees, FeeRate: feeRate}
+	for _, scriptPubKey := range outputScripts {
+		m.OutputsSize += 8 + 1 + util.LenDoubleSliceByte(scriptPubKey)
+	}
+	return m
+}
+
+//Montant que doit atteindre la valeur effective des inputs sélectionnés
+func (m CostModel) Target() int {
+	return m.Amount + m.Fees + m.FeeRate*(TxBaseSize+m.OutputsSize)
+}
+
+//Frais nécessaires pour dépenser un UTXO
+func (m CostModel) InputCost() int {
+	return m.FeeRate * InputSize
+}
+
+//Frais nécessaires pour ajouter un output de rendu
+func (m CostModel) ChangeCost() int {
+	return m.FeeRate * ChangeOutputSize
+}
+
+//Valeur d'un UTXO une fois les frais de sa dépense déduits
+func (m CostModel) EffectiveValue(us LocalUnspentOutput) int {
+	return us.Amount - m.InputCost()
+}
+
+//Résultat d'une sélection d'UTXOs
+type CoinSelection struct {
+	Inputs []LocalUnspentOutput
+	Total  int //somme des UTXOs sélectionnés
+	Fee    int //frais totaux de la transaction
+	Change int //montant du rendu, 0 si la transaction n'a pas de rendu
+}
+
+//Calcule les frais et le rendu d'une sélection
+//Si l'excédent, une fois le coût d'un output de rendu déduit, est inférieur
+//à DustThreshold, il est ajouté aux frais
+func (m CostModel) newSelection(inputs []LocalUnspentOutput) *CoinSelection {
+	s := &CoinSelection{Inputs: inputs}
+	for _, us := range inputs {
+		s.Total += us.Amount
+	}
+	s.Fee = m.Target() - m.Amount + len(inputs)*m.InputCost()
+	excess := s.Total - m.Amount - s.Fee
+	if excess-m.ChangeCost() >= DustThreshold {
+		s.Change = excess - m.ChangeCost()
+		s.Fee += m.ChangeCost()
+	} else {
+		s.Fee += excess
+	}
+	return s
+}
+
+//Stratégie de sélection des UTXOs financant une transaction
+type CoinSelector interface {
+	//Retourne une liste d'UTXOs dont la valeur effective atteint m.Target()
+	Select(candidates []LocalUnspentOutput, m CostModel) ([]LocalUnspentOutput, error)
+}
+
+var coinSelectors = map[string]CoinSelector{
+	"bnb":            bnbSelector{},
+	"largest-first":  sortedSelector{largestFirst: true},
+	"smallest-first": sortedSelector{largestFirst: false},
+	"random":         randomSelector{},
+}
+
+//Ajoute une stratégie de sélection utilisable avec SelectCoins
+func RegisterCoinSelector(name string, selector CoinSelector) {
+	coinSelectors[name] = selector
+}
+
+//Retourne le nom des stratégies de sélection disponibles
+func CoinSelectorNames() []string {
+	var names []string
+	for name := range coinSelectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//Sélectionne les UTXOs financant une transaction avec la stratégie passée en paramètre
+//Les UTXOs dont la valeur ne couvre pas les frais de leur dépense sont ignorés
+func SelectCoins(strategy string, candidates []LocalUnspentOutput, m CostModel) (*CoinSelection, error) {
+	if strategy == "" {
+		strategy = DefaultCoinSelector
+	}
+	selector, exist := coinSelectors[strategy]
+	if exist == false {
+		return nil, fmt.Errorf("unknown coin selection strategy %s", strategy)
+	}
+	var spendable []LocalUnspentOutput
+	for _, us := range candidates {
+		if us.AmountLockedByMultiSig == 0 && m.EffectiveValue(us) > 0 {
+			spendable = append(spendable, us)
+		}
+	}
+	inputs, err := selector.Select(spendable, m)
+	if err != nil {
+		return nil, err
+	}
+	return m.newSelection(inputs), nil
+}
+
+//Calcule les frais et le rendu d'une transaction dépensant tous les UTXOs passés en paramètre
+func NewManualSelection(inputs []LocalUnspentOutput, m CostModel) (*CoinSelection, error) {
+	var value int
+	for _, us := range inputs {
+		value += m.EffectiveValue(us)
+	}
+	if value < m.Target() {
+		return nil, ErrInsufficientFunds
+	}
+	return m.newSelection(inputs), nil
+}
+
+//Ajoute les UTXOs dans l'ordre de la liste jusqu'à atteindre le montant à financer
+func accumulate(candidates []LocalUnspentOutput, m CostModel) ([]LocalUnspentOutput, error) {
+	var selected []LocalUnspentOutput
+	var value int
+	for _, us := range candidates {
+		if value >= m.Target() {
+			break
+		}
+		selected = append(selected, us)
+		value += m.EffectiveValue(us)
+	}
+	if value < m.Target() {
+		return nil, ErrInsufficientFunds
+	}
+	return selected, nil
+}
+
+//Sélectionne les UTXOs par ordre de valeur
+type sortedSelector struct {
+	largestFirst bool
+}
+
+func (s sortedSelector) Select(candidates []LocalUnspentOutput, m CostModel) ([]LocalUnspentOutput, error) {
+	sorted := append([]LocalUnspentOutput{}, candidates...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if s.largestFirst {
+			return sorted[i].Amount > sorted[j].Amount
+		}
+		return sorted[i].Amount < sorted[j].Amount
+	})
+	return accumulate(sorted, m)
+}
+
+//Sélectionne les UTXOs dans un ordre aléatoire pour ne pas lier
+//systématiquement les mêmes adresses entre elles
+type randomSelector struct{}
+
+func (randomSelector) Select(candidates []LocalUnspentOutput, m CostModel) ([]LocalUnspentOutput, error) {
+	shuffled := append([]LocalUnspentOutput{}, candidates...)
+	mathr.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return accumulate(shuffled, m)
+}
+
+//Branch and bound : cherche une combinaison d'UTXOs dont la valeur effective
+//est comprise entre le montant à financer et ce montant augmenté du coût d'un rendu
+//et du seuil de poussière.
+//La transaction n'a alors pas de rendu, l'excédent est laissé en frais.
+//Si aucune combinaison n'est trouvée, les UTXOs sont sélectionnés par ordre de valeur décroissante.
+type bnbSelector struct{}
+
+func (bnbSelector) Select(candidates []LocalUnspentOutput, m CostModel) ([]LocalUnspentOutput, error) {
+	pool := append([]LocalUnspentOutput{}, candidates...)
+	sort.SliceStable(pool, func(i, j int) bool {
+		return pool[i].Amount > pool[j].Amount
+	})
+	target := m.Target()
+	upper := target + m.ChangeCost() + DustThreshold - 1
+
+	var available int
+	for _, us := range pool {
+		available += m.EffectiveValue(us)
+	}
+	if available < target {
+		return nil, ErrInsufficientFunds
+	}
+
+	current := make([]bool, len(pool))
+	var best []bool
+	bestExcess := -1
+	tries := 0
+
+	var search func(i, value, remaining int)
+	search = func(i, value, remaining int) {
+		if tries >= bnbMaxTries || bestExcess == 0 || value > upper {
+			return
+		}
+		tries++
+		if value >= target {
+			if best == nil || value-target < bestExcess {
+				best = append([]bool{}, current...)
+				bestExcess = value - target
+			}
+			return
+		}
+		if i == len(pool) || value+remaining < target {
+			return
+		}
+		eff := m.EffectiveValue(pool[i])
+		//branche incluant l'UTXO puis branche l'excluant
+		current[i] = true
+		search(i+1, value+eff, remaining-eff)
+		current[i] = false
+		search(i+1, value, remaining-eff)
+	}
+	search(0, 0, available)
+
+	if best == nil {
+		return accumulate(pool, m)
+	}
+	var selected []LocalUnspentOutput
+	for i, in := range best {
+		if in {
+			selected = append(selected, pool[i])
+		}
+	}
+	return selected, nil
+}
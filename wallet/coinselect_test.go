@@ -0,0 +1,108 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+	"tway/script"
+	"tway/twayutil"
+)
+
+func testCoins(amounts ...int) []LocalUnspentOutput {
+	var coins []LocalUnspentOutput
+	for i, amount := range amounts {
+		coins = append(coins, LocalUnspentOutput{TxID: []byte{byte(i)}, Amount: amount})
+	}
+	return coins
+}
+
+//Un output de 1000 avec un frais de 1 par octet : 1043 à financer, 142 par input
+func testCostModel() CostModel {
+	return NewCostModel(1000, 0, 1, [][][]byte{script.Script.LockingScript([][]byte{bytes.Repeat([]byte{0x01}, 20)}, 0)})
+}
+
+func checkSelection(t *testing.T, s *CoinSelection, m CostModel) {
+	if s.Total != m.Amount+s.Fee+s.Change {
+		t.Fatalf("total %d, amount %d, fee %d, change %d", s.Total, m.Amount, s.Fee, s.Change)
+	}
+	if s.Change != 0 && s.Change < DustThreshold {
+		t.Fatalf("dust change %d", s.Change)
+	}
+}
+
+func TestChangeOutputSize(t *testing.T) {
+	out := twayutil.NewTxOutput(script.Script.LockingScript([][]byte{bytes.Repeat([]byte{0x01}, 20)}, 0), 1)
+	if out.GetSize() != ChangeOutputSize {
+		t.Fatalf("P2PKH output size %d, ChangeOutputSize %d", out.GetSize(), ChangeOutputSize)
+	}
+}
+
+//Le branch and bound trouve la combinaison sans rendu plutôt que le plus gros UTXO
+func TestBnBExactMatch(t *testing.T) {
+	m := testCostModel()
+	s, err := SelectCoins("bnb", testCoins(5000, 742, 300, 585), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSelection(t, s, m)
+	if len(s.Inputs) != 2 || s.Total != 742+585 || s.Change != 0 || s.Fee != 327 {
+		t.Fatalf("selected %d inputs, total %d, fee %d, change %d", len(s.Inputs), s.Total, s.Fee, s.Change)
+	}
+}
+
+//Sans combinaison sans rendu, les UTXOs sont sélectionnés par ordre de valeur décroissante
+func TestBnBFallback(t *testing.T) {
+	m := testCostModel()
+	s, err := SelectCoins("bnb", testCoins(3000, 5000, 100), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSelection(t, s, m)
+	if len(s.Inputs) != 1 || s.Total != 5000 || s.Change != 3782 || s.Fee != 218 {
+		t.Fatalf("selected %d inputs, total %d, fee %d, change %d", len(s.Inputs), s.Total, s.Fee, s.Change)
+	}
+}
+
+//Un excédent inférieur au seuil de poussière une fois le coût du rendu déduit est laissé en frais
+func TestChangeDustThreshold(t *testing.T) {
+	m := testCostModel()
+	tests := []struct {
+		amount int
+		fee    int
+		change int
+	}{
+		{1000 + 185 + ChangeOutputSize + DustThreshold - 1, 185 + ChangeOutputSize + DustThreshold - 1, 0},
+		{1000 + 185 + ChangeOutputSize + DustThreshold, 185 + ChangeOutputSize, DustThreshold},
+		{1185, 185, 0},
+	}
+	for _, test := range tests {
+		s, err := NewManualSelection(testCoins(test.amount), m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSelection(t, s, m)
+		if s.Fee != test.fee || s.Change != test.change {
+			t.Fatalf("input %d: fee %d, change %d, want fee %d, change %d", test.amount, s.Fee, s.Change, test.fee, test.change)
+		}
+	}
+	if _, err := NewManualSelection(testCoins(1184), m); err != ErrInsufficientFunds {
+		t.Fatalf("insufficient input accepted: %v", err)
+	}
+}
+
+func TestSelectCoinsInsufficientFunds(t *testing.T) {
+	m := testCostModel()
+	for _, strategy := range CoinSelectorNames() {
+		//l'UTXO de 100 ne couvre pas le coût de sa dépense et est ignoré
+		if _, err := SelectCoins(strategy, testCoins(742, 584, 100), m); err != ErrInsufficientFunds {
+			t.Fatalf("%s: %v", strategy, err)
+		}
+		s, err := SelectCoins(strategy, testCoins(742, 585, 100), m)
+		if err != nil {
+			t.Fatalf("%s: %v", strategy, err)
+		}
+		checkSelection(t, s, m)
+		if len(s.Inputs) != 2 {
+			t.Fatalf("%s: selected %d inputs", strategy, len(s.Inputs))
+		}
+	}
+}
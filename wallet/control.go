@@ -0,0 +1,143 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+//Commandes transmises au serveur du noeud par sa socket de contrôle
+const (
+	controlUnlock  = "unlock"
+	controlLock    = "lock"
+	controlEncrypt = "encrypt"
+)
+
+//Retournée par les commandes de contrôle si le serveur du noeud n'est pas lancé
+var ErrNoServer = errors.New("the server of the node is not running")
+
+//Commande envoyée au serveur, encodée en JSON sur une ligne
+type controlRequest struct {
+	Command string `json:"command"`
+	//wallet de la commande, il doit être celui sélectionné par le serveur
+	Wallet     string `json:"wallet"`
+	Passphrase string `json:"passphrase,omitempty"`
+	Timeout    int    `json:"timeout,omitempty"` //en secondes
+}
+
+type controlResponse struct {
+	Error string `json:"error,omitempty"`
+}
+
+//Socket unix du serveur du noeud, accessible uniquement par son utilisateur
+func controlSocketFile() string {
+	return nodeWalletFile + ".sock"
+}
+
+//Ouvre la socket de contrôle du serveur : les commandes wallet --unlock, --lock
+//et --encrypt s'appliquent aux clés gardées dans la mémoire du serveur.
+//Les connexions sont traitées par une goroutine jusqu'à l'arrêt du processus
+func ListenControl() error {
+	path := controlSocketFile()
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return errors.New("the server of the node is already running")
+	}
+	//socket d'un serveur arrêté
+	os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return err
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			go handleControl(conn)
+		}
+	}()
+	return nil
+}
+
+func handleControl(conn net.Conn) {
+	defer conn.Close()
+	var req controlRequest
+	var resp controlResponse
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		resp.Error = err.Error()
+	} else if err := req.apply(); err != nil {
+		resp.Error = err.Error()
+	}
+	json.NewEncoder(conn).Encode(resp)
+}
+
+//Applique la commande au wallet sélectionné par le serveur
+func (req *controlRequest) apply() error {
+	if req.Wallet != WalletName && WalletName == DefaultWalletName {
+		return errors.New("the server uses the default wallet of the node")
+	} else if req.Wallet != WalletName {
+		return fmt.Errorf("the server uses the wallet %s", WalletName)
+	}
+	switch req.Command {
+	case controlUnlock:
+		return Unlock(req.Passphrase, time.Duration(req.Timeout)*time.Second)
+	case controlLock:
+		if IsEncrypted() == false {
+			return errors.New("wallet is not encrypted")
+		}
+		Lock()
+		return nil
+	case controlEncrypt:
+		return EncryptWallet(req.Passphrase)
+	}
+	return fmt.Errorf("unknown command %s", req.Command)
+}
+
+//Envoie la commande au serveur du noeud et retourne son erreur
+//Retourne ErrNoServer si aucun serveur n'écoute la socket de contrôle
+func sendControl(req controlRequest) error {
+	req.Wallet = WalletName
+	conn, err := net.Dial("unix", controlSocketFile())
+	if err != nil {
+		return ErrNoServer
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
+	var resp controlResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
+
+//Déverrouille le wallet du serveur du noeud pour la durée passée en paramètre
+func UnlockServer(passphrase string, timeout time.Duration) error {
+	return sendControl(controlRequest{Command: controlUnlock, Passphrase: passphrase, Timeout: int(timeout / time.Second)})
+}
+
+//Verrouille le wallet du serveur du noeud, ses clés déchiffrées sont effacées
+func LockServer() error {
+	return sendControl(controlRequest{Command: controlLock})
+}
+
+//Chiffre le wallet du serveur du noeud : le serveur ne garde pas en mémoire
+//les clés en clair d'un fichier .dat chiffré par une autre commande
+func EncryptServer(passphrase string) error {
+	return sendControl(controlRequest{Command: controlEncrypt, Passphrase: passphrase})
+}
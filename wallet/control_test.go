@@ -0,0 +1,93 @@
+package wallet
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestControlNoServer(t *testing.T) {
+	testNodeWallets(t)
+	if err := LockServer(); err != ErrNoServer {
+		t.Fatalf("error %v, want %v", err, ErrNoServer)
+	}
+}
+
+//Les commandes de contrôle s'appliquent au wallet sélectionné par le serveur
+func TestControlLockUnlock(t *testing.T) {
+	testNodeWallets(t)
+	if err := ListenControl(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(controlSocketFile())
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("socket %v, error %v", info, err)
+	}
+	if err := ListenControl(); err == nil {
+		t.Fatal("second server listening on the control socket")
+	}
+
+	if err := LockServer(); err == nil || err.Error() != "wallet is not encrypted" {
+		t.Fatalf("error %v", err)
+	}
+	if err := EncryptServer("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if IsEncrypted() == false || IsLocked() == false {
+		t.Fatal("wallet of the server not encrypted and locked")
+	}
+	content, err := readWalletFile(nodeWalletFile)
+	if err != nil || content.Crypto == nil {
+		t.Fatalf("wallet file not encrypted, error %v", err)
+	}
+
+	if err := UnlockServer("wrong", time.Minute); err == nil || err.Error() != "wrong passphrase" {
+		t.Fatalf("error %v", err)
+	}
+	if err := UnlockServer("passphrase", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if IsLocked() {
+		t.Fatal("wallet of the server still locked")
+	}
+	if err := LockServer(); err != nil {
+		t.Fatal(err)
+	}
+	if IsLocked() == false {
+		t.Fatal("wallet of the server still unlocked")
+	}
+
+	//commande d'un processus utilisant un autre wallet
+	req := &controlRequest{Command: controlLock, Wallet: "savings"}
+	if err := req.apply(); err == nil || err.Error() != "the server uses the default wallet of the node" {
+		t.Fatalf("error %v", err)
+	}
+}
+
+//Le délai de déverrouillage expire pendant que d'autres goroutines signent
+func TestUnlockConcurrent(t *testing.T) {
+	testNodeWallets(t)
+	if err := EncryptWallet("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				IsLocked()
+				encryptionKey()
+			}
+		}()
+	}
+	if err := Unlock("passphrase", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	Lock()
+	wg.Wait()
+	if IsLocked() == false {
+		t.Fatal("wallet still unlocked")
+	}
+}
@@ -0,0 +1,309 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+	"tway/keys"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	//Paramètres argon2id de dérivation de la clé de chiffrement
+	kdfTime    = 3
+	kdfMemory  = 64 * 1024 //en KiB
+	kdfThreads = 4
+	kdfKeyLen  = 32
+	kdfSaltLen = 16
+)
+
+//Valeur chiffrée avec la clé du wallet pour vérifier la passphrase
+var cryptoCheckValue = []byte("tway wallet")
+
+var ErrLocked = errors.New("wallet is locked, its passphrase is required")
+
+//Durée du déverrouillage obtenu avec PassphrasePrompt
+const PromptUnlockTimeout = 10 * time.Minute
+
+//Paramètres de chiffrement du wallet, sauvegardés dans le fichier .dat
+//Les clés privées et la phrase mnémonique sont chiffrées avec AES-256-GCM,
+//la clé de chiffrement est dérivée de la passphrase avec argon2id.
+type WalletCrypto struct {
+	Salt    []byte
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	Check   []byte
+}
+
+var (
+	//clé de chiffrement, nil si le wallet est verrouillé
+	//elle reste dans la mémoire du processus et n'est jamais écrite sur le disque
+	walletKey     []byte
+	unlockedUntil time.Time
+	//verrou de Crypto, walletKey, unlockedUntil et des clés déchiffrées :
+	//le serveur du noeud est déverrouillé et verrouillé par ListenControl
+	//pendant que ses autres goroutines signent
+	cryptoMu sync.Mutex
+
+	//Demande la passphrase lorsqu'une clé privée est nécessaire et que le wallet
+	//est verrouillé. Définie par les commandes lancées depuis un terminal,
+	//le serveur est déverrouillé une seule fois à son démarrage.
+	PassphrasePrompt func() (string, error)
+)
+
+func (c *WalletCrypto) deriveKey(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), c.Salt, c.Time, c.Memory, c.Threads, kdfKeyLen)
+}
+
+//Chiffre les données, le nonce est placé devant le texte chiffré
+func seal(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+//Déchiffre des données chiffrées avec seal
+func open(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("encrypted data is too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+//Retourne true si les clés du wallet sont chiffrées
+func IsEncrypted() bool {
+	cryptoMu.Lock()
+	defer cryptoMu.Unlock()
+	return Crypto != nil
+}
+
+//Retourne true si les clés privées ne sont pas accessibles
+//Le wallet est verrouillé automatiquement à l'expiration du délai de déverrouillage
+func IsLocked() bool {
+	cryptoMu.Lock()
+	defer cryptoMu.Unlock()
+	return isLocked()
+}
+
+//cryptoMu doit être verrouillé par l'appelant
+func isLocked() bool {
+	if Crypto == nil {
+		return false
+	}
+	if walletKey == nil {
+		return true
+	}
+	if time.Now().After(unlockedUntil) {
+		lock()
+		return true
+	}
+	return false
+}
+
+//Retourne ErrLocked si le wallet est verrouillé
+//La passphrase est demandée avec PassphrasePrompt si celle-ci est définie
+func CheckUnlocked() error {
+	if IsLocked() == false {
+		return nil
+	}
+	if PassphrasePrompt == nil {
+		return ErrLocked
+	}
+	passphrase, err := PassphrasePrompt()
+	if err != nil {
+		return err
+	}
+	return Unlock(passphrase, PromptUnlockTimeout)
+}
+
+//Chiffre les clés du wallet avec la passphrase puis le verrouille
+func EncryptWallet(passphrase string) error {
+	if IsEncrypted() {
+		return errors.New("wallet is already encrypted")
+	}
+	if passphrase == "" {
+		return errors.New("passphrase can't be empty")
+	}
+	c := &WalletCrypto{Time: kdfTime, Memory: kdfMemory, Threads: kdfThreads}
+	c.Salt = make([]byte, kdfSaltLen)
+	if _, err := rand.Read(c.Salt); err != nil {
+		return err
+	}
+	key := c.deriveKey(passphrase)
+	check, err := seal(key, cryptoCheckValue)
+	if err != nil {
+		return err
+	}
+	c.Check = check
+
+	cryptoMu.Lock()
+	Crypto = c
+	walletKey = key
+	unlockedUntil = time.Now().Add(PromptUnlockTimeout)
+	cryptoMu.Unlock()
+	//SaveToFile chiffre chaque clé privée avec walletKey
+	err = SaveToFile()
+	Lock()
+	return err
+}
+
+//Déverrouille le wallet du processus pour la durée passée en paramètre
+func Unlock(passphrase string, timeout time.Duration) error {
+	cryptoMu.Lock()
+	c := Crypto
+	cryptoMu.Unlock()
+	if c == nil {
+		return errors.New("wallet is not encrypted")
+	}
+	if timeout <= 0 {
+		return errors.New("timeout must be greater than 0")
+	}
+	//la dérivation de la clé est longue, elle est faite sans verrou
+	key := c.deriveKey(passphrase)
+	cryptoMu.Lock()
+	defer cryptoMu.Unlock()
+	return unlockWithKey(key, time.Now().Add(timeout))
+}
+
+//Déchiffre les clés privées et la phrase mnémonique avec la clé
+//cryptoMu doit être verrouillé par l'appelant
+func unlockWithKey(key []byte, until time.Time) error {
+	if check, err := open(key, Crypto.Check); err != nil || bytes.Compare(check, cryptoCheckValue) != 0 {
+		return errors.New("wrong passphrase")
+	}
+	for _, w := range WalletList {
+		if len(w.EncryptedKey) == 0 {
+			continue
+		}
+		d, err := open(key, w.EncryptedKey)
+		if err != nil {
+			return err
+		}
+		w.PrivateKey = *keys.ParsePrivateKey(d)
+	}
+	if HD != nil && len(HD.EncryptedMnemonic) > 0 {
+		mnemonic, err := open(key, HD.EncryptedMnemonic)
+		if err != nil {
+			return err
+		}
+		HD.Mnemonic = string(mnemonic)
+	}
+	walletKey = key
+	unlockedUntil = until
+	return nil
+}
+
+//Verrouille le wallet : les clés privées déchiffrées sont effacées de la mémoire
+func Lock() {
+	cryptoMu.Lock()
+	defer cryptoMu.Unlock()
+	lock()
+}
+
+//cryptoMu doit être verrouillé par l'appelant
+func lock() {
+	if Crypto == nil {
+		return
+	}
+	for _, w := range WalletList {
+		if len(w.EncryptedKey) > 0 {
+			w.PrivateKey = ecdsa.PrivateKey{}
+		}
+	}
+	if HD != nil && len(HD.EncryptedMnemonic) > 0 {
+		HD.Mnemonic = ""
+	}
+	walletKey = nil
+	unlockedUntil = time.Time{}
+}
+
+//Oublie la clé et les paramètres de chiffrement du wallet précédemment ouvert
+func resetCrypto(c *WalletCrypto) {
+	cryptoMu.Lock()
+	defer cryptoMu.Unlock()
+	Crypto = c
+	walletKey = nil
+	unlockedUntil = time.Time{}
+}
+
+//Retourne la clé de chiffrement, la passphrase est demandée si le wallet est verrouillé
+func encryptionKey() ([]byte, error) {
+	if err := CheckUnlocked(); err != nil {
+		return nil, err
+	}
+	cryptoMu.Lock()
+	defer cryptoMu.Unlock()
+	if isLocked() {
+		return nil, ErrLocked
+	}
+	return walletKey, nil
+}
+
+//Retourne une copie des wallets telle qu'écrite dans le fichier .dat :
+//si le wallet est chiffré, les clés privées et la phrase mnémonique
+//sont remplacées par leur version chiffrée
+func encryptedContent() (map[string]*Wallet, *HDWallet, *WalletCrypto, error) {
+	cryptoMu.Lock()
+	c := Crypto
+	cryptoMu.Unlock()
+	if c == nil {
+		return WalletList, HD, nil, nil
+	}
+	wallets := make(map[string]*Wallet)
+	for addr, w := range WalletList {
+		if len(w.EncryptedKey) == 0 {
+			key, err := encryptionKey()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			encrypted, err := seal(key, keys.SerializePrivateKey(&w.PrivateKey))
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			w.EncryptedKey = encrypted
+		}
+		wallets[addr] = &Wallet{PublicKey: w.PublicKey, HDPath: w.HDPath, EncryptedKey: w.EncryptedKey}
+	}
+
+	var hd *HDWallet
+	if HD != nil {
+		if len(HD.EncryptedMnemonic) == 0 {
+			key, err := encryptionKey()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			encrypted, err := seal(key, []byte(HD.Mnemonic))
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			HD.EncryptedMnemonic = encrypted
+		}
+		hd = &HDWallet{Account: HD.Account, NextIndex: HD.NextIndex, EncryptedMnemonic: HD.EncryptedMnemonic}
+	}
+	return wallets, hd, c, nil
+}
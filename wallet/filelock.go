@@ -0,0 +1,24 @@
+package wallet
+
+import (
+	"log"
+	"os"
+	"syscall"
+)
+
+//Verrouille un fichier partagé entre le serveur et les commandes du noeud
+//Le verrou est posé sur le fichier path + ".lock", il est libéré par la fonction retournée
+func lockFile(path string) func() {
+	file, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		log.Panic(err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		log.Panic(err)
+	}
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}
+}
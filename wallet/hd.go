@@ -0,0 +1,189 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	b "tway/blockchain"
+	"tway/keys"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+const (
+	//Chaîne des adresses de réception
+	HDExternalChain = uint32(0)
+	//Chaîne des adresses de rendu de monnaie
+	HDInternalChain = uint32(1)
+	//Nombre d'adresses consécutives non utilisées avant l'arrêt d'un rescan
+	HDGapLimit = 20
+	//Taille de l'entropie de la phrase mnémonique (12 mots)
+	HDEntropyBits = 128
+)
+
+//Wallet déterministe hiérarchique
+//Toutes les clés sont dérivées de la phrase mnémonique selon le chemin
+//m/account'/chain/index, seule la phrase doit donc être sauvegardée.
+type HDWallet struct {
+	Mnemonic          string //vide tant que le wallet est verrouillé
+	Account           uint32
+	NextIndex         [2]uint32 //prochain index à dériver pour chaque chaîne
+	EncryptedMnemonic []byte
+}
+
+//Créer un wallet HD avec une nouvelle phrase mnémonique
+func NewHDWallet() (*HDWallet, error) {
+	entropy, err := bip39.NewEntropy(HDEntropyBits)
+	if err != nil {
+		return nil, err
+	}
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return nil, err
+	}
+	return &HDWallet{Mnemonic: mnemonic}, nil
+}
+
+//Créer un wallet HD à partir d'une phrase mnémonique existante
+func NewHDWalletFromMnemonic(mnemonic string) (*HDWallet, error) {
+	if bip39.IsMnemonicValid(mnemonic) == false {
+		return nil, errors.New("invalid mnemonic")
+	}
+	return &HDWallet{Mnemonic: mnemonic}, nil
+}
+
+//Retourne la clé étendue du compte du wallet (m/account')
+func (hd *HDWallet) accountKey() (*keys.ExtendedKey, error) {
+	if hd.Mnemonic == "" {
+		return nil, ErrLocked
+	}
+	master, err := keys.NewMasterKey(bip39.NewSeed(hd.Mnemonic, ""))
+	if err != nil {
+		return nil, err
+	}
+	return master.Child(keys.HardenedKeyStart + hd.Account)
+}
+
+//Dérive le wallet à la position chain/index du compte
+func (hd *HDWallet) DeriveWallet(chain, index uint32) (*Wallet, error) {
+	account, err := hd.accountKey()
+	if err != nil {
+		return nil, err
+	}
+	key, err := account.Derive([]uint32{chain, index})
+	if err != nil {
+		return nil, err
+	}
+	private := key.PrivateKey()
+	path := keys.PathString([]uint32{keys.HardenedKeyStart + hd.Account, chain, index})
+	return &Wallet{PrivateKey: *private, PublicKey: keys.PubKeyFromPrivate(private).SerializeCompressed(), HDPath: path}, nil
+}
+
+//Dérive le prochain wallet de la chaîne passée en paramètre
+//Le fichier .dat doit être mis à jour par l'appelant
+func (hd *HDWallet) NextWallet(chain uint32) (*Wallet, error) {
+	for {
+		w, err := hd.DeriveWallet(chain, hd.NextIndex[chain])
+		if err != nil && err != keys.ErrInvalidChild {
+			return nil, err
+		}
+		hd.NextIndex[chain]++
+		if err == nil {
+			return w, nil
+		}
+	}
+}
+
+//Initialise le wallet HD du noeud, à partir d'une phrase mnémonique
+//si celle-ci n'est pas vide. Retourne la phrase mnémonique.
+func InitHD(mnemonic string) (string, error) {
+	if HD != nil {
+		return "", errors.New("a HD seed already exists")
+	}
+	if err := CheckUnlocked(); err != nil {
+		return "", err
+	}
+	var hd *HDWallet
+	var err error
+	if mnemonic == "" {
+		hd, err = NewHDWallet()
+	} else {
+		hd, err = NewHDWalletFromMnemonic(mnemonic)
+	}
+	if err != nil {
+		return "", err
+	}
+	HD = hd
+	if err := SaveToFile(); err != nil {
+		HD = nil
+		return "", err
+	}
+	return hd.Mnemonic, nil
+}
+
+//Récupère l'ensemble des clés publiques et pubKeyHash (hex)
+//utilisés dans les outputs de la blockchain
+func getUsedScriptElements() map[string]bool {
+	used := make(map[string]bool)
+	be := b.NewExplorer()
+	for block := be.Next(); block != nil; block = be.Next() {
+		for _, tx := range block.Transactions {
+			for _, out := range tx.Outputs {
+				for _, elem := range out.ScriptPubKey {
+					if len(elem) > 1 {
+						used[hex.EncodeToString(elem)] = true
+					}
+				}
+			}
+		}
+	}
+	return used
+}
+
+//Parcourt les chaînes de réception et de rendu du wallet HD et ajoute
+//chaque adresse utilisée dans la blockchain à la liste des wallets.
+//Le parcours d'une chaîne s'arrête après gap adresses consécutives non utilisées.
+//Retourne le nombre d'adresses ajoutées.
+func RescanHD(gap int) (int, error) {
+	if HD == nil {
+		return 0, errors.New("no HD seed, use wallet --hd-new or --hd-restore")
+	}
+	if gap <= 0 {
+		gap = HDGapLimit
+	}
+	used := getUsedScriptElements()
+	added := 0
+
+	for _, chain := range []uint32{HDExternalChain, HDInternalChain} {
+		unused := 0
+		for index := uint32(0); unused < gap; index++ {
+			w, err := HD.DeriveWallet(chain, index)
+			if err == keys.ErrInvalidChild {
+				continue
+			} else if err != nil {
+				return added, err
+			}
+			if used[hex.EncodeToString(HashPubKey(w.PublicKey))] == false && used[hex.EncodeToString(w.PublicKey)] == false {
+				unused++
+				continue
+			}
+			unused = 0
+			w.Change = chain == HDInternalChain
+			addr := string(w.GetAddress())
+			if IsAddressStored(addr) == false {
+				WalletList[addr] = w
+				added++
+			}
+			if index >= HD.NextIndex[chain] {
+				HD.NextIndex[chain] = index + 1
+			}
+		}
+	}
+	if err := SaveToFile(); err != nil {
+		return added, err
+	}
+	if added > 0 {
+		//les transactions des adresses retrouvées sont ajoutées à l'historique
+		RebuildHistory(0)
+	}
+	return added, nil
+}
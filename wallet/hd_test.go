@@ -0,0 +1,148 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"tway/keys"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func testHDWallet(t *testing.T) *HDWallet {
+	hd, err := NewHDWalletFromMnemonic(testMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hd
+}
+
+//Les clés dérivées d'une même phrase ne changent pas d'une version à l'autre
+func TestHDDerivationVectors(t *testing.T) {
+	master, err := keys.NewMasterKey(bip39.NewSeed(testMnemonic, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(master.Key) != "f7d7d667e44617625e91b8f52825372f3dc46699eb0b134b27f1a93c08808383" ||
+		hex.EncodeToString(master.ChainCode) != "615d8fa3d0f026afd5c852af885d43c91a9738c2a54344e5e32a6b4e49c8d3a6" {
+		t.Fatalf("master key %x, chain code %x", master.Key, master.ChainCode)
+	}
+
+	tests := []struct {
+		chain  uint32
+		index  uint32
+		path   string
+		pubKey string
+	}{
+		{HDExternalChain, 0, "m/0'/0/0", "031db3483ac0a552bf1628e95a9dc9d3d6a3ee0cfcf36bb22864f6ec5c57227ca1"},
+		{HDExternalChain, 1, "m/0'/0/1", "02f852fbf83a8bcf1c703386d4b9fb74d724675e3abd38a8ca693f8df6d9a623e7"},
+		{HDInternalChain, 0, "m/0'/1/0", "034fb91956c3025107e6b02f8dde39363b42510d517749923a46220173c363f8cd"},
+	}
+	hd := testHDWallet(t)
+	for _, test := range tests {
+		w, err := hd.DeriveWallet(test.chain, test.index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w.HDPath != test.path || hex.EncodeToString(w.PublicKey) != test.pubKey {
+			t.Fatalf("%d/%d: got %s %x, want %s %s", test.chain, test.index, w.HDPath, w.PublicKey, test.path, test.pubKey)
+		}
+	}
+}
+
+//Le wallet de chain/index est la clé m/account'/chain/index dérivée index par index
+func TestHDChildIndex(t *testing.T) {
+	hd := testHDWallet(t)
+	hd.Account = 2
+	master, err := keys.NewMasterKey(bip39.NewSeed(testMnemonic, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := master
+	for _, i := range []uint32{keys.HardenedKeyStart + 2, HDInternalChain, 7} {
+		if key, err = key.Child(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := hd.DeriveWallet(HDInternalChain, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.HDPath != "m/2'/1/7" || bytes.Compare(keys.SerializePrivateKey(&w.PrivateKey), key.Key) != 0 {
+		t.Fatalf("%s: private key %x, want %x", w.HDPath, keys.SerializePrivateKey(&w.PrivateKey), key.Key)
+	}
+	if bytes.Compare(w.PublicKey, keys.PubKeyFromPrivate(key.PrivateKey()).SerializeCompressed()) != 0 {
+		t.Fatal("public key doesn't match the private key")
+	}
+}
+
+//Un index renforcé ne donne pas la même clé que l'index normal correspondant
+func TestHDHardened(t *testing.T) {
+	master, err := keys.NewMasterKey(bip39.NewSeed(testMnemonic, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	normal, err := master.Child(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hardened, err := master.Child(keys.HardenedKeyStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(normal.Key, hardened.Key) == 0 || bytes.Compare(normal.ChainCode, hardened.ChainCode) == 0 {
+		t.Fatal("hardened child equals normal child")
+	}
+	if s := keys.PathString([]uint32{keys.HardenedKeyStart, 1, keys.HardenedKeyStart + 5}); s != "m/0'/1/5'" {
+		t.Fatalf("path %s", s)
+	}
+}
+
+//Deux wallets restaurés avec la même phrase génèrent les mêmes adresses
+func TestHDDeterministicAddresses(t *testing.T) {
+	a, b := testHDWallet(t), testHDWallet(t)
+	seen := make(map[string]bool)
+	for _, chain := range []uint32{HDExternalChain, HDInternalChain} {
+		for i := 0; i < 5; i++ {
+			wa, err := a.NextWallet(chain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wb, err := b.NextWallet(chain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			addr := string(wa.GetAddress())
+			if addr != string(wb.GetAddress()) || wa.HDPath != wb.HDPath {
+				t.Fatalf("%s: %s and %s", wa.HDPath, addr, wb.GetAddress())
+			}
+			if seen[addr] {
+				t.Fatalf("%s: address %s derived twice", wa.HDPath, addr)
+			}
+			seen[addr] = true
+		}
+	}
+	if a.NextIndex != [2]uint32{5, 5} {
+		t.Fatalf("next index %v", a.NextIndex)
+	}
+	w, err := a.DeriveWallet(HDExternalChain, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := string(w.GetAddress()); addr != "12bX4AZeAN5KUQt2ystZM3AboqPeNndEth" {
+		t.Fatalf("address %s", addr)
+	}
+}
+
+func TestHDLocked(t *testing.T) {
+	hd := testHDWallet(t)
+	hd.Mnemonic = ""
+	if _, err := hd.DeriveWallet(HDExternalChain, 0); err != ErrLocked {
+		t.Fatalf("locked wallet derived a key: %v", err)
+	}
+	if _, err := NewHDWalletFromMnemonic("abandon abandon"); err == nil {
+		t.Fatal("invalid mnemonic accepted")
+	}
+}
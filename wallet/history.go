@@ -0,0 +1,496 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+	b "tway/blockchain"
+	"tway/mempool"
+	"tway/script"
+	"tway/twayutil"
+	"tway/util"
+)
+
+//Sens d'une transaction pour le wallet
+const (
+	TxReceived = "received"
+	TxSent     = "sent"
+	TxSelf     = "self" //tous les outputs appartiennent au wallet
+)
+
+//Transaction concernant au moins une adresse ou un compte multisig du wallet
+type WalletTx struct {
+	TxID      []byte
+	Direction string
+	//montant reçu, montant envoyé aux destinataires (rendu et frais exclus)
+	//ou montant transféré entre les adresses du wallet
+	Amount int
+	Fee    int //frais payés par le wallet, 0 pour une transaction reçue
+	//adresses des expéditeurs pour une transaction reçue,
+	//des destinataires pour une transaction envoyée
+	Counterparties []string
+	Addresses      []string //adresses du wallet débitées ou créditées
+	Height         int      //hauteur du block contenant la transaction, -1 si non confirmée
+	Coinbase       bool
+	WatchOnly      bool  //toutes les adresses du wallet concernées sont watch-only
+	Time           int64 //time unix de la première réception de la transaction
+	Memo           string
+	//transaction complète, conservée tant qu'elle n'est pas confirmée
+	//afin d'exclure ses inputs de la sélection des UTXOs
+	Tx *twayutil.Transaction
+	//hash (hex) de la transaction l'ayant remplacée dans la mempool
+	ReplacedBy string
+}
+
+var (
+	//Transactions du wallet indexées par leur hash (hex)
+	History   map[string]*WalletTx
+	historyMu sync.Mutex
+)
+
+func (w *walletState) historyFile() string {
+	return w.file + ".history"
+}
+
+//Retourne le nombre de confirmations de la transaction
+func (wtx *WalletTx) Confirmations() int {
+	if wtx.Height < 0 {
+		return 0
+	}
+	return b.BC.Height - wtx.Height + 1
+}
+
+//Retourne true si l'adresse est une des contreparties ou des adresses locales de la transaction
+func (wtx *WalletTx) HasAddress(addr string) bool {
+	for _, a := range append(wtx.Addresses, wtx.Counterparties...) {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+//Enregistre les listeners mettant à jour l'historique
+//à chaque changement de la chain ou de la mempool
+func registerHistoryListeners() {
+	b.OnBlockConnected(func(block *twayutil.Block, height int) {
+		forEachLoadedWallet(func(w *walletState) {
+			w.blockConnected(block, height)
+			w.paymentRequestsBlockConnected(block, height)
+		})
+	})
+	b.OnBlockDisconnected(func(block *twayutil.Block, height int) {
+		forEachLoadedWallet(func(w *walletState) {
+			w.blockDisconnected(block, height)
+			w.paymentRequestsBlockDisconnected(block)
+		})
+	})
+	mempool.Mempool.OnTxAccepted(func(tx *twayutil.Transaction) {
+		forEachLoadedWallet(func(w *walletState) {
+			w.txAccepted(tx)
+			//les demandes de paiement sont vues dès la réception de la transaction,
+			//elles sont payées une fois la transaction confirmée
+			w.paymentRequestsTxAccepted(tx)
+		})
+	})
+	mempool.Mempool.OnTxReplaced(func(replaced, by *twayutil.Transaction) {
+		forEachLoadedWallet(func(w *walletState) {
+			w.markReplaced(replaced, by)
+			w.paymentRequestsTxReplaced(replaced)
+		})
+	})
+}
+
+//Charge l'historique depuis le fichier .history du wallet
+func (w *walletState) loadHistory() {
+	history := make(map[string]*WalletTx)
+	data, err := ioutil.ReadFile(w.historyFile())
+	if os.IsNotExist(err) {
+		w.setHistory(history)
+		return
+	} else if err != nil {
+		log.Panic(err)
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&history); err != nil {
+		log.Panic(err)
+	}
+	//les adresses enregistrées avant la migration de l'encodage base58
+	//sont réécrites lors de la prochaine sauvegarde, voir rekeyAddresses
+	for _, wtx := range history {
+		wtx.rekeyAddresses()
+	}
+	w.setHistory(history)
+}
+
+//Met à jour les adresses de la transaction au format actuel
+func (wtx *WalletTx) rekeyAddresses() {
+	for i, addr := range wtx.Addresses {
+		wtx.Addresses[i] = migrateAddress(addr)
+	}
+	for i, addr := range wtx.Counterparties {
+		wtx.Counterparties[i] = migrateAddress(addr)
+	}
+}
+
+//Met à jour l'historique. Le fichier .history est partagé entre le serveur
+//et les commandes du noeud : il est relu sous verrou avant la modification
+//afin de ne pas écraser les changements d'un autre processus.
+//update retourne true si l'historique a été modifié et doit être sauvegardé
+func (w *walletState) updateHistory(update func() bool) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	unlock := lockFile(w.historyFile())
+	defer unlock()
+	w.loadHistory()
+	if update() {
+		w.saveHistory()
+	}
+}
+
+//Sauvegarde l'historique, historyMu doit être verrouillé par l'appelant
+func (w *walletState) saveHistory() {
+	var content bytes.Buffer
+	if err := gob.NewEncoder(&content).Encode(w.history); err != nil {
+		log.Panic(err)
+	}
+	if err := ioutil.WriteFile(w.historyFile(), content.Bytes(), 0600); err != nil {
+		log.Panic(err)
+	}
+}
+
+//Retourne l'adresse correspondant à un scriptPubKey, vide si le script n'est pas standard
+func scriptAddress(scriptPubKey [][]byte) string {
+	switch script.Script.GetScriptClass(scriptPubKey) {
+	case script.PubKeyHashTy:
+		return string(GetAddressFromPubKeyHash(scriptPubKey[2]))
+	case script.PubKeyTy:
+		return string(GetAddressFromPubKeyHash(HashPubKey(scriptPubKey[0])))
+	case script.MultiSigTy:
+		scriptHash, err := script.Script.Hash(scriptPubKey)
+		if err == nil {
+			return string(GetAddressFromScriptHash(scriptHash))
+		}
+	case script.HTLCTy:
+		info, err := script.Script.GetHTLCInfo(scriptPubKey)
+		if err == nil {
+			return string(GetAddressFromPubKeyHash(info.RecipientPubKeyH))
+		}
+	}
+	return ""
+}
+
+//Retourne true si l'output locké avec le script appartient au wallet
+//ou à une de ses entrées watch-only.
+//Un output HTLC appartient au wallet si celui-ci en est le destinataire
+func (w *walletState) isLocalScript(scriptPubKey [][]byte) bool {
+	addr := scriptAddress(scriptPubKey)
+	if addr == "" {
+		return false
+	}
+	switch script.Script.GetScriptClass(scriptPubKey) {
+	case script.PubKeyHashTy, script.PubKeyTy, script.HTLCTy:
+		return w.wallets[NormalizeAddress(addr)] != nil || w.isWatchOnly(addr)
+	case script.MultiSigTy:
+		return w.multiSig[NormalizeAddress(addr)] != nil || w.isWatchOnly(addr)
+	}
+	return false
+}
+
+func (w *walletState) isWatchOnly(addr string) bool {
+	return w.watchOnly[NormalizeAddress(addr)] != nil
+}
+
+func appendAddress(list []string, addr string) []string {
+	if addr == "" {
+		return list
+	}
+	for _, a := range list {
+		if a == addr {
+			return list
+		}
+	}
+	return append(list, addr)
+}
+
+//Récupère les transactions précédentes des inputs dans la blockchain, la mempool
+//ou les transactions non confirmées de l'historique
+//historyMu doit être verrouillé par l'appelant
+func (w *walletState) findPrevTxs(tx *twayutil.Transaction) map[string]*twayutil.Transaction {
+	prevTxs := make(map[string]*twayutil.Transaction)
+	if tx.IsCoinbase() {
+		return prevTxs
+	}
+	for _, in := range tx.Inputs {
+		hash := hex.EncodeToString(in.PrevTransactionHash)
+		if prevTx, _, height := b.GetTxByHash(in.PrevTransactionHash); height > -1 {
+			prevTxs[hash] = prevTx
+		} else if prevTx := mempool.Mempool.GetTx(hash); prevTx != nil {
+			prevTxs[hash] = prevTx
+		} else if wtx := w.history[hash]; wtx != nil && wtx.Tx != nil {
+			prevTxs[hash] = wtx.Tx
+		}
+	}
+	return prevTxs
+}
+
+//Créer l'entrée d'historique d'une transaction
+//Retourne nil si la transaction ne concerne pas le wallet
+func (w *walletState) newWalletTx(tx *twayutil.Transaction, prevTxs map[string]*twayutil.Transaction) *WalletTx {
+	wtx := &WalletTx{TxID: tx.GetHash(), Height: -1, Coinbase: tx.IsCoinbase()}
+	var debit, credit, totalIn, totalOut int
+	var senders, recipients []string
+	//les frais ne sont connus que si toutes les transactions précédentes ont été trouvées
+	feeKnown := true
+
+	if wtx.Coinbase == false {
+		for _, in := range tx.Inputs {
+			prevTx := prevTxs[hex.EncodeToString(in.PrevTransactionHash)]
+			vout := util.DecodeInt(in.Vout)
+			if prevTx == nil || vout < 0 || vout >= len(prevTx.Outputs) {
+				feeKnown = false
+				continue
+			}
+			out := prevTx.Outputs[vout]
+			value := util.DecodeInt(out.Value)
+			totalIn += value
+			if w.isLocalScript(out.ScriptPubKey) {
+				debit += value
+				wtx.Addresses = appendAddress(wtx.Addresses, scriptAddress(out.ScriptPubKey))
+			} else {
+				senders = appendAddress(senders, scriptAddress(out.ScriptPubKey))
+			}
+		}
+	}
+	var sentToOthers int
+	for _, out := range tx.Outputs {
+		value := util.DecodeInt(out.Value)
+		totalOut += value
+		if w.isLocalScript(out.ScriptPubKey) {
+			credit += value
+			wtx.Addresses = appendAddress(wtx.Addresses, scriptAddress(out.ScriptPubKey))
+		} else {
+			sentToOthers += value
+			recipients = appendAddress(recipients, scriptAddress(out.ScriptPubKey))
+		}
+	}
+
+	switch {
+	case debit == 0 && credit == 0:
+		return nil
+	case debit == 0:
+		wtx.Direction = TxReceived
+		wtx.Amount = credit
+		wtx.Counterparties = senders
+	case sentToOthers == 0:
+		wtx.Direction = TxSelf
+		wtx.Amount = credit
+	default:
+		wtx.Direction = TxSent
+		wtx.Amount = sentToOthers
+		wtx.Counterparties = recipients
+	}
+	if debit > 0 && feeKnown {
+		wtx.Fee = totalIn - totalOut
+	}
+	wtx.WatchOnly = true
+	for _, addr := range wtx.Addresses {
+		if w.isWatchOnly(addr) == false {
+			wtx.WatchOnly = false
+		}
+	}
+	return wtx
+}
+
+//Ajoute ou met à jour une transaction dans l'historique
+//La date de réception et le memo d'une transaction déjà connue sont conservés
+//historyMu doit être verrouillé par l'appelant
+func (w *walletState) recordTx(tx *twayutil.Transaction, height int, t int64) bool {
+	wtx := w.newWalletTx(tx, w.findPrevTxs(tx))
+	if wtx == nil {
+		return false
+	}
+	wtx.Height = height
+	wtx.Time = t
+	if height == -1 {
+		wtx.Tx = tx
+	}
+	old, exist := w.history[hex.EncodeToString(wtx.TxID)]
+	if exist {
+		wtx.Time = old.Time
+		wtx.Memo = old.Memo
+	}
+	w.history[hex.EncodeToString(wtx.TxID)] = wtx
+	if exist == false {
+		w.notifyTxEvent(EventTxSeen, wtx)
+	}
+	if height > -1 && (exist == false || old.Height == -1) {
+		w.notifyTxEvent(EventTxConfirmed, wtx)
+	}
+	return true
+}
+
+func (w *walletState) blockConnected(block *twayutil.Block, height int) {
+	w.updateHistory(func() bool {
+		updated := false
+		for i := range block.Transactions {
+			if w.recordTx(&block.Transactions[i], height, int64(util.DecodeInt(block.Header.Time))) {
+				updated = true
+			}
+		}
+		w.notifyConfirmations(height)
+		//notifié après les transactions du block
+		w.blockEvent(block, height)
+		return updated
+	})
+}
+
+//Les transactions du block retiré redeviennent non confirmées,
+//les transactions coinbase sont supprimées de l'historique
+func (w *walletState) blockDisconnected(block *twayutil.Block, height int) {
+	w.updateHistory(func() bool {
+		updated := false
+		for i := range block.Transactions {
+			txID := hex.EncodeToString(block.Transactions[i].GetHash())
+			wtx, exist := w.history[txID]
+			if exist == false || wtx.Height != height {
+				continue
+			}
+			if wtx.Coinbase {
+				delete(w.history, txID)
+			} else {
+				wtx.Height = -1
+				wtx.Tx = &block.Transactions[i]
+			}
+			w.notifyTxEvent(EventTxReorged, wtx)
+			updated = true
+		}
+		return updated
+	})
+}
+
+//Marque une transaction non confirmée comme remplacée, ses outputs
+//et les outputs qu'elle dépense ne sont plus pris en compte
+func MarkReplaced(replaced, by *twayutil.Transaction) {
+	selectedWallet().markReplaced(replaced, by)
+}
+
+func (w *walletState) markReplaced(replaced, by *twayutil.Transaction) {
+	w.updateHistory(func() bool {
+		wtx, exist := w.history[hex.EncodeToString(replaced.GetHash())]
+		if exist == false || wtx.Height != -1 {
+			return false
+		}
+		wtx.ReplacedBy = hex.EncodeToString(by.GetHash())
+		return true
+	})
+}
+
+func (w *walletState) txAccepted(tx *twayutil.Transaction) {
+	w.updateHistory(func() bool {
+		if _, exist := w.history[hex.EncodeToString(tx.GetHash())]; exist {
+			return false
+		}
+		return w.recordTx(tx, -1, time.Now().Unix())
+	})
+}
+
+//Reconstruit l'historique à partir du block à la hauteur fromHeight
+//(toute la blockchain si fromHeight <= 1).
+//Les transactions des blocks précédents, les transactions non confirmées
+//et les memos sont conservés. Retourne le nombre de transactions de l'historique
+func RebuildHistory(fromHeight int) int {
+	var count int
+	w := selectedWallet()
+	w.updateHistory(func() bool {
+		withoutEvents(func() {
+			count = w.rebuildHistory(fromHeight)
+		})
+		return true
+	})
+	return count
+}
+
+//historyMu doit être verrouillé par l'appelant
+func (w *walletState) rebuildHistory(fromHeight int) int {
+	//index des transactions de la chain pour retrouver les outputs dépensés
+	txs := make(map[string]*twayutil.Transaction)
+	var blocks []*twayutil.Block
+	be := b.NewExplorer()
+	for block := be.Next(); block != nil; block = be.Next() {
+		for i := range block.Transactions {
+			txs[hex.EncodeToString(block.Transactions[i].GetHash())] = &block.Transactions[i]
+		}
+		blocks = append(blocks, block)
+	}
+
+	old := w.history
+	history := make(map[string]*WalletTx)
+	//les blocks sont parcourus depuis le tip
+	for i, block := range blocks {
+		height := len(blocks) - i
+		if height < fromHeight {
+			break
+		}
+		for j := range block.Transactions {
+			tx := &block.Transactions[j]
+			wtx := w.newWalletTx(tx, txs)
+			if wtx == nil {
+				continue
+			}
+			wtx.Height = height
+			wtx.Time = int64(util.DecodeInt(block.Header.Time))
+			history[hex.EncodeToString(wtx.TxID)] = wtx
+		}
+	}
+	for txID, wtx := range old {
+		if current, exist := history[txID]; exist {
+			current.Time = wtx.Time
+			current.Memo = wtx.Memo
+		} else if (wtx.Height == -1 && wtx.Coinbase == false) || (wtx.Height > 0 && wtx.Height < fromHeight) {
+			history[txID] = wtx
+		}
+	}
+	w.setHistory(history)
+	return len(history)
+}
+
+//Ajoute un memo à une transaction de l'historique
+func SetTxMemo(txID, memo string) bool {
+	found := false
+	w := selectedWallet()
+	w.updateHistory(func() bool {
+		wtx, exist := w.history[txID]
+		if exist == false {
+			return false
+		}
+		wtx.Memo = memo
+		found = true
+		return true
+	})
+	return found
+}
+
+//Retourne les transactions de l'historique, les plus récentes en premier
+//Les transactions non confirmées sont placées en tête
+func GetHistory() []*WalletTx {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	list := make([]*WalletTx, 0, len(History))
+	for _, wtx := range History {
+		list = append(list, wtx)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		hi, hj := list[i].Height, list[j].Height
+		if hi != hj {
+			return hi == -1 || (hj != -1 && hi > hj)
+		}
+		return list[i].Time > list[j].Time
+	})
+	return list
+}
@@ -0,0 +1,48 @@
+package wallet
+
+import (
+	b "tway/blockchain"
+	"tway/script"
+	"tway/util"
+)
+
+//Structure représentant un output HTLC non dépensé
+//dont au moins une des branches est dépensable par un wallet local
+type LocalHTLCOutput struct {
+	TxID    []byte
+	Idx     int
+	Amount  int
+	Info    *script.HTLCInfo
+	ClaimW  *Wallet //wallet local destinataire, nil si non local
+	RefundW *Wallet //wallet local pouvant être remboursé, nil si non local
+}
+
+//Retourne true si l'HTLC peut être remboursé dans un block à la hauteur passée en paramètre
+func (o *LocalHTLCOutput) IsExpired(height int) bool {
+	return o.Info.LockTime <= height
+}
+
+//Récupère la liste des outputs HTLC non dépensés liés aux wallets locaux
+func GetLocalHTLCOutputs() []LocalHTLCOutput {
+	var pubKHList [][]byte
+	for _, w := range WalletList {
+		pubKHList = append(pubKHList, HashPubKey(w.PublicKey))
+	}
+
+	var list []LocalHTLCOutput
+	for _, us := range b.UTXO.GetHTLCOutputsByPubKH(pubKHList) {
+		info, err := script.Script.GetHTLCInfo(us.Output.ScriptPubKey)
+		if err != nil {
+			continue
+		}
+		list = append(list, LocalHTLCOutput{
+			TxID:    us.TxID,
+			Idx:     us.Idx,
+			Amount:  util.DecodeInt(us.Output.Value),
+			Info:    info,
+			ClaimW:  GetWalletByPubKeyHash(info.RecipientPubKeyH),
+			RefundW: GetWalletByPubKeyHash(info.RefundPubKeyH),
+		})
+	}
+	return list
+}
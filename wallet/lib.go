@@ -2,34 +2,19 @@ package wallet
 
 import (
 	"tway/util"
-	"bytes"
 	"errors"
-	"crypto/rand"
-	"crypto/ecdsa"
 	mathr "math/rand"
 	"os"
 )
 
-//Vérifie qu'une adresse est correcte (processus utilisé par le BTC)
+//Vérifie qu'une adresse base58 ou bech32 est correcte et appartient au réseau actif
 func IsAddressValid(addr string) bool {
-	//base58 to pubkey hash
-	pubKeyHash := util.Base58Decode([]byte(addr))
-	//on recupere le checksum de la clé publique hashé
-	actualChecksum := pubKeyHash[len(pubKeyHash)-AddressChecksumLen:]
-	//on recupere la version
-	version := pubKeyHash[0]
-	//on recupere le contenu de la clé public hashé entre la version (Index = 1) et le checksum (Index = len - 4)
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-AddressChecksumLen]
-
-	//on créer un checksum correspondant au resultat de la reconstution de la clé public hashé
-	targetChecksum := checksum(append([]byte{version}, pubKeyHash...))
-
-	//si les deux checksum sont identiques l'adresse est valide
-	return bytes.Compare(actualChecksum, targetChecksum) == 0
+	_, err := DecodeAddress(addr)
+	return err == nil
 }
 
 func IsAddressStored(addr string) bool {
-	return WalletList[addr] != nil
+	return GetWallet(addr) != nil
 }
 
 //Signe une data vide à partir de la clé privée correspondant
@@ -39,14 +24,16 @@ func SignPrivateKey(addr string) ([]byte, error) {
 	if IsAddressStored(addr) == false {
 		return []byte{}, errors.New("public key doesn't match with a private key stored")
 	}
+	if err := CheckUnlocked(); err != nil {
+		return []byte{}, err
+	}
 	
-	w := *WalletList[addr]
+	w := *GetWallet(addr)
 	
-	r, s, err := ecdsa.Sign(rand.Reader, &w.PrivateKey, []byte{})
+	signature, err := util.Sign(&w.PrivateKey, util.Sha256([]byte{}))
 	if err != nil {
 		return []byte{}, err
 	}
-	signature := append(r.Bytes(), s.Bytes()...)
 	return signature, nil
 }
 
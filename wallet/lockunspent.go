@@ -0,0 +1,162 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	b "tway/blockchain"
+	"tway/script"
+	"tway/util"
+)
+
+var (
+	//Outpoints (txid:vout) réservés, ignorés par la sélection automatique des UTXOs
+	LockedUnspents map[string]bool
+	lockedMu       sync.Mutex
+)
+
+func lockedUnspentFile() string {
+	return WALLET_FILE + ".locked"
+}
+
+//Charge la liste des outpoints réservés depuis le fichier .locked du wallet
+func loadLockedUnspents() {
+	lockedMu.Lock()
+	defer lockedMu.Unlock()
+	LockedUnspents = make(map[string]bool)
+	data, err := ioutil.ReadFile(lockedUnspentFile())
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		log.Panic(err)
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&LockedUnspents); err != nil {
+		log.Panic(err)
+	}
+}
+
+//Sauvegarde la liste des outpoints réservés, lockedMu doit être verrouillé par l'appelant
+func saveLockedUnspents() {
+	var content bytes.Buffer
+	if err := gob.NewEncoder(&content).Encode(LockedUnspents); err != nil {
+		log.Panic(err)
+	}
+	if err := ioutil.WriteFile(lockedUnspentFile(), content.Bytes(), 0600); err != nil {
+		log.Panic(err)
+	}
+}
+
+//Parse un outpoint au format txid:vout
+func ParseOutpoint(s string) ([]byte, int, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 2 {
+		return nil, 0, fmt.Errorf("outpoint %s must be at format txid:vout", s)
+	}
+	txID, err := hex.DecodeString(parts[0])
+	if err != nil || len(txID) != 32 {
+		return nil, 0, fmt.Errorf("outpoint %s has a wrong txid", s)
+	}
+	vout, err := strconv.Atoi(parts[1])
+	if err != nil || vout < 0 {
+		return nil, 0, fmt.Errorf("outpoint %s has a wrong vout", s)
+	}
+	return txID, vout, nil
+}
+
+//Réserve un UTXO du wallet, il n'est plus sélectionné automatiquement
+//mais reste dépensable en le désignant explicitement
+func LockUnspent(txID []byte, vout int) error {
+	if b.UTXO.GetUnSpentOutputByVoutAndTxHash(vout, txID) == nil {
+		return fmt.Errorf("%s is not an unspent output", outpoint(txID, vout))
+	}
+	lockedMu.Lock()
+	defer lockedMu.Unlock()
+	if LockedUnspents[outpoint(txID, vout)] {
+		return fmt.Errorf("%s is already locked", outpoint(txID, vout))
+	}
+	LockedUnspents[outpoint(txID, vout)] = true
+	saveLockedUnspents()
+	return nil
+}
+
+//Libère un UTXO réservé avec LockUnspent
+func UnlockUnspent(txID []byte, vout int) error {
+	lockedMu.Lock()
+	defer lockedMu.Unlock()
+	if LockedUnspents[outpoint(txID, vout)] == false {
+		return fmt.Errorf("%s is not locked", outpoint(txID, vout))
+	}
+	delete(LockedUnspents, outpoint(txID, vout))
+	saveLockedUnspents()
+	return nil
+}
+
+//Retourne la liste triée des outpoints réservés
+//Les outpoints dépensés depuis leur réservation sont retirés de la liste
+func ListLockedUnspents() []string {
+	lockedMu.Lock()
+	defer lockedMu.Unlock()
+	var list []string
+	updated := false
+	for op := range LockedUnspents {
+		txID, vout, err := ParseOutpoint(op)
+		if err != nil || b.UTXO.GetUnSpentOutputByVoutAndTxHash(vout, txID) == nil {
+			delete(LockedUnspents, op)
+			updated = true
+			continue
+		}
+		list = append(list, op)
+	}
+	if updated {
+		saveLockedUnspents()
+	}
+	sort.Strings(list)
+	return list
+}
+
+func IsUnspentLocked(txID []byte, vout int) bool {
+	lockedMu.Lock()
+	defer lockedMu.Unlock()
+	return LockedUnspents[outpoint(txID, vout)]
+}
+
+//Retourne le wallet local pouvant dépenser un output PayToPubKeyHash
+//ou PayToPubKey avec sa signature, nil si aucun wallet ne le peut
+func spendingWallet(scriptPubKey [][]byte) *Wallet {
+	switch script.Script.GetScriptClass(scriptPubKey) {
+	case script.PubKeyHashTy:
+		return GetWalletByPubKeyHash(scriptPubKey[2])
+	case script.PubKeyTy:
+		return GetWalletByPubKeyHash(HashPubKey(scriptPubKey[0]))
+	}
+	return nil
+}
+
+//Récupère un UTXO d'un wallet local désigné par son outpoint
+//L'UTXO peut être réservé, il doit être dépensable avec une signature du wallet
+func GetLocalUnspentOutput(txID []byte, vout int) (*LocalUnspentOutput, error) {
+	us := b.UTXO.GetUnSpentOutputByVoutAndTxHash(vout, txID)
+	if us == nil {
+		return nil, fmt.Errorf("%s is not an unspent output", outpoint(txID, vout))
+	}
+	w := spendingWallet(us.Output.ScriptPubKey)
+	if w == nil {
+		return nil, fmt.Errorf("%s can't be spent by a local wallet", outpoint(txID, vout))
+	}
+	pending := getPendingState()
+	if pending.isAvailable(*us) == false {
+		return nil, fmt.Errorf("%s is already spent by an unconfirmed transaction", outpoint(txID, vout))
+	}
+	if pending.isImmature(*us) {
+		return nil, fmt.Errorf("mining reward %s is not mature", outpoint(txID, vout))
+	}
+	return &LocalUnspentOutput{us.TxID, us.Idx, util.DecodeInt(us.Output.Value), w, 0}, nil
+}
@@ -0,0 +1,83 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"tway/keys"
+	"tway/util"
+)
+
+//Préfixe ajouté aux messages signés, une signature de message
+//ne peut pas être réutilisée comme signature de transaction
+const MessageMagic = "Tway Signed Message:\n"
+
+var ErrInvalidMessageSignature = errors.New("invalid message signature")
+
+//Retourne le hash signé d'un message : double sha256 du préfixe
+//et du message, chacun précédé de sa taille
+func MessageHash(message string) []byte {
+	var payload bytes.Buffer
+	for _, part := range []string{MessageMagic, message} {
+		size := make([]byte, binary.MaxVarintLen64)
+		payload.Write(size[:binary.PutUvarint(size, uint64(len(part)))])
+		payload.WriteString(part)
+	}
+	return util.Sha256(util.Sha256(payload.Bytes()))
+}
+
+//Signe un message avec la clé privée d'une adresse locale
+//Retourne la clé publique du wallet suivie de la signature DER, encodées en base64
+func SignMessage(addr, message string) (string, error) {
+	if _, err := DecodePubKeyHashAddress(addr); err != nil {
+		return "", err
+	}
+	addr = NormalizeAddress(addr)
+	if IsAddressStored(addr) == false {
+		return "", errors.New("address is not stored in the wallet")
+	}
+	if err := CheckUnlocked(); err != nil {
+		return "", err
+	}
+	w := GetWallet(addr)
+	signature, err := util.Sign(&w.PrivateKey, MessageHash(message))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(append(w.PublicKey, signature...)), nil
+}
+
+//Vérifie qu'un message a été signé avec SignMessage par la clé privée de l'adresse
+//Retourne une erreur si la signature ou l'adresse sont mal formées
+func VerifyMessage(addr, signature, message string) (bool, error) {
+	pubKeyHash, err := DecodePubKeyHashAddress(addr)
+	if err != nil {
+		return false, err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, ErrInvalidMessageSignature
+	}
+	//la clé publique n'a pas une taille fixe : la signature DER commence
+	//par 0x30 suivi de la taille du reste de la signature
+	found := false
+	for i := keys.PubKeyBytesLenCompressed; i+2 <= len(decoded); i++ {
+		if decoded[i] != 0x30 || int(decoded[i+1]) != len(decoded)-i-2 {
+			continue
+		}
+		pub, err := keys.ParsePubKey(decoded[:i])
+		if err != nil {
+			continue
+		}
+		found = true
+		//la clé publique doit correspondre à l'adresse
+		if bytes.Compare(HashPubKey(decoded[:i]), pubKeyHash) == 0 {
+			return pub.Verify(MessageHash(message), decoded[i:]), nil
+		}
+	}
+	if found == false {
+		return false, ErrInvalidMessageSignature
+	}
+	return false, nil
+}
@@ -0,0 +1,120 @@
+package wallet
+
+import (
+	"encoding/base64"
+	"testing"
+	"tway/keys"
+)
+
+//Ajoute deux wallets dérivés de la phrase de test à la liste des wallets
+func testMessageWallets(t *testing.T) (string, string) {
+	hd := testHDWallet(t)
+	saved, savedCrypto := WalletList, Crypto
+	t.Cleanup(func() { WalletList, Crypto = saved, savedCrypto })
+	WalletList, Crypto = make(map[string]*Wallet), nil
+
+	var addrs []string
+	for i := 0; i < 2; i++ {
+		w, err := hd.NextWallet(HDExternalChain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := string(w.GetAddress())
+		WalletList[addr] = w
+		addrs = append(addrs, addr)
+	}
+	return addrs[0], addrs[1]
+}
+
+func TestSignMessageRoundTrip(t *testing.T) {
+	addr, _ := testMessageWallets(t)
+	for _, message := range []string{"", "hello", "Café Léa\nligne 2"} {
+		signature, err := SignMessage(addr, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := VerifyMessage(addr, signature, message); err != nil || ok == false {
+			t.Fatalf("%q: signature rejected, err %v", message, err)
+		}
+	}
+	if _, err := SignMessage(string(GetAddressFromPubKeyHash(make([]byte, 20))), "hello"); err == nil {
+		t.Fatal("message signed with an address not stored in the wallet")
+	}
+}
+
+func TestVerifyMessageTampered(t *testing.T) {
+	addr, _ := testMessageWallets(t)
+	signature, err := SignMessage(addr, "pay 10 to alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range []string{"pay 11 to alice", "pay 10 to alice ", MessageMagic + "pay 10 to alice"} {
+		if ok, err := VerifyMessage(addr, signature, message); err != nil || ok {
+			t.Fatalf("%q: tampered message accepted, err %v", message, err)
+		}
+	}
+}
+
+func TestVerifyMessageWrongAddress(t *testing.T) {
+	addr, other := testMessageWallets(t)
+	signature, err := SignMessage(addr, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := VerifyMessage(other, signature, "hello"); err != nil || ok {
+		t.Fatalf("signature accepted for another address, err %v", err)
+	}
+	if _, err := VerifyMessage(addr[:len(addr)-1]+"1", signature, "hello"); err == nil {
+		t.Fatal("malformed address accepted")
+	}
+}
+
+func TestVerifyMessageMalformedSignature(t *testing.T) {
+	addr, _ := testMessageWallets(t)
+	signature, err := SignMessage(addr, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badPrefix := append([]byte{0x05}, decoded[1:]...)
+	badDER := append(append([]byte{}, decoded[:33]...), 0x30, 0x01, 0x00)
+	tests := []struct {
+		name      string
+		signature string
+	}{
+		{"not base64", "%%%"},
+		{"empty", ""},
+		{"public key only", base64.StdEncoding.EncodeToString(decoded[:33])},
+		{"bad public key prefix", base64.StdEncoding.EncodeToString(badPrefix)},
+		{"truncated signature", base64.StdEncoding.EncodeToString(decoded[:len(decoded)-1])},
+		{"bad DER", base64.StdEncoding.EncodeToString(badDER)},
+	}
+	for _, test := range tests {
+		if ok, _ := VerifyMessage(addr, test.signature, "hello"); ok {
+			t.Fatalf("%s: signature accepted", test.name)
+		}
+	}
+}
+
+//Un wallet des premières versions signe avec sa clé publique X||Y
+func TestSignMessageLegacyKey(t *testing.T) {
+	addr, _ := testMessageWallets(t)
+	w := WalletList[addr]
+	legacy := &Wallet{PrivateKey: w.PrivateKey, PublicKey: keys.PubKeyFromPrivate(&w.PrivateKey).SerializeLegacy()}
+	legacyAddr := string(legacy.GetAddress())
+	WalletList[legacyAddr] = legacy
+	signature, err := SignMessage(legacyAddr, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := VerifyMessage(legacyAddr, signature, "hello"); err != nil || ok == false {
+		t.Fatalf("legacy signature rejected, err %v", err)
+	}
+	//même clé privée, adresse différente
+	if ok, err := VerifyMessage(addr, signature, "hello"); err != nil || ok {
+		t.Fatalf("legacy signature accepted for the compressed key address, err %v", err)
+	}
+}
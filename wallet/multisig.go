@@ -0,0 +1,102 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	b "tway/blockchain"
+	"tway/keys"
+	"tway/script"
+	"tway/util"
+)
+
+//Compte multisig m-of-n enregistré dans le wallet
+//Les clés publiques sont gardées dans l'ordre du script
+type MultiSigAccount struct {
+	Label   string
+	NSig    int
+	PubKeys [][]byte
+}
+
+//Créer un compte multisig à partir de nSig et d'une liste ordonnée de clés publiques
+func NewMultiSigAccount(label string, nSig int, pubKeys [][]byte) (*MultiSigAccount, error) {
+	if len(pubKeys) < 2 || len(pubKeys) > 16 {
+		return nil, errors.New("a multisig account needs between 2 and 16 public keys")
+	}
+	if nSig < 1 || nSig > len(pubKeys) {
+		return nil, fmt.Errorf("number of signatures must be between 1 and %d", len(pubKeys))
+	}
+	for _, pubKey := range pubKeys {
+		if keys.IsPubKey(pubKey) == false {
+			return nil, fmt.Errorf("%x is not a valid public key", pubKey)
+		}
+	}
+	return &MultiSigAccount{label, nSig, pubKeys}, nil
+}
+
+//Ajoute un compte multisig au wallet et met à jour le fichier .dat
+//Retourne l'adresse du compte
+func AddMultiSigAccount(account *MultiSigAccount) (string, error) {
+	addr := string(account.GetAddress())
+	if _, exist := MultiSigAccounts[addr]; exist {
+		return addr, errors.New("multisig account is already registered")
+	}
+	if len(account.LocalWallets()) == 0 {
+		return addr, errors.New("none of the public keys belongs to a local wallet")
+	}
+	MultiSigAccounts[addr] = account
+	if err := SaveToFile(); err != nil {
+		delete(MultiSigAccounts, addr)
+		return addr, err
+	}
+	return addr, nil
+}
+
+//Retourne le scriptPubKey des outputs du compte
+func (a *MultiSigAccount) ScriptPubKey() [][]byte {
+	return script.Script.LockingScript(a.PubKeys, a.NSig)
+}
+
+//Retourne l'adresse du compte (hash de son script)
+func (a *MultiSigAccount) GetAddress() []byte {
+	scriptHash, err := script.Script.Hash(a.ScriptPubKey())
+	if err != nil {
+		return nil
+	}
+	return GetAddressFromScriptHash(scriptHash)
+}
+
+//Retourne la liste des wallets locaux faisant partie du compte
+func (a *MultiSigAccount) LocalWallets() []*Wallet {
+	var wallets []*Wallet
+	for _, pubKey := range a.PubKeys {
+		if w := GetWalletByPubKeyHash(HashPubKey(pubKey)); w != nil {
+			wallets = append(wallets, w)
+		}
+	}
+	return wallets
+}
+
+//Retourne true si la clé publique est celle d'un wallet local
+func (a *MultiSigAccount) IsLocalKey(pubKey []byte) bool {
+	return GetWalletByPubKeyHash(HashPubKey(pubKey)) != nil
+}
+
+//Récupère la liste des outputs non dépensés du compte et leur montant total
+//Les outputs dépensés par une transaction non confirmée sont ignorés
+func (a *MultiSigAccount) GetUnspentOutputs() (int, []b.UnspentOutput) {
+	var total int
+	var available []b.UnspentOutput
+	pending := getPendingState()
+	for _, us := range b.UTXO.GetUnspentOutputsByScript(a.ScriptPubKey()) {
+		if pending.isAvailable(us) {
+			total += util.DecodeInt(us.Output.Value)
+			available = append(available, us)
+		}
+	}
+	return total, available
+}
+
+//Retourne le compte multisig lié à l'adresse, nil si non enregistré
+func GetMultiSigAccount(addr string) *MultiSigAccount {
+	return MultiSigAccounts[NormalizeAddress(addr)]
+}
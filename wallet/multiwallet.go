@@ -0,0 +1,324 @@
+package wallet
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Nom du wallet par défaut du noeud, stocké dans le fichier WALLET_FILE + NODE_ID
+const DefaultWalletName = ""
+
+var (
+	//nom du wallet utilisé par le processus, DefaultWalletName par défaut
+	WalletName string
+	//fichier du wallet par défaut, les wallets nommés sont stockés à côté
+	nodeWalletFile   string
+	walletNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+	//les évènements sont transmis à tous les wallets chargés, voir OpenLoadedWallets
+	dispatchLoaded bool
+	//wallets chargés autres que le wallet sélectionné, indexés par leur nom
+	loadedWallets = make(map[string]*walletState)
+	walletsMu     sync.Mutex
+)
+
+//Wallet nommé stocké dans le dossier des wallets du noeud
+type NamedWallet struct {
+	Name   string
+	Loaded bool
+}
+
+//Dossier contenant les wallets nommés du noeud
+func walletsDir() string {
+	return nodeWalletFile + ".wallets"
+}
+
+//Fichier listant les wallets nommés chargés, les noms ne contiennent pas de point
+func loadedWalletsFile() string {
+	return filepath.Join(walletsDir(), "loaded.list")
+}
+
+//Retourne le chemin du fichier .dat d'un wallet
+//Les fichiers .history, .locked et .requests (et leurs .lock) sont placés à côté
+func walletFilePath(name string) string {
+	if name == DefaultWalletName {
+		return nodeWalletFile
+	}
+	return filepath.Join(walletsDir(), name)
+}
+
+func checkWalletName(name string) error {
+	if walletNameRegexp.MatchString(name) == false {
+		return fmt.Errorf("wallet name %s must only contain letters, digits, - and _", name)
+	}
+	return nil
+}
+
+func walletExists(name string) bool {
+	_, err := os.Stat(walletFilePath(name))
+	return err == nil
+}
+
+//Ouvre le wallet stocké dans le fichier : les clés, l'historique
+//et les outpoints réservés remplacent ceux du wallet précédent
+func openWallet(file string) {
+	WALLET_FILE = file
+	WalletList = make(map[string]*Wallet)
+	MultiSigAccounts = make(map[string]*MultiSigAccount)
+	WatchOnly = make(map[string]*WatchOnlyEntry)
+	HD = nil
+	resetCrypto(nil)
+	LoadFromFile()
+	selectedWallet().loadHistory()
+	loadLockedUnspents()
+}
+
+//Wallet dont l'historique et les demandes de paiement sont mis à jour
+//par les évènements de la blockchain et de la mempool.
+//Le wallet sélectionné partage ses variables avec le package, chaque autre
+//wallet chargé par le serveur du noeud a ses propres clés et son propre historique
+type walletState struct {
+	name      string
+	file      string
+	wallets   map[string]*Wallet
+	multiSig  map[string]*MultiSigAccount
+	watchOnly map[string]*WatchOnlyEntry
+	//historique lu depuis le fichier .history, historyMu doit être verrouillé
+	history  map[string]*WalletTx
+	selected bool
+	//date de modification du fichier .dat lors de sa lecture
+	modTime time.Time
+}
+
+//Retourne l'état du wallet sélectionné par le processus
+func selectedWallet() *walletState {
+	return &walletState{
+		name:      WalletName,
+		file:      WALLET_FILE,
+		wallets:   WalletList,
+		multiSig:  MultiSigAccounts,
+		watchOnly: WatchOnly,
+		history:   History,
+		selected:  true,
+	}
+}
+
+//Lit les clés d'un wallet nommé depuis son fichier .dat
+//sans modifier les variables du wallet sélectionné
+func readWalletState(name string) (*walletState, error) {
+	file := walletFilePath(name)
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	content, err := readWalletFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return &walletState{
+		name:      name,
+		file:      file,
+		wallets:   content.Wallets,
+		multiSig:  content.MultiSig,
+		watchOnly: content.WatchOnly,
+		history:   make(map[string]*WalletTx),
+		modTime:   info.ModTime(),
+	}, nil
+}
+
+//Le wallet sélectionné partage son historique avec la variable History
+func (w *walletState) setHistory(history map[string]*WalletTx) {
+	w.history = history
+	if w.selected {
+		History = history
+	}
+}
+
+//Le serveur du noeud transmet ensuite les nouveaux blocks et les transactions
+//de la mempool à chaque wallet chargé, en plus du wallet qu'il a sélectionné :
+//leur historique, leurs demandes de paiement et leurs hooks restent à jour.
+func OpenLoadedWallets() {
+	walletsMu.Lock()
+	dispatchLoaded = true
+	walletsMu.Unlock()
+}
+
+//Exécute f pour le wallet sélectionné puis, si OpenLoadedWallets a été appelée,
+//pour le wallet par défaut du noeud et chaque wallet chargé, par ordre de nom
+func forEachLoadedWallet(f func(w *walletState)) {
+	walletsMu.Lock()
+	defer walletsMu.Unlock()
+	f(selectedWallet())
+	if dispatchLoaded == false {
+		return
+	}
+	for _, w := range refreshLoadedWallets() {
+		f(w)
+	}
+}
+
+//Met à jour les wallets chargés selon loaded.list.
+//Le fichier .dat d'un wallet n'est relu que s'il a été modifié depuis sa lecture,
+//par exemple par une commande du noeud ajoutant une clé.
+//walletsMu doit être verrouillé par l'appelant
+func refreshLoadedWallets() []*walletState {
+	names := readLoadedWallets()
+	names[DefaultWalletName] = true
+	delete(names, WalletName)
+	for name := range loadedWallets {
+		if names[name] == false {
+			delete(loadedWallets, name)
+		}
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var list []*walletState
+	for _, name := range sorted {
+		info, err := os.Stat(walletFilePath(name))
+		if err != nil {
+			delete(loadedWallets, name)
+			continue
+		}
+		w, exist := loadedWallets[name]
+		if exist == false || info.ModTime().Equal(w.modTime) == false {
+			if w, err = readWalletState(name); err != nil {
+				log.Println("wallet", name, ":", err)
+				delete(loadedWallets, name)
+				continue
+			}
+			loadedWallets[name] = w
+		}
+		list = append(list, w)
+	}
+	return list
+}
+
+func readLoadedWallets() map[string]bool {
+	loaded := make(map[string]bool)
+	file, err := os.Open(loadedWalletsFile())
+	if err != nil {
+		return loaded
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			loaded[name] = true
+		}
+	}
+	return loaded
+}
+
+func writeLoadedWallets(loaded map[string]bool) error {
+	var names []string
+	for name := range loaded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return ioutil.WriteFile(loadedWalletsFile(), []byte(strings.Join(names, "\n")), 0600)
+}
+
+//Créer un wallet nommé vide et le charge
+func CreateWallet(name string) error {
+	if err := checkWalletName(name); err != nil {
+		return err
+	}
+	if walletExists(name) {
+		return fmt.Errorf("wallet %s already exists", name)
+	}
+	if err := os.MkdirAll(walletsDir(), 0700); err != nil {
+		return err
+	}
+	if err := writeWalletFile(walletFilePath(name), walletFile{}); err != nil {
+		return err
+	}
+	return LoadWallet(name)
+}
+
+//Charge un wallet nommé, il peut ensuite être sélectionné avec --wallet.
+//Le serveur du noeud lui transmet les évènements de la blockchain et de la mempool
+func LoadWallet(name string) error {
+	if err := checkWalletName(name); err != nil {
+		return err
+	}
+	if walletExists(name) == false {
+		return fmt.Errorf("wallet %s doesn't exist", name)
+	}
+	loaded := readLoadedWallets()
+	if loaded[name] {
+		return fmt.Errorf("wallet %s is already loaded", name)
+	}
+	loaded[name] = true
+	return writeLoadedWallets(loaded)
+}
+
+//Décharge un wallet nommé, ses fichiers sont conservés
+//Le serveur du noeud ne lui transmet plus d'évènements
+func UnloadWallet(name string) error {
+	loaded := readLoadedWallets()
+	if loaded[name] == false {
+		return fmt.Errorf("wallet %s is not loaded", name)
+	}
+	if name == WalletName {
+		if err := SelectWallet(DefaultWalletName); err != nil {
+			return err
+		}
+	}
+	delete(loaded, name)
+	return writeLoadedWallets(loaded)
+}
+
+//Retourne la liste triée des wallets nommés du noeud
+func ListWallets() ([]NamedWallet, error) {
+	files, err := ioutil.ReadDir(walletsDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	loaded := readLoadedWallets()
+	var list []NamedWallet
+	for _, f := range files {
+		if f.IsDir() || checkWalletName(f.Name()) != nil {
+			continue
+		}
+		list = append(list, NamedWallet{f.Name(), loaded[f.Name()]})
+	}
+	return list, nil
+}
+
+//Sélectionne le wallet utilisé par les commandes du processus
+//Un wallet nommé doit être chargé, DefaultWalletName sélectionne le wallet du noeud
+func SelectWallet(name string) error {
+	if name == WalletName {
+		return nil
+	}
+	if name != DefaultWalletName {
+		if err := checkWalletName(name); err != nil {
+			return err
+		}
+		if walletExists(name) == false {
+			return fmt.Errorf("wallet %s doesn't exist, create it with wallet --create-wallet", name)
+		}
+		if readLoadedWallets()[name] == false {
+			return errors.New("wallet " + name + " is not loaded, load it with wallet --load-wallet")
+		}
+	}
+	openWallet(walletFilePath(name))
+	WalletName = name
+	return nil
+}
@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"tway/twayutil"
+	"tway/util"
+)
+
+//Créer le wallet par défaut d'un noeud dans un dossier temporaire,
+//le wallet sélectionné et les wallets chargés sont restaurés à la fin du test
+func testNodeWallets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tway-wallets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedNode, savedFile, savedName := nodeWalletFile, WALLET_FILE, WalletName
+	savedWallets, savedMultiSig, savedWatchOnly, savedHistory := WalletList, MultiSigAccounts, WatchOnly, History
+	savedDispatch, savedLoaded, savedListeners := dispatchLoaded, loadedWallets, eventListeners
+	savedHD, savedCrypto := HD, Crypto
+	t.Cleanup(func() {
+		nodeWalletFile, WALLET_FILE, WalletName = savedNode, savedFile, savedName
+		WalletList, MultiSigAccounts, WatchOnly, History = savedWallets, savedMultiSig, savedWatchOnly, savedHistory
+		HD = savedHD
+		resetCrypto(savedCrypto)
+		dispatchLoaded, loadedWallets, eventListeners = savedDispatch, savedLoaded, savedListeners
+		os.RemoveAll(dir)
+	})
+
+	nodeWalletFile = filepath.Join(dir, "wallet.dat")
+	if err := writeWalletFile(nodeWalletFile, walletFile{}); err != nil {
+		t.Fatal(err)
+	}
+	WalletName = DefaultWalletName
+	openWallet(nodeWalletFile)
+	dispatchLoaded, loadedWallets, eventListeners = true, make(map[string]*walletState), nil
+}
+
+//Écrit un wallet nommé surveillant les adresses des clés publiques
+func writeWatchOnlyWallet(t *testing.T, name string, pubKeys ...[]byte) {
+	watched := make(map[string]*WatchOnlyEntry)
+	for _, pubKey := range pubKeys {
+		entry := &WatchOnlyEntry{PubKeyHash: HashPubKey(pubKey), PubKey: pubKey}
+		watched[entry.GetAddress()] = entry
+	}
+	if err := writeWalletFile(walletFilePath(name), walletFile{WatchOnly: watched}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//Les évènements sont traités par chaque wallet chargé
+//sans modifier les variables du wallet sélectionné
+func TestForEachLoadedWallet(t *testing.T) {
+	testNodeWallets(t)
+	if err := CreateWallet("savings"); err != nil {
+		t.Fatal(err)
+	}
+	pubKey := []byte("savings public key")
+	writeWatchOnlyWallet(t, "savings", pubKey)
+
+	var events []WalletEvent
+	OnWalletEvent(func(e WalletEvent) {
+		events = append(events, e)
+	})
+	tx := twayutil.NewCoinbaseTx(pubKey, 0)
+	forEachLoadedWallet(func(w *walletState) {
+		w.txAccepted(&tx)
+	})
+
+	if WALLET_FILE != nodeWalletFile || len(WatchOnly) != 0 || len(History) != 0 {
+		t.Fatalf("selected wallet modified: %s, %d watch-only entries, %d transactions", WALLET_FILE, len(WatchOnly), len(History))
+	}
+	if len(events) != 1 || events[0].Wallet != "savings" || events[0].Event != EventTxSeen {
+		t.Fatalf("events %+v", events)
+	}
+	savings, err := readWalletState("savings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	savings.loadHistory()
+	wtx := savings.history[events[0].TxID]
+	if wtx == nil || wtx.Direction != TxReceived || wtx.Amount != util.DecodeInt(tx.Outputs[0].Value) || wtx.WatchOnly == false {
+		t.Fatalf("savings history %+v", savings.history)
+	}
+}
+
+//Le fichier .dat d'un wallet chargé n'est relu que s'il a été modifié
+func TestRefreshLoadedWallets(t *testing.T) {
+	testNodeWallets(t)
+	if err := CreateWallet("savings"); err != nil {
+		t.Fatal(err)
+	}
+	//le wallet sélectionné ne reçoit les évènements qu'une fois
+	list := refreshLoadedWallets()
+	if len(list) != 1 || list[0].name != "savings" {
+		t.Fatalf("%d loaded wallets", len(list))
+	}
+	savings := list[0]
+	if again := refreshLoadedWallets(); again[0] != savings {
+		t.Fatal("unmodified wallet read again")
+	}
+
+	writeWatchOnlyWallet(t, "savings", []byte("first key"), []byte("second key"))
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(walletFilePath("savings"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	list = refreshLoadedWallets()
+	if list[0] == savings || len(list[0].watchOnly) != 2 {
+		t.Fatalf("modified wallet not read again, %d watch-only entries", len(list[0].watchOnly))
+	}
+
+	if err := SelectWallet("savings"); err != nil {
+		t.Fatal(err)
+	}
+	if list = refreshLoadedWallets(); len(list) != 1 || list[0].name != DefaultWalletName {
+		t.Fatalf("%d loaded wallets", len(list))
+	}
+	if err := UnloadWallet("savings"); err != nil {
+		t.Fatal(err)
+	}
+	if list = refreshLoadedWallets(); len(list) != 0 || len(loadedWallets) != 0 {
+		t.Fatalf("%d loaded wallets", len(list))
+	}
+}
@@ -0,0 +1,213 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"tway/twayutil"
+)
+
+//Évènements du wallet transmis aux hooks
+const (
+	EventTxSeen      = "seen"      //transaction du wallet reçue pour la première fois
+	EventTxConfirmed = "confirmed" //transaction du wallet incluse dans un block
+	EventTxReorged   = "reorged"   //block de la transaction retiré de la chain
+	EventBlock       = "block"     //nouveau block ajouté à la chain
+	//nouvelle confirmation d'une transaction du wallet, jusqu'à NotifyConfirmations
+	EventTxConfirmations = "confirmations"
+)
+
+//Évènement du wallet, encodé en JSON pour les hooks HTTP
+type WalletEvent struct {
+	Event         string `json:"event"`
+	Wallet        string `json:"wallet"`
+	TxID          string `json:"txid,omitempty"`
+	Direction     string `json:"direction,omitempty"`
+	Amount        int    `json:"amount"`
+	Confirmations int    `json:"confirmations"`
+	Height        int    `json:"height"`
+	BlockHash     string `json:"blockhash,omitempty"`
+}
+
+//Nombre de confirmations jusqu'auquel un évènement est notifié à chaque nouveau block
+var NotifyConfirmations = 6
+
+var (
+	eventListeners []func(e WalletEvent)
+	//les évènements ne sont pas notifiés pendant un rescan de la blockchain,
+	//les transactions retrouvées ne sont pas nouvelles
+	eventsSuppressed bool
+)
+
+//Enregistre une fonction appelée à chaque évènement du wallet
+//La fonction est appelée pendant la mise à jour de l'historique et ne doit pas bloquer
+func OnWalletEvent(listener func(e WalletEvent)) {
+	eventListeners = append(eventListeners, listener)
+}
+
+func notifyWalletEvent(e WalletEvent) {
+	if eventsSuppressed {
+		return
+	}
+	for _, listener := range eventListeners {
+		listener(e)
+	}
+}
+
+//Exécute f sans notifier les évènements, historyMu doit être verrouillé par l'appelant
+func withoutEvents(f func()) {
+	eventsSuppressed = true
+	defer func() {
+		eventsSuppressed = false
+	}()
+	f()
+}
+
+//Notifie un évènement concernant une transaction de l'historique
+func (w *walletState) notifyTxEvent(event string, wtx *WalletTx) {
+	confirmations := wtx.Confirmations()
+	//le tip de la chain peut ne pas encore être mis à jour
+	if wtx.Height > -1 && confirmations < 1 {
+		confirmations = 1
+	}
+	notifyWalletEvent(txEvent(event, w.name, wtx, confirmations))
+}
+
+func txEvent(event, walletName string, wtx *WalletTx, confirmations int) WalletEvent {
+	return WalletEvent{
+		Event:         event,
+		Wallet:        walletName,
+		TxID:          hex.EncodeToString(wtx.TxID),
+		Direction:     wtx.Direction,
+		Amount:        wtx.Amount,
+		Confirmations: confirmations,
+		Height:        wtx.Height,
+	}
+}
+
+//Notifie la nouvelle confirmation apportée par le block à la hauteur height
+//aux transactions des blocks précédents, historyMu doit être verrouillé par l'appelant
+func (w *walletState) notifyConfirmations(height int) {
+	var list []*WalletTx
+	for _, wtx := range w.history {
+		confirmations := height - wtx.Height + 1
+		if wtx.Height > -1 && confirmations > 1 && confirmations <= NotifyConfirmations {
+			list = append(list, wtx)
+		}
+	}
+	//les transactions les plus anciennes en premier
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Height < list[j].Height
+	})
+	for _, wtx := range list {
+		notifyWalletEvent(txEvent(EventTxConfirmations, w.name, wtx, height-wtx.Height+1))
+	}
+}
+
+func (w *walletState) blockEvent(block *twayutil.Block, height int) {
+	notifyWalletEvent(WalletEvent{Event: EventBlock, Wallet: w.name, Height: height, BlockHash: hex.EncodeToString(block.GetHash())})
+}
+
+//Hook exécutant une commande ou envoyant une requête POST à chaque évènement
+type Hook struct {
+	//commande exécutée par le shell, %e, %t, %a, %c, %h, %b et %w sont remplacés par
+	//l'évènement, le hash de la transaction, le montant, le nombre de confirmations,
+	//la hauteur, le hash du block et le nom du wallet
+	Command string
+	//URL recevant l'évènement encodé en JSON
+	URL string
+	//nombre de nouvelles tentatives après un échec, le délai double à chaque tentative
+	Retries    int
+	RetryDelay time.Duration
+	queue      chan WalletEvent
+}
+
+//Enregistre le hook, les évènements sont transmis dans l'ordre par une goroutine
+func RegisterHook(h *Hook) {
+	h.queue = make(chan WalletEvent, 1024)
+	go func() {
+		for e := range h.queue {
+			h.deliver(e)
+		}
+	}()
+	OnWalletEvent(func(e WalletEvent) {
+		//l'évènement est notifié pendant la mise à jour de l'historique :
+		//il est abandonné si la file est pleine plutôt que de bloquer le noeud
+		select {
+		case h.queue <- e:
+		default:
+			log.Println("wallet hook queue is full, event dropped:", e.Event, e.TxID, e.BlockHash)
+		}
+	})
+}
+
+//Transmet l'évènement en réessayant en cas d'échec
+func (h *Hook) deliver(e WalletEvent) {
+	delay := h.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err := h.run(e)
+		if err == nil {
+			return
+		}
+		if attempt >= h.Retries {
+			log.Println("wallet hook failed for", e.Event, e.TxID, e.BlockHash, ":", err)
+			return
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (h *Hook) run(e WalletEvent) error {
+	if h.Command != "" {
+		if err := h.runCommand(e); err != nil {
+			return err
+		}
+	}
+	if h.URL != "" {
+		return h.post(e)
+	}
+	return nil
+}
+
+func (h *Hook) runCommand(e WalletEvent) error {
+	replacer := strings.NewReplacer(
+		"%e", e.Event,
+		"%t", e.TxID,
+		"%a", strconv.Itoa(e.Amount),
+		"%c", strconv.Itoa(e.Confirmations),
+		"%h", strconv.Itoa(e.Height),
+		"%b", e.BlockHash,
+		"%w", e.Wallet,
+	)
+	cmd := exec.Command("sh", "-c", replacer.Replace(h.Command))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (h *Hook) post(e WalletEvent) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(h.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", h.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+	b "tway/blockchain"
+	"tway/mempool"
+	"tway/script"
+	"tway/twayutil"
+	"tway/util"
+)
+
+//Nombre de confirmations avant qu'une récompense de minage soit dépensable
+//par le wallet, la récompense du dernier block peut être perdue lors d'un fork
+const CoinbaseMaturity = 2
+
+//Transactions non confirmées du wallet et de la mempool
+type pendingState struct {
+	txs []*twayutil.Transaction
+	//outpoints (txid:vout) dépensés par les transactions non confirmées
+	spent map[string]bool
+	//transactions coinbase du wallet n'ayant pas atteint CoinbaseMaturity confirmations
+	immature map[string]bool
+}
+
+func outpoint(txID []byte, vout int) string {
+	return fmt.Sprintf("%x:%d", txID, vout)
+}
+
+//Retourne true si chaque input de la transaction est un UTXO ou un output d'une
+//autre transaction non confirmée, une transaction dont un input a été dépensé
+//par une transaction confirmée ne sera jamais confirmée
+func isPendingTxValid(tx *twayutil.Transaction, candidates map[string]*twayutil.Transaction) bool {
+	for _, in := range tx.Inputs {
+		if candidates[hex.EncodeToString(in.PrevTransactionHash)] != nil {
+			continue
+		}
+		if b.UTXO.GetUnSpentOutputByVoutAndTxHash(util.DecodeInt(in.Vout), in.PrevTransactionHash) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+//Récupère les transactions non confirmées de l'historique et de la mempool
+func getPendingState() *pendingState {
+	ps := &pendingState{spent: make(map[string]bool), immature: make(map[string]bool)}
+	candidates := make(map[string]*twayutil.Transaction)
+
+	historyMu.Lock()
+	for txID, wtx := range History {
+		if wtx.Coinbase && wtx.Height > -1 && wtx.Confirmations() < CoinbaseMaturity {
+			ps.immature[txID] = true
+		}
+		//une transaction remplacée ne sera jamais confirmée
+		if wtx.Height == -1 && wtx.Tx != nil && wtx.ReplacedBy == "" {
+			candidates[txID] = wtx.Tx
+		}
+	}
+	historyMu.Unlock()
+
+	pool := mempool.Mempool.PoolToTxSlice()
+	for i := range pool {
+		candidates[hex.EncodeToString(pool[i].GetHash())] = &pool[i]
+	}
+	for _, tx := range candidates {
+		if isPendingTxValid(tx, candidates) == false {
+			continue
+		}
+		ps.txs = append(ps.txs, tx)
+		for _, in := range tx.Inputs {
+			ps.spent[outpoint(in.PrevTransactionHash, util.DecodeInt(in.Vout))] = true
+		}
+	}
+	return ps
+}
+
+//Retourne true si l'UTXO n'est pas dépensé par une transaction non confirmée
+func (ps *pendingState) isAvailable(us b.UnspentOutput) bool {
+	return ps.spent[outpoint(us.TxID, us.Idx)] == false
+}
+
+//Retourne true si l'UTXO est une récompense de minage pas encore dépensable
+func (ps *pendingState) isImmature(us b.UnspentOutput) bool {
+	return ps.immature[hex.EncodeToString(us.TxID)]
+}
+
+//Retourne le montant des outputs non confirmés et non dépensés
+//locké avec la clé publique ou le pubKeyHash de l'adresse
+func (ps *pendingState) unconfirmedAmount(addr string) int {
+	var amount int
+	for _, tx := range ps.txs {
+		for idx, out := range tx.Outputs {
+			class := script.Script.GetScriptClass(out.ScriptPubKey)
+			if class != script.PubKeyHashTy && class != script.PubKeyTy {
+				continue
+			}
+			if scriptAddress(out.ScriptPubKey) == addr && ps.spent[outpoint(tx.GetHash(), idx)] == false {
+				amount += util.DecodeInt(out.Value)
+			}
+		}
+	}
+	return amount
+}
+
+//Ajoute la transaction remplaçant une transaction non confirmée du wallet
+//dans l'historique, après son envoi à un autre noeud
+func ReplacePendingTx(replacedTxID string, tx *twayutil.Transaction) {
+	historyMu.Lock()
+	replaced := History[replacedTxID]
+	historyMu.Unlock()
+	if replaced != nil && replaced.Tx != nil {
+		MarkReplaced(replaced.Tx, tx)
+	}
+	AddPendingTx(tx)
+}
+
+//Ajoute une transaction envoyée à un autre noeud dans l'historique du wallet.
+//Ses inputs ne sont plus sélectionnés tant qu'elle n'est pas confirmée
+//ou abandonnée avec AbandonTx.
+func AddPendingTx(tx *twayutil.Transaction) {
+	w := selectedWallet()
+	w.updateHistory(func() bool {
+		if _, exist := w.history[hex.EncodeToString(tx.GetHash())]; exist {
+			return false
+		}
+		return w.recordTx(tx, -1, time.Now().Unix())
+	})
+}
+
+//Retire une transaction non confirmée de l'historique, ses inputs
+//redeviennent dépensables
+func AbandonTx(txID string) error {
+	var err error
+	w := selectedWallet()
+	w.updateHistory(func() bool {
+		wtx, exist := w.history[txID]
+		switch {
+		case exist == false:
+			err = errors.New("transaction not found in wallet history")
+		case wtx.Height > -1:
+			err = errors.New("transaction is already confirmed")
+		case mempool.Mempool.GetTx(txID) != nil:
+			err = errors.New("transaction is in the mempool")
+		default:
+			delete(w.history, txID)
+			return true
+		}
+		return false
+	})
+	return err
+}
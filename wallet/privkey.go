@@ -0,0 +1,102 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	conf "tway/config"
+	"tway/keys"
+	"tway/util"
+)
+
+//La version des clés privées encodées dépend du réseau actif, voir conf.ActiveNet
+const (
+	//La clé publique liée à la clé privée est utilisée au format compressé
+	privKeyCompressedFlag = byte(0x01)
+	//La clé publique est utilisée au format X||Y des premières versions
+	privKeyLegacyFlag = byte(0x00)
+	//version, clé privée, flag de compression, checksum
+	encodedPrivKeyLen = 1 + keys.PrivKeyBytesLen + 1 + AddressChecksumLen
+)
+
+var ErrInvalidPrivKey = errors.New("invalid encoded private key")
+
+//Encode la clé privée d'un wallet en base58 avec une version et un checksum
+//Le flag de compression indique le format de la clé publique du wallet
+func EncodePrivateKey(w *Wallet) string {
+	flag := privKeyCompressedFlag
+	if keys.IsLegacyPubKey(w.PublicKey) {
+		flag = privKeyLegacyFlag
+	}
+	payload := append([]byte{conf.ActiveNet.PrivateKeyID}, keys.SerializePrivateKey(&w.PrivateKey)...)
+	payload = append(payload, flag)
+	payload = append(payload, checksum(payload)...)
+	return string(util.Base58Encode(payload))
+}
+
+//Décode une clé privée encodée avec EncodePrivateKey
+//La clé publique du wallet retourné est au format indiqué par le flag de compression
+func DecodePrivateKey(encoded string) (*Wallet, error) {
+	decoded := util.Base58Decode([]byte(encoded))
+	if len(decoded) != encodedPrivKeyLen || decoded[0] != conf.ActiveNet.PrivateKeyID {
+		return nil, ErrInvalidPrivKey
+	}
+	payload := decoded[:len(decoded)-AddressChecksumLen]
+	if bytes.Compare(checksum(payload), decoded[len(payload):]) != 0 {
+		return nil, errors.New("private key checksum doesn't match")
+	}
+	flag := payload[len(payload)-1]
+	if flag != privKeyCompressedFlag && flag != privKeyLegacyFlag {
+		return nil, ErrInvalidPrivKey
+	}
+	d := payload[1 : 1+keys.PrivKeyBytesLen]
+	//la clé doit être comprise entre 1 et l'ordre de la courbe
+	n := new(big.Int).SetBytes(d)
+	if n.Sign() == 0 || n.Cmp(keys.Curve().Params().N) >= 0 {
+		return nil, ErrInvalidPrivKey
+	}
+	priv := keys.ParsePrivateKey(d)
+	pubKey := keys.PubKeyFromPrivate(priv).SerializeCompressed()
+	if flag == privKeyLegacyFlag {
+		pubKey = keys.PubKeyFromPrivate(priv).SerializeLegacy()
+	}
+	return &Wallet{PrivateKey: *priv, PublicKey: pubKey}, nil
+}
+
+//Retourne la clé privée encodée d'une adresse locale
+func ExportKey(addr string) (string, error) {
+	addr = NormalizeAddress(addr)
+	if IsAddressStored(addr) == false {
+		return "", errors.New("address is not stored in the wallet")
+	}
+	if err := CheckUnlocked(); err != nil {
+		return "", err
+	}
+	return EncodePrivateKey(GetWallet(addr)), nil
+}
+
+//Ajoute une clé privée encodée aux wallets locaux et met à jour le fichier .dat
+//Retourne l'adresse de la clé importée
+func ImportKey(encoded string) (string, error) {
+	w, err := DecodePrivateKey(encoded)
+	if err != nil {
+		return "", err
+	}
+	if err := CheckUnlocked(); err != nil {
+		return "", err
+	}
+	addr := string(w.GetAddress())
+	if IsAddressStored(addr) {
+		return addr, errors.New("private key is already stored in the wallet")
+	}
+	//l'adresse devient dépensable, elle n'est plus surveillée
+	watched := WatchOnly[addr]
+	delete(WatchOnly, addr)
+	if err := AddWallet(addr, w); err != nil {
+		if watched != nil {
+			WatchOnly[addr] = watched
+		}
+		return "", err
+	}
+	return addr, nil
+}
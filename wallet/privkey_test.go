@@ -0,0 +1,33 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+	"tway/keys"
+)
+
+//Le flag de compression conserve le format de la clé publique, et donc l'adresse
+func TestPrivateKeyRoundTrip(t *testing.T) {
+	w, err := testHDWallet(t).DeriveWallet(HDExternalChain, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := &Wallet{PrivateKey: w.PrivateKey, PublicKey: keys.PubKeyFromPrivate(&w.PrivateKey).SerializeLegacy()}
+	for _, wallet := range []*Wallet{w, legacy} {
+		decoded, err := DecodePrivateKey(EncodePrivateKey(wallet))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Compare(decoded.PublicKey, wallet.PublicKey) != 0 || string(decoded.GetAddress()) != string(wallet.GetAddress()) {
+			t.Fatalf("%s decoded as %s", wallet.GetAddress(), decoded.GetAddress())
+		}
+		if decoded.PrivateKey.D.Cmp(wallet.PrivateKey.D) != 0 {
+			t.Fatal("private key changed")
+		}
+	}
+	encoded := []byte(EncodePrivateKey(w))
+	encoded[len(encoded)-1] = map[bool]byte{true: '2', false: '1'}[encoded[len(encoded)-1] == '1']
+	if _, err := DecodePrivateKey(string(encoded)); err == nil {
+		t.Fatal("bad checksum accepted")
+	}
+}
@@ -0,0 +1,339 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+	b "tway/blockchain"
+	conf "tway/config"
+	"tway/script"
+	"tway/twayutil"
+	"tway/util"
+)
+
+//Nombre de confirmations des paiements pour qu'une demande soit payée
+const RequestConfirmations = 1
+
+//Statut d'une demande de paiement
+const (
+	RequestPending = "pending" //montant non reçu
+	RequestSeen    = "seen"    //montant reçu par des transactions pas assez confirmées
+	RequestPaid    = "paid"    //montant reçu avec au moins RequestConfirmations confirmations
+)
+
+//Demande de paiement vers une adresse de réception dédiée
+type PaymentRequest struct {
+	PaymentURI
+	Created int64
+	//paiements reçus sur l'adresse indexés par le hash (hex) de leur transaction
+	Payments map[string]*RequestPayment
+	//hash de la transaction complétant le paiement, vide tant qu'il n'est pas payé
+	PaidBy string
+	PaidAt int64
+}
+
+//Montant reçu sur l'adresse d'une demande par une transaction
+type RequestPayment struct {
+	Amount int
+	Height int //hauteur du block contenant la transaction, -1 si non confirmée
+}
+
+//Les demandes sont relues depuis le fichier avant chaque mise à jour,
+//le fichier est partagé entre le serveur et les commandes du noeud,
+//il est verrouillé avec lockFile pendant la mise à jour
+var requestsMu sync.Mutex
+
+func (w *walletState) paymentRequestsFile() string {
+	return w.file + ".requests"
+}
+
+//Charge les demandes de paiement indexées par adresse
+func (w *walletState) loadPaymentRequests() map[string]*PaymentRequest {
+	requests := make(map[string]*PaymentRequest)
+	data, err := ioutil.ReadFile(w.paymentRequestsFile())
+	if os.IsNotExist(err) {
+		return requests
+	} else if err != nil {
+		log.Panic(err)
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&requests); err != nil {
+		log.Panic(err)
+	}
+	return requests
+}
+
+func (w *walletState) savePaymentRequests(requests map[string]*PaymentRequest) {
+	var content bytes.Buffer
+	if err := gob.NewEncoder(&content).Encode(requests); err != nil {
+		log.Panic(err)
+	}
+	if err := ioutil.WriteFile(w.paymentRequestsFile(), content.Bytes(), 0600); err != nil {
+		log.Panic(err)
+	}
+}
+
+//Retourne le nombre de confirmations du paiement
+func (p *RequestPayment) Confirmations() int {
+	if p.Height < 0 {
+		return 0
+	}
+	return b.BC.Height - p.Height + 1
+}
+
+//Retourne le montant reçu par les paiements ayant au moins minConf confirmations
+func (r *PaymentRequest) Received(minConf int) int {
+	var total int
+	for _, p := range r.Payments {
+		if p.Confirmations() >= minConf {
+			total += p.Amount
+		}
+	}
+	return total
+}
+
+//Retourne le montant reçu, confirmé ou non
+func (r *PaymentRequest) Seen() int {
+	return r.Received(0)
+}
+
+//Retourne le montant reçu avec au moins RequestConfirmations confirmations
+func (r *PaymentRequest) Paid() int {
+	return r.Received(RequestConfirmations)
+}
+
+//Retourne true si le montant couvre la demande, une demande libre est couverte par tout paiement
+func (r *PaymentRequest) covers(amount int) bool {
+	return amount > 0 && amount >= r.Amount
+}
+
+func (r *PaymentRequest) Status() string {
+	switch {
+	case r.covers(r.Paid()):
+		return RequestPaid
+	case r.covers(r.Seen()):
+		return RequestSeen
+	}
+	return RequestPending
+}
+
+func (r *PaymentRequest) IsPaid() bool {
+	return r.Status() == RequestPaid
+}
+
+//Enregistre le montant reçu par une transaction à la hauteur height (-1 si non confirmée)
+//Retourne true si la demande a été modifiée
+func (r *PaymentRequest) setPayment(txID string, amount, height int) bool {
+	if amount == 0 {
+		return false
+	}
+	if p, exist := r.Payments[txID]; exist && p.Amount == amount && p.Height == height {
+		return false
+	}
+	r.Payments[txID] = &RequestPayment{Amount: amount, Height: height}
+	return true
+}
+
+//Met à jour PaidBy et PaidAt selon les confirmations des paiements
+//Retourne true si la demande a été modifiée
+func (r *PaymentRequest) updatePaid() bool {
+	paidBy := ""
+	if r.IsPaid() {
+		if p, exist := r.Payments[r.PaidBy]; exist && p.Confirmations() >= RequestConfirmations {
+			return false
+		}
+		//la transaction complétant le paiement est la dernière confirmée
+		for txID, p := range r.Payments {
+			if p.Confirmations() >= RequestConfirmations && (paidBy == "" || p.Height > r.Payments[paidBy].Height) {
+				paidBy = txID
+			}
+		}
+	}
+	if paidBy == r.PaidBy {
+		return false
+	}
+	r.PaidBy, r.PaidAt = paidBy, 0
+	if paidBy != "" {
+		r.PaidAt = time.Now().Unix()
+	}
+	return true
+}
+
+//Retourne le montant envoyé par la transaction vers le pubKeyHash
+func amountPaidTo(tx *twayutil.Transaction, pubKeyHash []byte) int {
+	var amount int
+	for _, out := range tx.Outputs {
+		if script.Script.GetScriptClass(out.ScriptPubKey) == script.PubKeyHashTy && bytes.Compare(out.ScriptPubKey[2], pubKeyHash) == 0 {
+			amount += util.DecodeInt(out.Value)
+		}
+	}
+	return amount
+}
+
+//Créer une demande de paiement vers une nouvelle adresse de réception
+func NewPaymentRequest(amount int, label, message string) (*PaymentRequest, error) {
+	addr, err := GenerateWallet()
+	if err != nil {
+		return nil, err
+	}
+	request := &PaymentRequest{
+		PaymentURI: PaymentURI{Address: addr, Amount: amount, Label: label, Message: message},
+		Created:    time.Now().Unix(),
+		Payments:   make(map[string]*RequestPayment),
+	}
+	w := selectedWallet()
+	requestsMu.Lock()
+	defer requestsMu.Unlock()
+	defer lockFile(w.paymentRequestsFile())()
+	requests := w.loadPaymentRequests()
+	requests[addr] = request
+	w.savePaymentRequests(requests)
+	return request, nil
+}
+
+//Met à jour les demandes de paiement du wallet
+//update retourne true si la demande a été modifiée
+func (w *walletState) updatePaymentRequests(update func(r *PaymentRequest) bool) {
+	if _, err := os.Stat(w.paymentRequestsFile()); os.IsNotExist(err) {
+		return
+	}
+	requestsMu.Lock()
+	defer requestsMu.Unlock()
+	defer lockFile(w.paymentRequestsFile())()
+	requests := w.loadPaymentRequests()
+	updated := false
+	for _, r := range requests {
+		if update(r) {
+			updated = true
+		}
+		if r.updatePaid() {
+			updated = true
+		}
+	}
+	if updated {
+		w.savePaymentRequests(requests)
+	}
+}
+
+//Enregistre les paiements des transactions à la hauteur height (-1 si non confirmées)
+//Un paiement déjà enregistré n'est pas modifié par sa réception dans la mempool
+func (w *walletState) recordPayments(txs []*twayutil.Transaction, height int) {
+	w.updatePaymentRequests(func(r *PaymentRequest) bool {
+		pubKeyHash := GetPubKeyHashFromAddress([]byte(r.Address))
+		updated := false
+		for _, tx := range txs {
+			txID := hex.EncodeToString(tx.GetHash())
+			if _, exist := r.Payments[txID]; exist && height == -1 {
+				continue
+			}
+			if r.setPayment(txID, amountPaidTo(tx, pubKeyHash), height) {
+				updated = true
+			}
+		}
+		return updated
+	})
+}
+
+//Supprime les paiements des transactions
+func (w *walletState) dropPayments(txs []*twayutil.Transaction) {
+	w.updatePaymentRequests(func(r *PaymentRequest) bool {
+		updated := false
+		for _, tx := range txs {
+			txID := hex.EncodeToString(tx.GetHash())
+			if _, exist := r.Payments[txID]; exist {
+				delete(r.Payments, txID)
+				updated = true
+			}
+		}
+		return updated
+	})
+}
+
+func blockTxs(block *twayutil.Block) []*twayutil.Transaction {
+	var txs []*twayutil.Transaction
+	for i := range block.Transactions {
+		txs = append(txs, &block.Transactions[i])
+	}
+	return txs
+}
+
+func (w *walletState) paymentRequestsTxAccepted(tx *twayutil.Transaction) {
+	w.recordPayments([]*twayutil.Transaction{tx}, -1)
+}
+
+func (w *walletState) paymentRequestsBlockConnected(block *twayutil.Block, height int) {
+	w.recordPayments(blockTxs(block), height)
+}
+
+//Les paiements du block retiré ne sont plus comptés,
+//ils sont de nouveau vus si leur transaction revient dans la mempool
+func (w *walletState) paymentRequestsBlockDisconnected(block *twayutil.Block) {
+	w.dropPayments(blockTxs(block))
+}
+
+func (w *walletState) paymentRequestsTxReplaced(replaced *twayutil.Transaction) {
+	w.dropPayments([]*twayutil.Transaction{replaced})
+}
+
+//Retourne les demandes de paiement triées par date de création
+//Les paiements reçus alors que le wallet n'était pas utilisé par le noeud
+//sont recherchés dans les UTXOs et les transactions non confirmées,
+//les paiements non confirmés absents de celles-ci et ceux de blocks retirés sont supprimés
+func ListPaymentRequests() []*PaymentRequest {
+	w := selectedWallet()
+	requestsMu.Lock()
+	defer requestsMu.Unlock()
+	defer lockFile(w.paymentRequestsFile())()
+	requests := w.loadPaymentRequests()
+	pending := getPendingState()
+	updated := false
+	var list []*PaymentRequest
+	for _, r := range requests {
+		list = append(list, r)
+		pubKeyHash := GetPubKeyHashFromAddress([]byte(r.Address))
+		unconfirmed := make(map[string]int)
+		for _, tx := range pending.txs {
+			if amount := amountPaidTo(tx, pubKeyHash); amount > 0 {
+				unconfirmed[hex.EncodeToString(tx.GetHash())] = amount
+			}
+		}
+		for txID, p := range r.Payments {
+			if (p.Height == -1 && unconfirmed[txID] == 0) || p.Height > b.BC.Height {
+				delete(r.Payments, txID)
+				updated = true
+			}
+		}
+		for txID, amount := range unconfirmed {
+			if _, exist := r.Payments[txID]; exist == false && r.setPayment(txID, amount, -1) {
+				updated = true
+			}
+		}
+		_, unspents := b.UTXO.GetUnspentOutputsByPubKOrPubKH([][]byte{pubKeyHash}, conf.MAX_COIN)
+		for _, us := range unspents {
+			txID := hex.EncodeToString(us.TxID)
+			//un paiement dépensé en partie n'est pas réduit
+			if p, exist := r.Payments[txID]; exist && p.Height > -1 {
+				continue
+			}
+			tx, _, height := b.GetTxByHash(us.TxID)
+			if tx != nil && r.setPayment(txID, amountPaidTo(tx, pubKeyHash), height) {
+				updated = true
+			}
+		}
+		if r.updatePaid() {
+			updated = true
+		}
+	}
+	if updated {
+		w.savePaymentRequests(requests)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Created < list[j].Created
+	})
+	return list
+}
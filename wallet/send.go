@@ -0,0 +1,218 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	b "tway/blockchain"
+	conf "tway/config"
+	"tway/script"
+	"tway/twayutil"
+	"tway/util"
+)
+
+//Destinataire d'un paiement
+type Recipient struct {
+	Address string `json:"address"`
+	Amount  int    `json:"amount"`
+}
+
+//Paramètres de financement d'une transaction par les wallets locaux
+type FundOptions struct {
+	From       string //adresse dont les UTXOs financent la transaction, toutes si vide
+	Fees       int    //frais fixes
+	FeeRate    int    //frais par octet ajoutés aux frais fixes
+	CoinSelect string //stratégie de sélection des UTXOs, voir SelectCoins
+	//UTXOs choisis par l'utilisateur, la sélection automatique n'est pas utilisée
+	UTXOs []LocalUnspentOutput
+	//adresses dont les UTXOs ne doivent pas être dépensés
+	Exclude []string
+	//la transaction signale qu'elle peut être remplacée, voir BumpFee
+	Replaceable bool
+}
+
+//Retourne le pubKeyHash d'une adresse PayToPubKeyHash base58 ou bech32
+//Retourne une erreur si l'adresse est invalide ou n'est pas une adresse PayToPubKeyHash
+func DecodePubKeyHashAddress(addr string) ([]byte, error) {
+	decoded, err := DecodeAddress(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid address: %s", addr, err)
+	}
+	if decoded.Type != PubKeyHashAddress {
+		return nil, fmt.Errorf("%s is not a pay to public key hash address", addr)
+	}
+	return decoded.Hash, nil
+}
+
+//Parse une liste de destinataires au format JSON ([{"address": ..., "amount": ...}])
+//ou CSV (une ligne address,amount par destinataire, l'en-tête est facultatif)
+func ParseRecipients(data []byte) ([]Recipient, error) {
+	var recipients []Recipient
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &recipients); err != nil {
+			return nil, err
+		}
+		return recipients, nil
+	}
+
+	r := csv.NewReader(bytes.NewReader(trimmed))
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		amount, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: %s is not a valid amount", line, record[1])
+		}
+		recipients = append(recipients, Recipient{strings.TrimSpace(record[0]), amount})
+	}
+	return recipients, nil
+}
+
+//Génère un output PayToPubKeyHash par destinataire
+//Retourne les outputs et leur montant total
+func RecipientOutputs(recipients []Recipient) ([]twayutil.Output, int, error) {
+	if len(recipients) == 0 {
+		return nil, 0, errors.New("no recipient")
+	}
+	var outputs []twayutil.Output
+	var total int
+	for _, r := range recipients {
+		pubKeyHash, err := DecodePubKeyHashAddress(r.Address)
+		if err != nil {
+			return nil, 0, err
+		}
+		if r.Amount <= 0 {
+			return nil, 0, fmt.Errorf("amount sent to %s must be positive", r.Address)
+		}
+		outputs = append(outputs, twayutil.NewTxOutput(script.Script.LockingScript([][]byte{pubKeyHash}, 0), r.Amount))
+		total += r.Amount
+	}
+	return outputs, total, nil
+}
+
+//Récupère les UTXOs pouvant financer la transaction
+func (opts FundOptions) candidates() ([]LocalUnspentOutput, error) {
+	if len(opts.UTXOs) > 0 {
+		return opts.UTXOs, nil
+	}
+	if opts.From == "" {
+		_, list := GetWalletInfo().GetLocalUnspentOutputs(conf.MAX_COIN, opts.Exclude...)
+		return list, nil
+	}
+	pubKeyHash, err := DecodePubKeyHashAddress(opts.From)
+	if err != nil {
+		return nil, errors.New("sender address is not a valid address")
+	}
+	_, list := GetLocalUnspentOutputsByPubKeyHash(pubKeyHash, conf.MAX_COIN)
+	return list, nil
+}
+
+//Créer une transaction signée vers les outputs passés en paramètre.
+//Les inputs sont choisis parmi les UTXOs des wallets locaux, l'excédent
+//est envoyé vers une adresse de rendu inutilisée.
+//Retourne la transaction et la sélection d'UTXOs contenant les frais totaux.
+func FundTransaction(outputs []twayutil.Output, opts FundOptions) (*twayutil.Transaction, *CoinSelection, error) {
+	//les inputs sont signés avec les clés privées des wallets locaux
+	if err := CheckUnlocked(); err != nil {
+		return nil, nil, err
+	}
+	var amount int
+	var outputScripts [][][]byte
+	for _, out := range outputs {
+		amount += util.DecodeInt(out.Value)
+		outputScripts = append(outputScripts, out.ScriptPubKey)
+	}
+
+	//on récupère la totalité des outputs dépensables, la stratégie de sélection
+	//choisit ensuite ceux qui financent le montant et les frais de la transaction
+	candidates, err := opts.candidates()
+	if err != nil {
+		return nil, nil, err
+	}
+	model := NewCostModel(amount, opts.Fees, opts.FeeRate, outputScripts)
+	var selection *CoinSelection
+	if len(opts.UTXOs) > 0 {
+		selection, err = NewManualSelection(candidates, model)
+	} else {
+		selection, err = SelectCoins(opts.CoinSelect, candidates, model)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var inputs []twayutil.Input
+	var inputsPubKey [][]byte
+	var inputsPrivKey []ecdsa.PrivateKey
+	for _, localUs := range selection.Inputs {
+		var emptyScript [][]byte
+		input := twayutil.NewTxInput(localUs.TxID, util.EncodeInt(localUs.Idx), emptyScript)
+		if opts.Replaceable {
+			input.Sequence = util.EncodeInt(twayutil.MaxRBFSequence)
+		}
+		inputs = append(inputs, input)
+		inputsPubKey = append(inputsPubKey, localUs.W.PublicKey)
+		inputsPrivKey = append(inputsPrivKey, localUs.W.PrivateKey)
+	}
+
+	//l'excédent est envoyé vers une adresse de rendu inutilisée
+	outputs = append([]twayutil.Output{}, outputs...)
+	if selection.Change > 0 {
+		changeW, err := GetChangeWallet()
+		if err != nil {
+			return nil, nil, err
+		}
+		changePubKeyHash := [][]byte{HashPubKey(changeW.PublicKey)}
+		outputs = append(outputs, twayutil.NewTxOutput(script.Script.LockingScript(changePubKeyHash, 0), selection.Change))
+	}
+
+	version := conf.VERSION
+	if opts.Replaceable {
+		//une transaction remplaçable doit signer ses outputs et ses séquences
+		version = util.SigHashTxVersion
+	}
+	tx := &twayutil.Transaction{
+		Version:    []byte{version},
+		InCounter:  util.EncodeInt(len(inputs)),
+		Inputs:     inputs,
+		OutCounter: util.EncodeInt(len(outputs)),
+		Outputs:    outputs,
+	}
+
+	prevTXs := make(map[string]*util.Transaction)
+	for _, in := range tx.Inputs {
+		prevTx, _, height := b.GetTxByHash(in.PrevTransactionHash)
+		if height == -1 {
+			return nil, nil, fmt.Errorf("transaction %x not found", in.PrevTransactionHash)
+		}
+		prevTXs[hex.EncodeToString(in.PrevTransactionHash)] = prevTx.ToTxUtil()
+	}
+	tx.Sign(prevTXs, inputsPrivKey, inputsPubKey)
+	return tx, selection, nil
+}
+
+//Créer une transaction signée payant chaque destinataire de la liste
+//avec un unique output de rendu
+func SendMany(recipients []Recipient, opts FundOptions) (*twayutil.Transaction, *CoinSelection, error) {
+	outputs, _, err := RecipientOutputs(recipients)
+	if err != nil {
+		return nil, nil, err
+	}
+	return FundTransaction(outputs, opts)
+}
@@ -8,7 +8,13 @@ import (
 
 type WalletInfo struct {
 	Ws     []WalletStatus
-	Amount int
+	Amount int //montant confirmé et dépensable
+	//outputs des transactions non confirmées et récompenses de minage non matures
+	UnconfirmedAmount int
+	ImmatureAmount    int
+	//fonds des entrées watch-only, non dépensables par le wallet
+	WatchOnly       []WatchOnlyStatus
+	WatchOnlyAmount int
 }
 
 //Structure représentant les informations basique d'une adresse
@@ -16,35 +22,62 @@ type WalletStatus struct {
 	Address                []byte
 	Amount                 int
 	AmountLockedByMultiSig int
+	Unconfirmed            int
+	Immature               int
 	W                      *Wallet
 }
 
+//Structure représentant le solde d'une entrée watch-only
+type WatchOnlyStatus struct {
+	Address string
+	Amount  int
+	Entry   *WatchOnlyEntry
+}
+
 //Retourne une structure WalletInfo
 //permettant d'obtenir les informations concernant
 //les wallets enregistrés localement.
 //Les informations sont le montant de coins disponible
-// pour chaque adresse
+// pour chaque adresse.
+//Les UTXOs dépensés par une transaction non confirmée ne sont pas comptés
 func GetWalletInfo() *WalletInfo {
 	utxo := b.UTXO
+	pending := getPendingState()
 
 	wInfo := &WalletInfo{}
 
 	//pour chaque wallet
 	for _, w := range WalletList {
 		//on récupère le montant disponible pour le wallet
-		amount, list := utxo.GetUnspentOutputsByPubKOrPubKH([][]byte{HashPubKey(w.PublicKey), w.PublicKey}, conf.MAX_COIN)
+		_, list := utxo.GetUnspentOutputsByPubKOrPubKH([][]byte{HashPubKey(w.PublicKey), w.PublicKey}, conf.MAX_COIN)
 
-		var amountLocked int
+		var amount, amountLocked, immature int
 		for _, us := range list {
-			if us.MultiSig == true {
-				amountLocked += util.DecodeInt(us.Output.Value)
+			value := util.DecodeInt(us.Output.Value)
+			switch {
+			case pending.isAvailable(us) == false:
+			case us.MultiSig == true:
+				amountLocked += value
+			case pending.isImmature(us):
+				immature += value
+			default:
+				amount += value
 			}
 		}
-		amount -= amountLocked
-		ws := WalletStatus{w.GetAddress(), amount, amountLocked, w}
+		addr := w.GetAddress()
+		unconfirmed := pending.unconfirmedAmount(string(addr))
+		ws := WalletStatus{addr, amount, amountLocked, unconfirmed, immature, w}
 		wInfo.Ws = append(wInfo.Ws, ws)
 
 		wInfo.Amount += amount
+		wInfo.UnconfirmedAmount += unconfirmed
+		wInfo.ImmatureAmount += immature
+	}
+
+	for addr, entry := range WatchOnly {
+		amount, _ := entry.getUnspentOutputs(pending)
+		wInfo.WatchOnly = append(wInfo.WatchOnly, WatchOnlyStatus{addr, amount, entry})
+		wInfo.WatchOnlyAmount += amount
 	}
 	return wInfo
 }
@@ -0,0 +1,87 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+//Schéma des URI de paiement (processus utilisé par le BTC, BIP 21)
+const URIScheme = "tway"
+
+//Demande de paiement transmise par une URI tway:<address>?amount=…&label=…&message=…
+type PaymentURI struct {
+	Address string
+	Amount  int //0 si le montant est libre
+	Label   string
+	Message string
+}
+
+//Encode une valeur de l'URI, les espaces sont encodés %20
+func escapeURIValue(value string) string {
+	return strings.Replace(url.QueryEscape(value), "+", "%20", -1)
+}
+
+//Retourne l'URI de la demande de paiement, les paramètres vides sont omis
+func EncodeURI(p PaymentURI) string {
+	var params []string
+	if p.Amount > 0 {
+		params = append(params, "amount="+strconv.Itoa(p.Amount))
+	}
+	if p.Label != "" {
+		params = append(params, "label="+escapeURIValue(p.Label))
+	}
+	if p.Message != "" {
+		params = append(params, "message="+escapeURIValue(p.Message))
+	}
+	uri := URIScheme + ":" + p.Address
+	if len(params) > 0 {
+		uri += "?" + strings.Join(params, "&")
+	}
+	return uri
+}
+
+//Decode une URI de paiement et vérifie son adresse et son montant
+//Un paramètre inconnu préfixé par req- est obligatoire, l'URI est alors refusée
+func DecodeURI(uri string) (*PaymentURI, error) {
+	prefix := URIScheme + ":"
+	if len(uri) < len(prefix) || strings.ToLower(uri[:len(prefix)]) != prefix {
+		return nil, fmt.Errorf("payment uri must start with %s", prefix)
+	}
+	rest := strings.TrimPrefix(uri[len(prefix):], "//")
+	query := ""
+	if pos := strings.IndexByte(rest, '?'); pos >= 0 {
+		rest, query = rest[:pos], rest[pos+1:]
+	}
+	p := &PaymentURI{Address: rest}
+	if _, err := DecodeAddress(p.Address); err != nil {
+		return nil, fmt.Errorf("payment uri address is not valid: %s", err)
+	}
+
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range params {
+		if len(values) > 1 {
+			return nil, fmt.Errorf("parameter %s is set twice", key)
+		}
+		switch key {
+		case "amount":
+			if p.Amount, err = strconv.Atoi(values[0]); err != nil || p.Amount <= 0 {
+				return nil, errors.New("payment uri amount must be a positive number of coins")
+			}
+		case "label":
+			p.Label = values[0]
+		case "message":
+			p.Message = values[0]
+		default:
+			if strings.HasPrefix(key, "req-") {
+				return nil, fmt.Errorf("required parameter %s is not supported", key)
+			}
+		}
+	}
+	return p, nil
+}
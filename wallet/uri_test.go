@@ -0,0 +1,98 @@
+package wallet
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func testURIAddress() string {
+	return string(GetAddressFromPubKeyHash(bytes.Repeat([]byte{0x42}, 20)))
+}
+
+func TestURIRoundTrip(t *testing.T) {
+	addr := testURIAddress()
+	tests := []PaymentURI{
+		{Address: addr},
+		{Address: addr, Amount: 1500},
+		{Address: addr, Amount: 20, Label: "Café Léa", Message: "order #12 & co = 100%"},
+		{Address: addr, Message: "a+b c?d"},
+	}
+	for _, p := range tests {
+		uri := EncodeURI(p)
+		decoded, err := DecodeURI(uri)
+		if err != nil {
+			t.Fatalf("%s: %v", uri, err)
+		}
+		if *decoded != p {
+			t.Fatalf("%s decoded as %+v, want %+v", uri, *decoded, p)
+		}
+	}
+	if uri := EncodeURI(PaymentURI{Address: addr}); uri != URIScheme+":"+addr {
+		t.Fatalf("empty parameters encoded: %s", uri)
+	}
+}
+
+//Les espaces sont encodés %20, les deux encodages sont acceptés au décodage
+func TestURISpaces(t *testing.T) {
+	addr := testURIAddress()
+	uri := EncodeURI(PaymentURI{Address: addr, Label: "John Doe"})
+	if strings.Contains(uri, "label=John%20Doe") == false {
+		t.Fatalf("space not encoded as %%20: %s", uri)
+	}
+	for _, label := range []string{"John%20Doe", "John+Doe"} {
+		p, err := DecodeURI(URIScheme + ":" + addr + "?label=" + label)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Label != "John Doe" {
+			t.Fatalf("label %s decoded as %q", label, p.Label)
+		}
+	}
+}
+
+func TestDecodeURIParams(t *testing.T) {
+	addr := testURIAddress()
+	p, err := DecodeURI(strings.ToUpper(URIScheme) + "://" + addr + "?amount=10&foo=bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Address != addr || p.Amount != 10 {
+		t.Fatalf("decoded %+v", *p)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"required parameter", "?req-foo=bar"},
+		{"required parameter with amount", "?amount=10&req-expires=123"},
+		{"duplicate amount", "?amount=10&amount=20"},
+		{"duplicate label", "?label=a&label=b"},
+		{"negative amount", "?amount=-5"},
+		{"zero amount", "?amount=0"},
+		{"decimal amount", "?amount=1.5"},
+		{"text amount", "?amount=ten"},
+		{"empty amount", "?amount="},
+		{"bad escape", "?label=%zz"},
+	}
+	for _, test := range tests {
+		if p, err := DecodeURI(URIScheme + ":" + addr + test.query); err == nil {
+			t.Fatalf("%s: uri accepted as %+v", test.name, *p)
+		}
+	}
+}
+
+func TestDecodeURIAddress(t *testing.T) {
+	addr := testURIAddress()
+	for _, uri := range []string{
+		"bitcoin:" + addr,
+		addr,
+		URIScheme + ":",
+		URIScheme + ":" + addr[:len(addr)-1] + "1",
+	} {
+		if _, err := DecodeURI(uri); err == nil {
+			t.Fatalf("%s accepted", uri)
+		}
+	}
+}
@@ -2,43 +2,60 @@ package wallet
 
 import (
 	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/ecdsa"
 	"log"
 	"bytes"
 	"encoding/gob"
 	"io/ioutil"
 	"os"
+	"strings"
+	conf "tway/config"
+	"tway/keys"
 )
 
 //Génère une clé de pair (privée, publique)
+//la clé publique est encodée au format SEC compressé
 func newKeyPair() (ecdsa.PrivateKey, []byte) {
-	curve := elliptic.P256()
-	private, err := ecdsa.GenerateKey(curve, rand.Reader)
+	private, err := keys.NewPrivateKey()
 	if err != nil {
 		log.Panic(err)
 	}
-	pubKey := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
+	pubKey := keys.PubKeyFromPrivate(private).SerializeCompressed()
 
 	return *private, pubKey
 }
 
-//Sauvegarde la liste des wallets dans le fichier .dat du client
-func SaveToFile() {
-	var content bytes.Buffer
-
-	gob.Register(elliptic.P256())
+//Contenu du fichier de stockage du wallet
+type walletFile struct {
+	Wallets   map[string]*Wallet
+	MultiSig  map[string]*MultiSigAccount
+	HD        *HDWallet
+	Crypto    *WalletCrypto
+	WatchOnly map[string]*WatchOnlyEntry
+}
 
-	encoder := gob.NewEncoder(&content)
-	err := encoder.Encode(WalletList)
+//Sauvegarde la liste des wallets dans le fichier .dat du client
+//Si le wallet est chiffré, seules les clés chiffrées sont écrites
+//Retourne ErrLocked si une nouvelle clé doit être chiffrée alors que le wallet est verrouillé
+func SaveToFile() error {
+	wallets, hd, c, err := encryptedContent()
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
+	return writeWalletFile(WALLET_FILE, walletFile{wallets, MultiSigAccounts, hd, c, WatchOnly})
+}
 
-	err = ioutil.WriteFile(WALLET_FILE, content.Bytes(), 0644)
-	if err != nil {
-		log.Panic(err)
+//Écrit le contenu d'un fichier .dat
+func writeWalletFile(path string, content walletFile) error {
+	var encoded bytes.Buffer
+
+	gob.Register(elliptic.P256())
+
+	encoder := gob.NewEncoder(&encoded)
+	if err := encoder.Encode(content); err != nil {
+		return err
 	}
+	return ioutil.WriteFile(path, encoded.Bytes(), 0600)
 }
 
 // LoadFromFile loads wallets from the file
@@ -46,18 +63,89 @@ func LoadFromFile() error {
 	if _, err := os.Stat(WALLET_FILE); os.IsNotExist(err) {
 		return err
 	}
-
-	fileContent, err := ioutil.ReadFile(WALLET_FILE)
+	content, err := readWalletFile(WALLET_FILE)
 	if err != nil {
 		log.Panic(err)
 	}
+	WalletList = content.Wallets
+	MultiSigAccounts = content.MultiSig
+	HD = content.HD
+	resetCrypto(content.Crypto)
+	WatchOnly = content.WatchOnly
+	return nil
+}
+
+//Lit le contenu d'un fichier .dat sans modifier les variables du wallet sélectionné
+func readWalletFile(path string) (*walletFile, error) {
+	fileContent, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
 
 	gob.Register(elliptic.P256())
+	var content walletFile
 	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
-	err = decoder.Decode(&WalletList)
-	if err != nil {
-		log.Panic(err)
+	if err = decoder.Decode(&content); err != nil {
+		//les anciens fichiers ne contiennent que la liste des wallets
+		content = walletFile{}
+		decoder = gob.NewDecoder(bytes.NewReader(fileContent))
+		if err = decoder.Decode(&content.Wallets); err != nil {
+			return nil, err
+		}
+	}
+	if content.Wallets == nil {
+		content.Wallets = make(map[string]*Wallet)
+	}
+	if content.MultiSig == nil {
+		content.MultiSig = make(map[string]*MultiSigAccount)
 	}
+	if content.WatchOnly == nil {
+		content.WatchOnly = make(map[string]*WatchOnlyEntry)
+	}
+	//les anciens wallets stockent la clé publique au format X||Y : elle est
+	//conservée telle quelle, l'adresse et les UTXOs de la clé en dépendent
+	content.rekeyAddresses()
+	return &content, nil
+}
 
-	return nil
-}
\ No newline at end of file
+//Les entrées sont indexées par leur adresse, les index sont recalculés
+//si le format des adresses a changé depuis la sauvegarde du fichier :
+//un pubKeyHash commençant par un octet nul était encodé avec un seul "1"
+//en tête avant que base58 n'encode chaque octet nul
+//Les adresses de l'historique sont migrées à son chargement, voir loadHistory
+func (content *walletFile) rekeyAddresses() {
+	wallets := make(map[string]*Wallet)
+	for _, w := range content.Wallets {
+		wallets[string(w.GetAddress())] = w
+	}
+	content.Wallets = wallets
+
+	accounts := make(map[string]*MultiSigAccount)
+	for _, account := range content.MultiSig {
+		accounts[string(account.GetAddress())] = account
+	}
+	content.MultiSig = accounts
+
+	watched := make(map[string]*WatchOnlyEntry)
+	for _, entry := range content.WatchOnly {
+		watched[entry.GetAddress()] = entry
+	}
+	content.WatchOnly = watched
+}
+
+//Retourne l'adresse au format actuel d'une adresse base58 enregistrée avant que
+//chaque octet nul en tête ne soit encodé par un "1", addr si elle est déjà valide
+func migrateAddress(addr string) string {
+	if strings.HasPrefix(addr, "1") == false || IsAddressValid(addr) {
+		return addr
+	}
+	//les octets nuls en tête étaient tous encodés par un seul "1"
+	migrated := addr
+	for i := 0; i < conf.PubKeyHLength; i++ {
+		migrated = "1" + migrated
+		if IsAddressValid(migrated) {
+			return migrated
+		}
+	}
+	return addr
+}
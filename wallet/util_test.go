@@ -0,0 +1,68 @@
+package wallet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//Adresse d'un pubKeyHash commençant par zeros octets nuls, au format actuel
+//et telle qu'encodée avant que base58 n'encode chaque octet nul en tête
+func testLegacyAddress(zeros int) (string, string) {
+	pubKeyHash := append(make([]byte, zeros), bytes.Repeat([]byte{0x42}, 20-zeros)...)
+	addr := string(GetAddressFromPubKeyHash(pubKeyHash))
+	return addr, "1" + strings.TrimLeft(addr, "1")
+}
+
+func TestMigrateAddress(t *testing.T) {
+	for _, zeros := range []int{1, 2, 3} {
+		addr, legacy := testLegacyAddress(zeros)
+		if legacy == addr || IsAddressValid(legacy) {
+			t.Fatalf("%s: legacy address %s is valid", addr, legacy)
+		}
+		if migrated := migrateAddress(legacy); migrated != addr {
+			t.Fatalf("%s migrated as %s, want %s", legacy, migrated, addr)
+		}
+		if migrated := migrateAddress(addr); migrated != addr {
+			t.Fatalf("%s migrated as %s", addr, migrated)
+		}
+	}
+	for _, addr := range []string{"", "1", testURIAddress(), "tway1qqqq", "1zzzz"} {
+		if migrated := migrateAddress(addr); migrated != addr {
+			t.Fatalf("%s migrated as %s", addr, migrated)
+		}
+	}
+}
+
+func TestLoadHistoryMigratesAddresses(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tway-wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	savedFile, savedHistory := WALLET_FILE, History
+	defer func() { WALLET_FILE, History = savedFile, savedHistory }()
+	WALLET_FILE = filepath.Join(dir, "wallet.dat")
+
+	addr, legacy := testLegacyAddress(1)
+	other := testURIAddress()
+	History = map[string]*WalletTx{
+		"aa": {TxID: []byte{0xaa}, Addresses: []string{legacy}, Counterparties: []string{other}},
+		"bb": {TxID: []byte{0xbb}, Addresses: []string{other}, Counterparties: []string{legacy, other}},
+	}
+	w := selectedWallet()
+	w.saveHistory()
+	w.loadHistory()
+	if a := History["aa"].Addresses; len(a) != 1 || a[0] != addr || History["aa"].Counterparties[0] != other {
+		t.Fatalf("addresses %v, counterparties %v", a, History["aa"].Counterparties)
+	}
+	if c := History["bb"].Counterparties; len(c) != 2 || c[0] != addr || c[1] != other {
+		t.Fatalf("counterparties %v", c)
+	}
+	if History["aa"].HasAddress(addr) == false {
+		t.Fatal("transaction not found by its migrated address")
+	}
+}
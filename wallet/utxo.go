@@ -2,6 +2,7 @@ package wallet
 
 import (
 	b "tway/blockchain"
+	conf "tway/config"
 	"tway/util"
 )
 
@@ -17,6 +18,8 @@ type LocalUnspentOutput struct {
 
 //Récupère une liste d'outputs locaux non dépensé locké avec le pubKeyHash
 //d'un montant supérieur ou égal au montant passé en paramètre
+//Les outputs dépensés par une transaction non confirmée, les récompenses
+//de minage non matures et les outputs réservés sont ignorés
 func GetLocalUnspentOutputsByPubKeyHash(pubKeyHash []byte, amount int) (int, []LocalUnspentOutput) {
 	utxo := b.UTXO
 	var list []LocalUnspentOutput
@@ -26,20 +29,32 @@ func GetLocalUnspentOutputsByPubKeyHash(pubKeyHash []byte, amount int) (int, []L
 		return 0, list
 	}
 
-	amount, unspents := utxo.GetUnspentOutputsByPubKOrPubKH([][]byte{pubKeyHash}, amount)
+	pending := getPendingState()
+	_, unspents := utxo.GetUnspentOutputsByPubKOrPubKH([][]byte{pubKeyHash}, conf.MAX_COIN)
 
+	var total int
 	for _, us := range unspents {
+		if total >= amount {
+			break
+		}
+		if pending.isAvailable(us) == false || pending.isImmature(us) || IsUnspentLocked(us.TxID, us.Idx) {
+			continue
+		}
 		localUXO := LocalUnspentOutput{us.TxID, us.Idx, util.DecodeInt(us.Output.Value), w, 0}
 		list = append(list, localUXO)
+		total += localUXO.Amount
 	}
 
-	return amount, list
+	return total, list
 }
 
 //Récupère une liste UTXO sur des wallets
 //enregistrés localement.
+//Les outputs dépensés par une transaction non confirmée, les récompenses
+//de minage non matures et les outputs réservés sont ignorés
 func (wInfo *WalletInfo) GetLocalUnspentOutputs(amount int, notAcceptedAddr ...string) (int, []LocalUnspentOutput) {
 	utxo := b.UTXO
+	pending := getPendingState()
 	var total = 0
 	var localUnSpents []LocalUnspentOutput
 
@@ -55,9 +70,15 @@ BrowseWallet:
 			}
 		}
 
-		a, outs := utxo.GetUnspentOutputsByPubKOrPubKH([][]byte{HashPubKey(ws.W.PublicKey), ws.W.PublicKey}, amount-total)
-		total += a
+		_, outs := utxo.GetUnspentOutputsByPubKOrPubKH([][]byte{HashPubKey(ws.W.PublicKey), ws.W.PublicKey}, conf.MAX_COIN)
 		for _, uo := range outs {
+			if amount < total {
+				break
+			}
+			if pending.isAvailable(uo) == false || pending.isImmature(uo) || IsUnspentLocked(uo.TxID, uo.Idx) {
+				continue
+			}
+			total += util.DecodeInt(uo.Output.Value)
 			var valueLockedByMultiSig int
 			if uo.MultiSig {
 				valueLockedByMultiSig = util.DecodeInt(uo.Output.Value)
@@ -4,25 +4,33 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"fmt"
+	"log"
 	"os"
+	b "tway/blockchain"
+	conf "tway/config"
 	"tway/util"
 )
 
-const (
-	Version            = byte(0x00)
-	AddressChecksumLen = 4 //checksumlen du Bitcoin
-)
+//les versions des adresses dépendent du réseau actif, voir conf.ActiveNet
+const AddressChecksumLen = 4 //checksumlen du Bitcoin
 
 var (
-	WalletList  map[string]*Wallet
-	NODE_ID     string
-	WALLET_FILE = "/Users/fantasim/go/src/tway/assets/dat/"
-	Walletinfo  *WalletInfo
+	WalletList       map[string]*Wallet
+	MultiSigAccounts map[string]*MultiSigAccount
+	WatchOnly        map[string]*WatchOnlyEntry
+	HD               *HDWallet
+	Crypto           *WalletCrypto //nil si le wallet n'est pas chiffré
+	NODE_ID          string
+	WALLET_FILE      = "/Users/fantasim/go/src/tway/assets/dat/"
 )
 
 type Wallet struct {
 	PrivateKey ecdsa.PrivateKey
 	PublicKey  []byte
+	HDPath     string //chemin de dérivation, vide si la clé est aléatoire
+	Change     bool   //adresse générée pour recevoir le rendu d'une transaction
+	//clé privée chiffrée, PrivateKey est vide tant que le wallet est verrouillé
+	EncryptedKey []byte
 }
 
 func InitPKG() {
@@ -32,60 +40,168 @@ func InitPKG() {
 		os.Exit(1)
 	}
 	WALLET_FILE += NODE_ID
-	WalletList = make(map[string]*Wallet)
-	LoadFromFile()
-	Walletinfo = GetWalletInfo()
+	nodeWalletFile = WALLET_FILE
+	//les commandes peuvent ensuite sélectionner un wallet nommé avec SelectWallet
+	openWallet(nodeWalletFile)
+	registerHistoryListeners()
 }
 
 //Gènere un nouveau wallet
 //Ajoute le wallet dans le fichier de stockage wallet du noeud
 //PWD = WalletFile + NODE_ID.dat
-func GenerateWallet() string {
-	w := NewWallet()
+func GenerateWallet() (string, error) {
+	w, err := NewWallet()
+	if err != nil {
+		return "", err
+	}
 	addr := string(w.GetAddress())[:]
 	//ajoute le wallet a la liste des wallets
+	if err := AddWallet(addr, w); err != nil {
+		return "", err
+	}
+	return addr, nil
+}
+
+//Retourne une adresse de rendu n'ayant encore reçu aucune transaction.
+//Une nouvelle adresse, dérivée de la chaîne interne du wallet HD si celui-ci existe,
+//n'est générée et ajoutée au fichier .dat que si toutes ont été utilisées :
+//une transaction abandonnée avant sa diffusion ne consomme pas d'index HD
+func GetChangeWallet() (*Wallet, error) {
+	if err := CheckUnlocked(); err != nil {
+		return nil, err
+	}
+	if w := unusedChangeWallet(); w != nil {
+		return w, nil
+	}
+	var w *Wallet
+	if HD != nil {
+		var err error
+		if w, err = HD.NextWallet(HDInternalChain); err != nil {
+			return nil, err
+		}
+	} else {
+		private, public := newKeyPair()
+		w = &Wallet{PrivateKey: private, PublicKey: public}
+	}
+	w.Change = true
+	if err := AddWallet(string(w.GetAddress()), w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+//Retourne l'adresse de rendu inutilisée la plus petite, nil s'il n'y en a pas
+//Une adresse est utilisée si elle apparaît dans l'historique ou possède des UTXOs
+func unusedChangeWallet() *Wallet {
+	unused := make(map[string]*Wallet)
+	var pubKeyHashes [][]byte
+	for addr, w := range WalletList {
+		if w.Change {
+			unused[addr] = w
+			pubKeyHashes = append(pubKeyHashes, HashPubKey(w.PublicKey))
+		}
+	}
+	if len(unused) == 0 {
+		return nil
+	}
+	historyMu.Lock()
+	for _, wtx := range History {
+		for _, addr := range wtx.Addresses {
+			delete(unused, addr)
+		}
+	}
+	historyMu.Unlock()
+	if len(unused) == 0 {
+		return nil
+	}
+	_, list := b.UTXO.GetUnspentOutputsByPubKOrPubKH(pubKeyHashes, conf.MAX_COIN)
+	for _, us := range list {
+		delete(unused, scriptAddress(us.Output.ScriptPubKey))
+	}
+
+	var found string
+	for addr := range unused {
+		if found == "" || addr < found {
+			found = addr
+		}
+	}
+	if found == "" {
+		return nil
+	}
+	return unused[found]
+}
+
+//Ajoute le wallet à la liste des wallets et met à jour le fichier .dat
+//Le wallet est retiré de la liste si le fichier n'a pas pu être écrit
+func AddWallet(addr string, w *Wallet) error {
 	WalletList[addr] = w
-	//met a jour le fichier .dat
-	SaveToFile()
-	return addr
+	if err := SaveToFile(); err != nil {
+		delete(WalletList, addr)
+		return err
+	}
+	return nil
 }
 
 //Génère un nouveau wallet
-func NewWallet() *Wallet {
+//La clé est dérivée de la seed HD si elle existe, aléatoire sinon
+//Un wallet chiffré doit être déverrouillé pour ajouter une clé
+func NewWallet() (*Wallet, error) {
+	if err := CheckUnlocked(); err != nil {
+		return nil, err
+	}
+	if HD != nil {
+		return HD.NextWallet(HDExternalChain)
+	}
 	private, public := newKeyPair()
-	wallet := Wallet{private, public}
-	return &wallet
+	wallet := Wallet{PrivateKey: private, PublicKey: public}
+	return &wallet, nil
 }
 
-func NewMiningWallet() []byte {
-	Walletinfo = GetWalletInfo()
-	for _, ws := range Walletinfo.Ws {
-		if ws.Amount == 0 {
-			return ws.W.PublicKey
+//Retourne la clé publique recevant la récompense d'un block miné
+func NewMiningWallet() ([]byte, error) {
+	//le mineur du serveur s'exécute pendant que les évènements sont transmis aux wallets chargés
+	walletsMu.Lock()
+	defer walletsMu.Unlock()
+	for _, ws := range GetWalletInfo().Ws {
+		if ws.Amount == 0 && ws.W.Change == false {
+			return ws.W.PublicKey, nil
 		}
 	}
-	w := NewWallet()
+	w, err := NewWallet()
+	if err != nil {
+		return nil, err
+	}
 	addr := string(w.GetAddress())[:]
-	WalletList[addr] = w
-	SaveToFile()
-	return w.PublicKey
+	if err := AddWallet(addr, w); err != nil {
+		return nil, err
+	}
+	if w.HDPath == "" {
+		log.Println("new mining address", addr, "is not derived from a HD seed, backup the wallet file")
+	}
+	return w.PublicKey, nil
 }
 
 //Formate la clé publique en address (processus utilisé par le BTC)
 func (w Wallet) GetAddress() []byte {
-	pubKeyHash := HashPubKey(w.PublicKey)
-
-	versionedPayload := append([]byte{Version}, pubKeyHash...)
-	checksum := checksum(versionedPayload)
+	return encodeBase58Address(PubKeyHashAddress, HashPubKey(w.PublicKey))
+}
 
-	fullPayload := append(versionedPayload, checksum...)
-	address := util.Base58Encode(fullPayload)
+//Formate la clé publique en address bech32
+func (w Wallet) GetBech32Address() string {
+	addr, err := EncodeBech32Address(PubKeyHashAddress, HashPubKey(w.PublicKey))
+	if err != nil {
+		log.Panic(err)
+	}
+	return addr
+}
 
-	return address
+//Retourne le wallet local lié à l'adresse base58 ou bech32, nil s'il n'existe pas
+func GetWallet(addr string) *Wallet {
+	return WalletList[NormalizeAddress(addr)]
 }
 
 func GetPubKeyFromAddress(addr string) []byte {
-	return WalletList[addr].PublicKey
+	return GetWallet(addr).PublicKey
 }
 
 func GetWalletByPubKeyHash(pubKeyHash []byte) *Wallet {
@@ -97,20 +213,23 @@ func GetWalletByPubKeyHash(pubKeyHash []byte) *Wallet {
 	return nil
 }
 
+//Retourne le hash contenu dans une adresse base58 ou bech32
+//Retourne nil si l'adresse n'est pas valide
 func GetPubKeyHashFromAddress(address []byte) []byte {
-	pubKeyHash := util.Base58Decode(address)
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-AddressChecksumLen]
-	return pubKeyHash
+	decoded, err := DecodeAddress(string(address))
+	if err != nil {
+		return nil
+	}
+	return decoded.Hash
 }
 
 func GetAddressFromPubKeyHash(pubkeyHash []byte) []byte {
-	versionedPayload := append([]byte{Version}, pubkeyHash...)
-	checksum := checksum(versionedPayload)
-
-	fullPayload := append(versionedPayload, checksum...)
-	address := util.Base58Encode(fullPayload)
+	return encodeBase58Address(PubKeyHashAddress, pubkeyHash)
+}
 
-	return address
+//Formate le hash d'un script en address (PayToScriptHash)
+func GetAddressFromScriptHash(scriptHash []byte) []byte {
+	return encodeBase58Address(ScriptHashAddress, scriptHash)
 }
 
 //Recupere le checksum d'une clé publique (processus utilisé par le BTC)
@@ -0,0 +1,136 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	b "tway/blockchain"
+	conf "tway/config"
+	"tway/keys"
+	"tway/script"
+	"tway/util"
+)
+
+//Adresse, clé publique ou script multisig surveillé sans clé privée.
+//Les fonds sont suivis par le wallet mais ne peuvent être dépensés
+//qu'avec une transaction signée ailleurs (voir psbt --sign).
+type WatchOnlyEntry struct {
+	Label      string
+	PubKeyHash []byte   //vide pour un script multisig
+	PubKey     []byte   //vide si l'entrée a été importée depuis une adresse
+	Script     [][]byte //scriptPubKey multisig, vide sinon
+}
+
+//Créer une entrée watch-only à partir d'une adresse, d'une clé publique
+//ou d'un script multisig au format hexadecimal
+func NewWatchOnlyEntry(label, data string) (*WatchOnlyEntry, error) {
+	if raw, err := hex.DecodeString(data); err == nil && len(raw) > 0 {
+		if keys.IsPubKey(raw) {
+			return &WatchOnlyEntry{Label: label, PubKeyHash: HashPubKey(raw), PubKey: raw}, nil
+		}
+		srpt, err := script.Script.Deserialize(raw)
+		if err != nil {
+			return nil, err
+		}
+		if _, _, err := script.Script.GetMultiSigInfo(srpt); err != nil {
+			return nil, errors.New("only multisig scripts can be imported")
+		}
+		return &WatchOnlyEntry{Label: label, Script: srpt}, nil
+	}
+
+	decoded, err := DecodeAddress(data)
+	if err != nil {
+		return nil, errors.New("neither an address, a public key nor a multisig script")
+	}
+	if decoded.Type != PubKeyHashAddress {
+		return nil, errors.New("only pay to public key hash addresses can be imported, import the multisig script instead")
+	}
+	return &WatchOnlyEntry{Label: label, PubKeyHash: decoded.Hash}, nil
+}
+
+//Retourne l'adresse de l'entrée
+func (e *WatchOnlyEntry) GetAddress() string {
+	if len(e.Script) > 0 {
+		scriptHash, err := script.Script.Hash(e.Script)
+		if err != nil {
+			return ""
+		}
+		return string(GetAddressFromScriptHash(scriptHash))
+	}
+	return string(GetAddressFromPubKeyHash(e.PubKeyHash))
+}
+
+//Récupère la liste des outputs non dépensés de l'entrée et leur montant total
+//Les outputs multisig contenant la clé publique et ceux dépensés
+//par une transaction non confirmée ne sont pas comptés
+func (e *WatchOnlyEntry) GetUnspentOutputs() (int, []b.UnspentOutput) {
+	return e.getUnspentOutputs(getPendingState())
+}
+
+func (e *WatchOnlyEntry) getUnspentOutputs(pending *pendingState) (int, []b.UnspentOutput) {
+	var unspents []b.UnspentOutput
+	if len(e.Script) > 0 {
+		unspents = b.UTXO.GetUnspentOutputsByScript(e.Script)
+	} else {
+		pubKOrPubKH := [][]byte{e.PubKeyHash}
+		if len(e.PubKey) > 0 {
+			pubKOrPubKH = append(pubKOrPubKH, e.PubKey)
+		}
+		_, list := b.UTXO.GetUnspentOutputsByPubKOrPubKH(pubKOrPubKH, conf.MAX_COIN)
+		for _, us := range list {
+			if us.MultiSig == false {
+				unspents = append(unspents, us)
+			}
+		}
+	}
+	var total int
+	var available []b.UnspentOutput
+	for _, us := range unspents {
+		if pending.isAvailable(us) {
+			total += util.DecodeInt(us.Output.Value)
+			available = append(available, us)
+		}
+	}
+	return total, available
+}
+
+//Ajoute une entrée watch-only et met à jour le fichier .dat
+//Retourne l'adresse de l'entrée
+func ImportWatchOnly(entry *WatchOnlyEntry) (string, error) {
+	addr := entry.GetAddress()
+	if IsAddressStored(addr) || GetMultiSigAccount(addr) != nil {
+		return addr, errors.New("address is already spendable by the wallet")
+	}
+	if IsWatchOnly(addr) {
+		return addr, errors.New("address is already watched")
+	}
+	WatchOnly[addr] = entry
+	if err := SaveToFile(); err != nil {
+		delete(WatchOnly, addr)
+		return addr, err
+	}
+	return addr, nil
+}
+
+//Retire une entrée watch-only, ses transactions restent dans l'historique
+func RemoveWatchOnly(addr string) error {
+	if IsWatchOnly(addr) == false {
+		return errors.New("address is not watched")
+	}
+	addr = NormalizeAddress(addr)
+	entry := WatchOnly[addr]
+	delete(WatchOnly, addr)
+	if err := SaveToFile(); err != nil {
+		WatchOnly[addr] = entry
+		return err
+	}
+	return nil
+}
+
+//Retourne l'entrée watch-only liée à l'adresse base58 ou bech32, nil si elle n'est pas surveillée
+func GetWatchOnly(addr string) *WatchOnlyEntry {
+	return WatchOnly[NormalizeAddress(addr)]
+}
+
+func IsWatchOnly(addr string) bool {
+	return GetWatchOnly(addr) != nil
+}